@@ -0,0 +1,225 @@
+package CLI
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	DataStore "project/DataStore"
+	"strings"
+)
+
+// Exit codes returned by Run
+const (
+	ExitOK    = 0 // the command succeeded
+	ExitError = 1 // the command failed, or found problems in the data
+	ExitUsage = 2 // the command line is invalid
+)
+
+// env is what a command runs against
+type env struct {
+	config DataStore.Config
+	store  *DataStore.DataStore
+	stdout io.Writer
+	stderr io.Writer
+	// changed is set by commands that modify the data, so that it is saved
+	changed bool
+}
+
+// command is a subcommand, e.g. "trails add"
+type command struct {
+	name    string
+	args    string // the arguments shown in the usage
+	summary string
+	// noLoad is set for commands that open the data themselves
+	noLoad bool
+	run    func(e *env, fs *flag.FlagSet, args []string) error
+}
+
+// usageError is returned for invalid command lines
+type usageError struct {
+	err error
+	// reported is set when the flag package already printed the error
+	reported bool
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func usagef(format string, args ...any) error {
+	return usageError{err: fmt.Errorf(format, args...)}
+}
+
+// errProblems is returned by commands that ran but found problems in the
+// data, after reporting them
+var errProblems = errors.New("problems found")
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "trails list", args: "[--region REGION] [--format table|csv|json]", summary: "list the trails", run: listTrails},
+		{name: "trails search", args: "[--difficulty DIFFICULTY,...] [--min-length DISTANCE] [--max-length DISTANCE] [--status STATUS,...] [--region REGION] [--land-unit NAME] [--maintained-before YYYY-MM-DD] [--maintained-after YYYY-MM-DD] [--min-satisfaction N] [--max-satisfaction N] [--date YYYY-MM-DD] [--sort KEY] [--desc] [--page N] [--page-size N] [--format table|csv|json] [TEXT...]", summary: "search the trails by name and location, filter, sort and page them", run: searchTrails},
+		{name: "trails near", args: "--id ID|--at LATITUDE,LONGITUDE [--within DISTANCE] [--format table|csv|json]", summary: "list the trails by distance from a trailhead or point", run: nearbyTrails},
+		{name: "trails add", args: "--name NAME --location LOCATION [--trailhead LATITUDE,LONGITUDE] --length DISTANCE [--elevation-gain HEIGHT] [--difficulty DIFFICULTY] --status STATUS", summary: "add a trail", run: addTrail},
+		{name: "trails update", args: "--id ID [--version N] [--name NAME] [--location LOCATION] [--trailhead LATITUDE,LONGITUDE] [--difficulty DIFFICULTY] [--length DISTANCE] [--elevation-gain HEIGHT]", summary: "change a trail", run: updateTrail},
+		{name: "trails status", args: "--id ID --status STATUS --reason TEXT [--date YYYY-MM-DD]", summary: "change the status of a trail", run: changeStatus},
+		{name: "trails history", args: "--id ID [--format table|csv|json]", summary: "list the status changes of a trail", run: statusHistory},
+		{name: "trails schedule list", args: "[--id ID] [--format table|csv|json]", summary: "list the scheduled status changes", run: listSchedule},
+		{name: "trails schedule add", args: "--id ID --status STATUS --reason TEXT --from YYYY-MM-DD [--until YYYY-MM-DD]", summary: "schedule a status for a trail over a period", run: addSchedule},
+		{name: "trails schedule cancel", args: "--id ID", summary: "cancel a scheduled status change", run: cancelSchedule},
+		{name: "trails import-gpx", args: "[--id ID | --name NAME --location LOCATION --status STATUS] [--difficulty DIFFICULTY] FILE", summary: "set the track of a trail from a GPX file, or add a trail from it", run: importGPX},
+		{name: "trails export-gpx", args: "--id ID [FILE]", summary: "export the track of a trail as GPX, to standard output without FILE", run: exportGPX},
+		{name: "trails delete", args: "--id ID", summary: "delete a trail", run: deleteTrail},
+		{name: "trails import", args: "[--merge] FILE", summary: "import trails from a JSON file", run: importDataset(DataStore.DatasetTrails)},
+		{name: "visitors list", args: "[--format table|csv|json]", summary: "list the visits", run: listVisitors},
+		{name: "visitors add", args: "--name NAME --date YYYY-MM-DD --trail NAME|--trail-id ID --satisfaction 1-5 [--feedback TEXT]", summary: "record a visit", run: addVisitor},
+		{name: "visitors delete", args: "--id ID", summary: "delete a visit", run: deleteVisitor},
+		{name: "visitors import", args: "[--merge] FILE", summary: "import visits from a JSON file", run: importDataset(DataStore.DatasetVisitors)},
+		{name: "maintenance list", args: "[--trail-id ID] [--status STATUS] [--format table|csv|json]", summary: "list the maintenance work orders", run: listMaintenance},
+		{name: "maintenance add", args: "--trail NAME|--trail-id ID --date YYYY-MM-DD --type TYPE [--priority PRIORITY] [--description TEXT] [--crew CREW] [--estimated-hours N] [--status STATUS] [--actual-hours N] [--notes TEXT]", summary: "open a maintenance work order, or record work done", run: addMaintenance},
+		{name: "maintenance update", args: "--id ID [--version N] [--date YYYY-MM-DD] [--type TYPE] [--priority PRIORITY] [--description TEXT] [--crew CREW] [--estimated-hours N]", summary: "change a work order", run: updateMaintenance},
+		{name: "maintenance status", args: "--id ID --status STATUS [--date YYYY-MM-DD] [--actual-hours N] [--notes TEXT]", summary: "move a work order on: requested, scheduled, in progress, done or cancelled", run: changeWorkStatus},
+		{name: "maintenance delete", args: "--id ID", summary: "delete a work order", run: deleteMaintenance},
+		{name: "maintenance due", args: "[--days N | --through YYYY-MM-DD] [--overdue] [--format table|csv|json]", summary: "list the open work orders and planned work due in the days ahead or overdue", run: dueWork},
+		{name: "maintenance plans list", args: "[--trail-id ID] [--format table|csv|json]", summary: "list the recurring maintenance plans", run: listPlans},
+		{name: "maintenance plans add", args: "--trail NAME|--trail-id ID --type TYPE --every N [--period days|weeks] [--start YYYY-MM-DD] [--end YYYY-MM-DD] [--season-start MM-DD --season-end MM-DD] [--priority PRIORITY] [--description TEXT] [--crew CREW] [--estimated-hours N]", summary: "plan recurring maintenance of a trail", run: addPlan},
+		{name: "maintenance plans update", args: "--id ID [--version N] [--type TYPE] [--every N] [--period days|weeks] [--start YYYY-MM-DD] [--end YYYY-MM-DD] [--season-start MM-DD] [--season-end MM-DD] [--priority PRIORITY] [--description TEXT] [--crew CREW] [--estimated-hours N]", summary: "change a maintenance plan", run: updatePlan},
+		{name: "maintenance plans delete", args: "--id ID", summary: "delete a maintenance plan, keeping its work orders", run: deletePlan},
+		{name: "maintenance plans generate", args: "[--days N | --through YYYY-MM-DD]", summary: "create the work orders the plans call for, 30 days ahead by default", run: generateWorkOrders},
+		{name: "maintenance plans import", args: "[--merge] FILE", summary: "import maintenance plans from a JSON file", run: importDataset(DataStore.DatasetPlans)},
+		{name: "maintenance import", args: "[--merge] FILE", summary: "import maintenance records from a JSON file", run: importDataset(DataStore.DatasetMaintenance)},
+		{name: "network junctions list", args: "[--format table|csv|json]", summary: "list the junctions of the trail network", run: listJunctions},
+		{name: "network junctions add", args: "--name NAME [--at LATITUDE,LONGITUDE]", summary: "add a junction", run: addJunction},
+		{name: "network junctions delete", args: "--id ID", summary: "delete a junction no segment meets at", run: deleteJunction},
+		{name: "network segments list", args: "[--trail-id ID] [--format table|csv|json]", summary: "list the segments of the trails", run: listSegments},
+		{name: "network segments add", args: "--trail-id ID --from JUNCTION --to JUNCTION [--length DISTANCE]", summary: "add a segment of a trail between two junctions", run: addSegment},
+		{name: "network segments delete", args: "--id ID", summary: "delete a segment", run: deleteSegment},
+		{name: "network route", args: "--from JUNCTION [--to JUNCTION] [--distance DISTANCE] [--tolerance FRACTION] [--max-difficulty DIFFICULTY] [--date YYYY-MM-DD] [--limit N] [--format table|csv|json]", summary: "plan loops or point-to-point routes avoiding trails closed or under maintenance", run: planRoute},
+		{name: "status", args: "[--date YYYY-MM-DD] [--region REGION] [--format table|csv|json]", summary: "show the status on a date and last maintenance of the trails, by land unit", run: status},
+		{name: "feedback summary", args: "[--format table|csv|json]", summary: "summarize visitor satisfaction", run: feedbackSummary},
+		{name: "check", args: "[--format table|csv|json]", summary: "list broken trail references, exit 1 if there are any", run: check},
+		{name: "export", args: "[--dataset trails|visitors|maintenance|maintenance_plans|status_history|status_schedule|tracks|junctions|segments] [FILE]", summary: "export the data as JSON, to standard output without FILE", run: export},
+		{name: "export-map", args: "[--format geojson|kml] [FILE]", summary: "export the trails with their status today and last maintenance for a map, to standard output without FILE", run: exportMap},
+		{name: "import", args: "[--dataset trails|visitors|maintenance|maintenance_plans|status_history|status_schedule|tracks|junctions|segments] [--merge] FILE", summary: "import data from a JSON file, - for standard input", run: importDataset("")},
+		{name: "serve", args: "[--addr :8080]", summary: "serve the data as a JSON REST API until interrupted", run: serve},
+		{name: "migrate", args: "[--dry-run]", summary: "migrate the data files to the current schema", noLoad: true, run: migrate},
+		{name: "import-csv", summary: "copy the CSV data files into the SQLite database", noLoad: true, run: importCSV},
+		{name: "backups", args: "[--format table|csv|json]", summary: "list the backups of the data files", run: listBackups},
+		{name: "restore", args: "[--file NAME] BACKUP", summary: "replace a data file with one of its backups, exit 1 if the data does not load", run: restore},
+		{name: "help", summary: "list the commands", noLoad: true, run: help},
+	}
+}
+
+// Run executes the subcommand in args, e.g. ["trails", "list"], against the
+// data described by config and returns the process exit code
+func Run(config DataStore.Config, args []string, stdout, stderr io.Writer) int {
+	cmd, rest, ok := find(args)
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command %q.\n", strings.Join(args, " "))
+		Usage(stderr)
+		return ExitUsage
+	}
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s %s\n", cmd.name, cmd.args)
+		fs.PrintDefaults()
+	}
+
+	e := &env{config: config, stdout: stdout, stderr: stderr}
+	if !cmd.noLoad {
+		store, err := DataStore.New(config)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error opening data store:", err)
+			return ExitError
+		}
+		defer store.Close()
+		for _, result := range store.Load() {
+			if result.Err != nil {
+				ReportLoad(stderr, result)
+				return ExitError
+			}
+			// Keep standard output for the command, but mention anything
+			// loading changed or could not read
+			if result.Recovered > 0 || result.Linked > 0 || len(result.Migrations) > 0 ||
+				len(result.Skipped) > 0 || len(result.Invalid) > 0 {
+				ReportLoad(stderr, result)
+			}
+		}
+		e.store = store
+	}
+
+	err := cmd.run(e, fs, rest)
+	if e.changed {
+		if saveErr := e.store.Save(); saveErr != nil {
+			fmt.Fprintln(stderr, "Error saving data:", saveErr)
+			return ExitError
+		}
+	}
+	var usageErr usageError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, flag.ErrHelp):
+		return ExitOK
+	case errors.As(err, &usageErr):
+		if !usageErr.reported {
+			fmt.Fprintln(stderr, "Error:", err)
+			fs.Usage()
+		}
+		return ExitUsage
+	case errors.Is(err, errProblems):
+		return ExitError
+	}
+	fmt.Fprintln(stderr, "Error:", err)
+	return ExitError
+}
+
+// find returns the command named by the first words of args and the
+// arguments that follow it
+func find(args []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd, args[len(words):], true
+		}
+	}
+	return command{}, nil, false
+}
+
+// parse parses the flags of a command, returning a usageError for invalid
+// flags and checking the number of positional arguments
+func parse(fs *flag.FlagSet, args []string, minArgs, maxArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{err: err, reported: true}
+	}
+	switch n := fs.NArg(); {
+	case n < minArgs:
+		return usagef("missing arguments")
+	case n > maxArgs:
+		return usagef("unexpected arguments: %s", strings.Join(fs.Args()[maxArgs:], " "))
+	}
+	return nil
+}
+
+func help(e *env, fs *flag.FlagSet, args []string) error {
+	Usage(e.stdout)
+	return nil
+}
+
+// Usage lists the commands
+func Usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: main [-data-dir DIR] COMMAND [ARGUMENTS]")
+	fmt.Fprintln(w, "Without a command the interactive menu starts.")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintln(w, " ", strings.TrimSpace(cmd.name+" "+cmd.args))
+		fmt.Fprintf(w, "      %s\n", cmd.summary)
+	}
+}
@@ -0,0 +1,361 @@
+package CLI
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	DataStore "project/DataStore"
+	Feedback "project/Feedback"
+	Status "project/Status"
+	Trail "project/Trail"
+	"project/utils"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func status(e *env, fs *flag.FlagSet, args []string) error {
+	date := fs.String("date", Trail.Today(), "date to show the status on, YYYY-MM-DD")
+	region := fs.String("region", "", "only show the trails in this state or region")
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	if !Trail.ValidDate(*date) {
+		return usagef("invalid date %q, please use YYYY-MM-DD", *date)
+	}
+	snapshot, err := e.store.Snapshot()
+	if err != nil {
+		return err
+	}
+	summaries, err := Status.Summaries(snapshot.Trails, snapshot.Maintenance, snapshot.StatusHistory, snapshot.StatusSchedule, *date)
+	if err != nil {
+		return err
+	}
+	if *region != "" {
+		summaries = Status.InRegion(summaries, *region)
+	}
+	// Trails are listed by land unit
+	var grouped []Status.TrailStatus
+	for _, group := range Status.GroupByLandUnit(summaries) {
+		grouped = append(grouped, group.Trails...)
+	}
+	t := table{columns: []string{"trail_id", "name", "land_unit", "location", "status", "since", "reason", "upcoming", "last_maintained", "maintenance_type"}, value: nonNil(grouped)}
+	for _, s := range grouped {
+		upcoming := ""
+		if len(s.Upcoming) > 0 {
+			upcoming = s.Upcoming[0].String()
+		}
+		t.add(s.TrailID, s.Name, s.Location.LandUnit, s.Location.String(), string(s.Status), s.Since, s.Reason, upcoming, s.LastMaintained, s.MaintenanceType)
+	}
+	return e.write(*format, t)
+}
+
+func feedbackSummary(e *env, fs *flag.FlagSet, args []string) error {
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	summary, err := Feedback.Summarize(e.store.Visitors)
+	if err != nil {
+		return err
+	}
+	for _, visitor := range summary.Invalid {
+		fmt.Fprintf(e.stderr, "Skipping invalid satisfaction score for visitor %s: %s\n", visitor.Name, visitor.Satisfaction)
+	}
+	t := table{columns: []string{"score", "entries"}, value: summary}
+	for score := 1; score <= 5; score++ {
+		t.add(strconv.Itoa(score), strconv.Itoa(summary.Scores[score]))
+	}
+	t.add("average", strconv.FormatFloat(summary.Average, 'f', 2, 64))
+	return e.write(*format, t)
+}
+
+func check(e *env, fs *flag.FlagSet, args []string) error {
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	problems, err := e.store.CheckIntegrity()
+	if err != nil {
+		return err
+	}
+	t := table{columns: []string{"entity", "id", "trail_id", "trail_name", "issue"}, value: nonNil(problems)}
+	for _, p := range problems {
+		t.add(p.Entity, p.ID, p.TrailID, p.TrailName, p.Issue)
+	}
+	if err := e.write(*format, t); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return errProblems
+	}
+	return nil
+}
+
+func listBackups(e *env, fs *flag.FlagSet, args []string) error {
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	backups, err := e.store.Backups()
+	if err != nil {
+		return err
+	}
+	t := table{columns: []string{"backup", "file", "taken"}, value: nonNil(backups)}
+	for _, b := range backups {
+		t.add(filepath.Base(b.Path), filepath.Base(b.File), b.Taken.Format("2006-01-02 15:04:05"))
+	}
+	return e.write(*format, t)
+}
+
+func restore(e *env, fs *flag.FlagSet, args []string) error {
+	file := fs.String("file", "", "only look at the backups of this data file, e.g. trails.csv")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	name := fs.Arg(0)
+	backups, err := e.store.Backups()
+	if err != nil {
+		return err
+	}
+	var matches []DataStore.Backup
+	for _, b := range backups {
+		if *file != "" && filepath.Base(b.File) != *file && b.File != *file {
+			continue
+		}
+		if filepath.Base(b.Path) == name || b.Path == name {
+			matches = append(matches, b)
+		}
+	}
+	switch {
+	case len(matches) == 0:
+		return fmt.Errorf("no backup named %s, see backups for the ones there are", name)
+	case len(matches) > 1:
+		return usagef("several data files have a backup named %s, choose one with --file", name)
+	}
+
+	failed := false
+	for _, result := range e.store.Restore(matches[0]) {
+		ReportLoad(e.stdout, result)
+		failed = failed || result.Err != nil
+	}
+	if failed {
+		return errProblems
+	}
+	fmt.Fprintf(e.stdout, "Restored %s from %s.\n", matches[0].File, filepath.Base(matches[0].Path))
+	return nil
+}
+
+func datasetFlag(fs *flag.FlagSet) *string {
+	return fs.String("dataset", "", "only this dataset: "+strings.Join(DataStore.Datasets, ", "))
+}
+
+func export(e *env, fs *flag.FlagSet, args []string) error {
+	dataset := datasetFlag(fs)
+	if err := parse(fs, args, 0, 1); err != nil {
+		return err
+	}
+	path := fs.Arg(0)
+	if path == "" || path == "-" {
+		return e.store.ExportJSON(e.stdout, *dataset)
+	}
+	if err := utils.WriteFileAtomic(path, func(w io.Writer) error {
+		return e.store.ExportJSON(w, *dataset)
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stderr, "Data exported to", path)
+	return nil
+}
+
+func exportMap(e *env, fs *flag.FlagSet, args []string) error {
+	format := fs.String("format", Status.GeoJSON, "map format: "+strings.Join(Status.MapFormats, " or "))
+	if err := parse(fs, args, 0, 1); err != nil {
+		return err
+	}
+	if *format != Status.GeoJSON && *format != Status.KML {
+		return usagef("unknown map format %q, use %s", *format, strings.Join(Status.MapFormats, " or "))
+	}
+	snapshot, err := e.store.Snapshot()
+	if err != nil {
+		return err
+	}
+	trails, err := Status.MapTrails(snapshot.Trails, snapshot.Maintenance, snapshot.StatusHistory, snapshot.StatusSchedule, snapshot.Tracks, Trail.Today())
+	if err != nil {
+		return err
+	}
+	path := fs.Arg(0)
+	if path == "" || path == "-" {
+		return Status.WriteMap(e.stdout, *format, trails, e.config.Units)
+	}
+	if err := utils.WriteFileAtomic(path, func(w io.Writer) error {
+		return Status.WriteMap(w, *format, trails, e.config.Units)
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stderr, "Map exported to", path)
+	return nil
+}
+
+// importDataset returns the command importing a JSON file. An empty
+// dataset imports a whole document, or the dataset given with --dataset.
+func importDataset(dataset string) func(e *env, fs *flag.FlagSet, args []string) error {
+	return func(e *env, fs *flag.FlagSet, args []string) error {
+		selected := &dataset
+		if dataset == "" {
+			selected = datasetFlag(fs)
+		}
+		merge := fs.Bool("merge", false, "update records with a matching ID and add the rest, instead of replacing the stored records")
+		if err := parse(fs, args, 1, 1); err != nil {
+			return err
+		}
+		mode := DataStore.Replace
+		if *merge {
+			mode = DataStore.Merge
+		}
+
+		r := io.Reader(os.Stdin)
+		if path := fs.Arg(0); path != "-" {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		result, err := e.store.ImportJSON(r, *selected, mode)
+		if err != nil {
+			return err
+		}
+		e.changed = true
+		ReportImport(e.stdout, result)
+		return nil
+	}
+}
+
+// ReportImport prints what a JSON import changed in the datasets it
+// imported, and in any other dataset it changed
+func ReportImport(w io.Writer, result DataStore.JSONImportResult) {
+	for _, changes := range []struct {
+		dataset, name string
+		DataStore.Changes
+	}{
+		{DataStore.DatasetTrails, "trails", result.Trails},
+		{DataStore.DatasetVisitors, "visitors", result.Visitors},
+		{DataStore.DatasetMaintenance, "maintenance records", result.Maintenance},
+		{DataStore.DatasetPlans, "maintenance plans", result.Plans},
+		{DataStore.DatasetStatusHistory, "status changes", result.StatusHistory},
+		{DataStore.DatasetStatusSchedule, "scheduled changes", result.StatusSchedule},
+		{DataStore.DatasetTracks, "tracks", result.Tracks},
+		{DataStore.DatasetJunctions, "junctions", result.Junctions},
+		{DataStore.DatasetSegments, "segments", result.Segments},
+	} {
+		if !slices.Contains(result.Imported, changes.dataset) && changes.Changes == (DataStore.Changes{}) {
+			continue
+		}
+		fmt.Fprintf(w, "Imported %s: %d created, %d updated, %d deleted.\n", changes.name, changes.Created, changes.Updated, changes.Deleted)
+	}
+}
+
+func migrate(e *env, fs *flag.FlagSet, args []string) error {
+	dryRun := fs.Bool("dry-run", false, "only report what would change")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	store, err := DataStore.New(e.config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if !*dryRun {
+		// Loading migrates and saves the data files
+		failed := false
+		for _, result := range store.Load() {
+			ReportLoad(e.stdout, result)
+			failed = failed || result.Err != nil
+		}
+		if failed {
+			return errProblems
+		}
+		return nil
+	}
+
+	plans, err := store.PlanMigrations()
+	for _, plan := range plans {
+		if plan.From == plan.To {
+			fmt.Fprintf(e.stdout, "%s: up to date at schema version %d.\n", plan.Path, plan.To)
+			continue
+		}
+		fmt.Fprintf(e.stdout, "%s: would migrate from schema version %d to %d.\n", plan.Path, plan.From, plan.To)
+		for _, step := range plan.Steps {
+			fmt.Fprintln(e.stdout, "  -", step)
+		}
+		if len(plan.AddedColumns) > 0 {
+			fmt.Fprintln(e.stdout, "  Added columns:", strings.Join(plan.AddedColumns, ", "))
+		}
+		if len(plan.RemovedColumns) > 0 {
+			fmt.Fprintln(e.stdout, "  Removed columns:", strings.Join(plan.RemovedColumns, ", "))
+		}
+		fmt.Fprintf(e.stdout, "  %d of %d rows would change.\n", plan.ChangedRows, plan.Rows)
+	}
+	if err != nil {
+		return fmt.Errorf("planning migrations: %w", err)
+	}
+	return nil
+}
+
+func importCSV(e *env, fs *flag.FlagSet, args []string) error {
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	imported, results, err := DataStore.ImportCSV(e.config)
+	for _, result := range results {
+		ReportLoad(e.stdout, result)
+	}
+	if err != nil {
+		return fmt.Errorf("importing CSV files: %w", err)
+	}
+	fmt.Fprintf(e.stdout, "Imported %d trails, %d visitors, %d maintenance records, %d maintenance plans, %d status changes, %d scheduled changes, %d tracks, %d junctions and %d segments into the SQLite database.\n",
+		imported.Trails, imported.Visitors, imported.Maintenance, imported.Plans, imported.StatusHistory, imported.StatusSchedule, imported.Tracks, imported.Junctions, imported.Segments)
+	return nil
+}
+
+// ReportLoad prints the outcome of loading a data file
+func ReportLoad(w io.Writer, result DataStore.LoadResult) {
+	if result.Err != nil {
+		fmt.Fprintf(w, "Error loading %s: %v\n", result.File, result.Err)
+		return
+	}
+	fmt.Fprintf(w, "Loaded %d records from %s.\n", result.Loaded, result.File)
+	if result.Recovered > 0 {
+		fmt.Fprintf(w, "Recovered %d unsaved changes.\n", result.Recovered)
+	}
+	for _, migration := range result.Migrations {
+		fmt.Fprintln(w, "Migrated to schema", migration)
+	}
+	if result.Linked > 0 {
+		fmt.Fprintf(w, "Linked %d records to their trails.\n", result.Linked)
+	}
+	for _, skipped := range result.Skipped {
+		fmt.Fprintln(w, "Skipped", skipped)
+	}
+	for _, invalid := range result.Invalid {
+		fmt.Fprintln(w, "Invalid", invalid)
+	}
+}
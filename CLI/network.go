@@ -0,0 +1,237 @@
+package CLI
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	Network "project/Network"
+	Trail "project/Trail"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func listJunctions(e *env, fs *flag.FlagSet, args []string) error {
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	junctions, err := e.store.Junctions.List()
+	if err != nil {
+		return err
+	}
+	t := table{columns: []string{"id", "name", "coordinates", "version"}, value: nonNil(junctions)}
+	for _, j := range junctions {
+		coordinates := ""
+		if j.Coordinates != nil {
+			coordinates = j.Coordinates.String()
+		}
+		t.add(j.ID, j.Name, coordinates, strconv.Itoa(j.Version))
+	}
+	return e.write(*format, t)
+}
+
+func addJunction(e *env, fs *flag.FlagSet, args []string) error {
+	var junction Network.Junction
+	fs.StringVar(&junction.Name, "name", "", "name of the junction")
+	fs.Func("at", "coordinates of the junction, LATITUDE,LONGITUDE", func(text string) error {
+		coordinates, err := Trail.ParseCoordinates(text)
+		if err != nil {
+			return err
+		}
+		junction.Coordinates = &coordinates
+		return nil
+	})
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if junction.Name == "" {
+		return usagef("--name is required")
+	}
+	junction, err := e.store.Junctions.Create(junction)
+	if err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Added junction %s.\n", junction.ID)
+	return nil
+}
+
+func deleteJunction(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the junction to delete")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	if err := e.store.Junctions.Delete(*id); err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Deleted junction %s.\n", *id)
+	return nil
+}
+
+func listSegments(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("trail-id", "", "only list the segments of the trail with this ID")
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	var segments []Network.Segment
+	var err error
+	if *id != "" {
+		if _, err := e.store.Trails.Get(*id); err != nil {
+			return err
+		}
+		segments, err = Network.Segments(e.store.Segments, *id)
+	} else {
+		segments, err = e.store.Segments.List()
+	}
+	if err != nil {
+		return err
+	}
+	t := table{columns: []string{"id", "trail_id", "from", "to", "length", "version"}, value: nonNil(segments)}
+	for _, s := range segments {
+		t.add(s.ID, s.TrailID, s.From, s.To, s.Length.Format(e.config.Units), strconv.Itoa(s.Version))
+	}
+	return e.write(*format, t)
+}
+
+func addSegment(e *env, fs *flag.FlagSet, args []string) error {
+	var segment Network.Segment
+	fs.StringVar(&segment.TrailID, "trail-id", "", "ID of the trail the segment is part of")
+	from := fs.String("from", "", "ID or name of the junction the segment starts at")
+	to := fs.String("to", "", "ID or name of the junction the segment ends at")
+	fs.Func("length", fmt.Sprintf("length of the segment, e.g. 1.5km (%s without a unit; default: the length of the trail)", e.config.Units.Distance()), func(text string) error {
+		var err error
+		segment.Length, err = Trail.ParseDistance(text, e.config.Units.Distance())
+		return err
+	})
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	switch {
+	case segment.TrailID == "":
+		return usagef("--trail-id is required")
+	case *from == "" || *to == "":
+		return usagef("--from and --to are required")
+	}
+	trail, err := e.store.Trails.Get(segment.TrailID)
+	if err != nil {
+		return err
+	}
+	if segment.Length.Value == 0 {
+		segment.Length = trail.Length
+	}
+	for _, end := range []struct {
+		name string
+		id   *string
+	}{{*from, &segment.From}, {*to, &segment.To}} {
+		junction, err := Network.FindJunction(e.store.Junctions, end.name)
+		if err != nil {
+			return err
+		}
+		*end.id = junction.ID
+	}
+	segment, err = e.store.Segments.Create(segment)
+	if err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Added segment %s of trail %s.\n", segment.ID, segment.TrailID)
+	return nil
+}
+
+func deleteSegment(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the segment to delete")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	if err := e.store.Segments.Delete(*id); err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Deleted segment %s.\n", *id)
+	return nil
+}
+
+func planRoute(e *env, fs *flag.FlagSet, args []string) error {
+	var request Network.RouteRequest
+	from := fs.String("from", "", "ID or name of the junction to start at")
+	to := fs.String("to", "", "ID or name of the junction to finish at (default: a loop back to --from)")
+	fs.Func("distance", fmt.Sprintf("distance to aim for, e.g. 10km (%s without a unit; default: the shortest routes)", e.config.Units.Distance()), func(text string) error {
+		var err error
+		request.Distance, err = Trail.ParseDistance(text, e.config.Units.Distance())
+		return err
+	})
+	fs.Float64Var(&request.Tolerance, "tolerance", 0.2, "how far a route may be from --distance, as a fraction of it")
+	fs.Var(&request.MaxDifficulty, "max-difficulty", "leave out the trails rated harder, "+Trail.DifficultyHelp)
+	date := fs.String("date", Trail.Today(), "date of the hike, YYYY-MM-DD; trails closed or under maintenance that day are avoided")
+	fs.IntVar(&request.Limit, "limit", 5, "most routes to list")
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	if *from == "" {
+		return usagef("--from is required")
+	}
+	if !Trail.ValidDate(*date) {
+		return usagef("invalid date %q, please use YYYY-MM-DD", *date)
+	}
+
+	snapshot, err := e.store.Snapshot()
+	if err != nil {
+		return err
+	}
+	start, err := Network.FindJunction(snapshot.Junctions, *from)
+	if err != nil {
+		return err
+	}
+	request.From = start.ID
+	if *to != "" {
+		end, err := Network.FindJunction(snapshot.Junctions, *to)
+		if err != nil {
+			return err
+		}
+		request.To = end.ID
+	}
+	graph, err := Network.BuildGraph(snapshot.Junctions, snapshot.Segments, snapshot.Trails, snapshot.Maintenance, snapshot.StatusHistory, snapshot.StatusSchedule, *date)
+	if err != nil {
+		return err
+	}
+	routes, err := graph.Plan(request)
+	if err != nil {
+		return err
+	}
+
+	// Say which trails were avoided without mixing it into the output
+	for _, id := range slices.Sorted(maps.Keys(graph.Closed)) {
+		if trail, ok := graph.Trails[id]; ok {
+			fmt.Fprintf(e.stderr, "Avoiding %s (%s).\n", trail.Name, graph.Closed[id])
+		}
+	}
+	t := table{columns: []string{"route", "length", "difficulty", "segments", "trails", "junctions"}, value: nonNil(routes)}
+	for i, route := range routes {
+		junctions := []string{graph.Junctions[route.From].Name}
+		for _, step := range route.Steps {
+			junctions = append(junctions, graph.Junctions[step.To].Name)
+		}
+		t.add(strconv.Itoa(i+1), route.Length.Format(e.config.Units), string(route.Difficulty), strconv.Itoa(len(route.Steps)),
+			strings.Join(route.Trails(), " > "), strings.Join(junctions, " > "))
+	}
+	return e.write(*format, t)
+}
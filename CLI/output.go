@@ -0,0 +1,67 @@
+package CLI
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats
+const (
+	FormatTable = "table"
+	FormatCSV   = "csv"
+	FormatJSON  = "json"
+)
+
+// formatFlag adds the --format flag to a command
+func formatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", FormatTable, "output format: table, csv or json")
+}
+
+// table is the output of a command that lists records. value is written
+// instead of the rows in the json format, so that it keeps its types.
+type table struct {
+	columns []string
+	rows    [][]string
+	value   any
+}
+
+func (t *table) add(row ...string) {
+	t.rows = append(t.rows, row)
+}
+
+// write prints the table in format
+func (e *env) write(format string, t table) error {
+	switch format {
+	case FormatTable:
+		w := tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.ToUpper(strings.Join(t.columns, "\t")))
+		for _, row := range t.rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	case FormatCSV:
+		w := csv.NewWriter(e.stdout)
+		w.Write(t.columns)
+		w.WriteAll(t.rows)
+		return w.Error()
+	case FormatJSON:
+		encoder := json.NewEncoder(e.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(t.value)
+	}
+	return usagef("unknown format %q, use table, csv or json", format)
+}
+
+// checkFormat returns a usageError for an unknown format before a command
+// does any work
+func checkFormat(format string) error {
+	switch format {
+	case FormatTable, FormatCSV, FormatJSON:
+		return nil
+	}
+	return usagef("unknown format %q, use table, csv or json", format)
+}
@@ -0,0 +1,258 @@
+package CLI
+
+import (
+	"flag"
+	"fmt"
+	"project/Maintenance"
+	Trail "project/Trail"
+	"slices"
+	"strconv"
+	"time"
+)
+
+func listPlans(e *env, fs *flag.FlagSet, args []string) error {
+	trailID := fs.String("trail-id", "", "only list the plans of the trail with this ID")
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	var plans []Maintenance.Plan
+	var err error
+	if *trailID != "" {
+		if _, err := e.store.Trails.Get(*trailID); err != nil {
+			return err
+		}
+		plans, err = Maintenance.Plans(e.store.Plans, *trailID)
+	} else {
+		plans, err = e.store.Plans.List()
+	}
+	if err != nil {
+		return err
+	}
+	t := table{columns: []string{"id", "trail_id", "type", "every", "period", "start", "end", "season", "priority", "crew", "generated_through", "version"}, value: nonNil(plans)}
+	for _, p := range plans {
+		season := ""
+		if p.SeasonStart != "" {
+			season = p.SeasonStart + " to " + p.SeasonEnd
+		}
+		t.add(p.ID, p.TrailID, p.Type, strconv.Itoa(p.Every), string(p.Period), p.Start, p.End, season, string(p.Priority), p.Crew, p.GeneratedThrough, strconv.Itoa(p.Version))
+	}
+	return e.write(*format, t)
+}
+
+// planFlags adds the flags holding the details of a maintenance plan
+func planFlags(fs *flag.FlagSet, plan *Maintenance.Plan) {
+	fs.StringVar(&plan.Type, "type", plan.Type, "maintenance type, e.g. cleaning or inspection")
+	fs.IntVar(&plan.Every, "every", plan.Every, "number of days or weeks between the work")
+	fs.Var(&plan.Period, "period", "what --every counts: days or weeks (default: days)")
+	fs.StringVar(&plan.Start, "start", plan.Start, "first day the work is due, YYYY-MM-DD")
+	fs.StringVar(&plan.End, "end", plan.End, "last day the work can be due, YYYY-MM-DD (default: no end)")
+	fs.StringVar(&plan.SeasonStart, "season-start", plan.SeasonStart, "first day of the season the work is due in, MM-DD (default: all year)")
+	fs.StringVar(&plan.SeasonEnd, "season-end", plan.SeasonEnd, "last day of the season the work is due in, MM-DD")
+	fs.Var(&plan.Priority, "priority", "priority of the work orders: "+Maintenance.JoinPriorities()+" (default: normal)")
+	fs.StringVar(&plan.Description, "description", plan.Description, "description of the work")
+	fs.StringVar(&plan.Crew, "crew", plan.Crew, "crew assigned to the work")
+	fs.Float64Var(&plan.EstimatedHours, "estimated-hours", plan.EstimatedHours, "hours the work is expected to take")
+}
+
+func addPlan(e *env, fs *flag.FlagSet, args []string) error {
+	var trailName string
+	plan := Maintenance.Plan{Period: Maintenance.Days, Start: Trail.Today()}
+	fs.StringVar(&trailName, "trail", "", "name of the trail")
+	fs.StringVar(&plan.TrailID, "trail-id", "", "ID of the trail")
+	planFlags(fs, &plan)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	switch {
+	case plan.Type == "":
+		return usagef("--type is required")
+	case plan.Every == 0:
+		return usagef("--every is required")
+	}
+	trail, err := findTrail(e, trailName, plan.TrailID)
+	if err != nil {
+		return err
+	}
+	plan.TrailID = trail.ID
+	if err := plan.Validate(); err != nil {
+		return usageError{err: err}
+	}
+	if plan, err = e.store.Plans.Create(plan); err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Added maintenance plan %s: %s of %s %s.\n", plan.ID, plan.Type, trail.Name, plan.Interval())
+	return nil
+}
+
+func updatePlan(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the plan to change")
+	version := fs.Int("version", 0, "version the changes are based on, the update fails if the plan has changed since (default: the current version)")
+	var changes Maintenance.Plan
+	planFlags(fs, &changes)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	plan, err := e.store.Plans.Get(*id)
+	if err != nil {
+		return err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "version":
+			plan.Version = *version
+		case "type":
+			plan.Type = changes.Type
+		case "every":
+			plan.Every = changes.Every
+		case "period":
+			plan.Period = changes.Period
+		case "start":
+			plan.Start = changes.Start
+		case "end":
+			plan.End = changes.End
+		case "season-start":
+			plan.SeasonStart = changes.SeasonStart
+		case "season-end":
+			plan.SeasonEnd = changes.SeasonEnd
+		case "priority":
+			plan.Priority = changes.Priority
+		case "description":
+			plan.Description = changes.Description
+		case "crew":
+			plan.Crew = changes.Crew
+		case "estimated-hours":
+			plan.EstimatedHours = changes.EstimatedHours
+		}
+	})
+	if err := plan.Validate(); err != nil {
+		return usageError{err: err}
+	}
+	if err := e.store.Plans.Update(plan.ID, plan); err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Updated maintenance plan %s.\n", plan.ID)
+	return nil
+}
+
+func deletePlan(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the plan to delete")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	if err := e.store.Plans.Delete(*id); err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Deleted maintenance plan %s, keeping the work orders it generated.\n", *id)
+	return nil
+}
+
+// throughFlags adds the flags choosing the last day to look ahead to,
+// a number of days from today or a date
+func throughFlags(fs *flag.FlagSet) (days *int, through *string) {
+	days = fs.Int("days", Maintenance.DefaultLead, "number of days ahead of today")
+	through = fs.String("through", "", "last day to look ahead to, YYYY-MM-DD, instead of --days")
+	return days, through
+}
+
+// lookAhead returns the day days after today, or through when it is given
+func lookAhead(days int, through string) (string, error) {
+	switch {
+	case through != "":
+		if !Trail.ValidDate(through) {
+			return "", usagef("invalid --through date %q, please use YYYY-MM-DD", through)
+		}
+		return through, nil
+	case days < 0:
+		return "", usagef("--days cannot be negative")
+	}
+	return time.Now().AddDate(0, 0, days).Format("2006-01-02"), nil
+}
+
+func generateWorkOrders(e *env, fs *flag.FlagSet, args []string) error {
+	days, through := throughFlags(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	date, err := lookAhead(*days, *through)
+	if err != nil {
+		return err
+	}
+	created, err := e.store.GenerateWorkOrders(date)
+	if len(created) > 0 {
+		e.changed = true
+	}
+	for _, order := range created {
+		fmt.Fprintf(e.stdout, "Added work order %s: %s of %s on %s.\n", order.ID, order.Type, order.TrailName, order.Date)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "Generated %d work orders through %s.\n", len(created), date)
+	return nil
+}
+
+func dueWork(e *env, fs *flag.FlagSet, args []string) error {
+	days, through := throughFlags(fs)
+	overdue := fs.Bool("overdue", false, "only list the work overdue")
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	date, err := lookAhead(*days, *through)
+	if err != nil {
+		return err
+	}
+	if date < Trail.Today() {
+		return usagef("--through cannot be before today")
+	}
+
+	snapshot, err := e.store.Snapshot()
+	if err != nil {
+		return err
+	}
+	plans, err := snapshot.Plans.List()
+	if err != nil {
+		return err
+	}
+	records, err := snapshot.Maintenance.List()
+	if err != nil {
+		return err
+	}
+	trails, err := snapshot.Trails.List()
+	if err != nil {
+		return err
+	}
+	work, err := Maintenance.Due(plans, records, trails, Trail.Today(), date)
+	if err != nil {
+		return err
+	}
+	if *overdue {
+		work = slices.DeleteFunc(work, func(w Maintenance.DueWork) bool { return !w.Overdue() })
+	}
+
+	t := table{columns: []string{"date", "days_late", "trail_id", "trail_name", "type", "priority", "status", "id", "plan_id"}, value: nonNil(work)}
+	for _, w := range work {
+		status := string(w.Status)
+		if w.ID == "" {
+			status = "not generated"
+		}
+		t.add(w.Date, strconv.Itoa(w.DaysLate), w.TrailID, w.TrailName, w.Type, string(w.Priority), status, w.ID, w.PlanID)
+	}
+	return e.write(*format, t)
+}
@@ -0,0 +1,704 @@
+package CLI
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	Maintenance "project/Maintenance"
+	Trail "project/Trail"
+	Visitor "project/Visitor"
+	"project/utils"
+	"slices"
+	"strconv"
+)
+
+func listTrails(e *env, fs *flag.FlagSet, args []string) error {
+	region := fs.String("region", "", "only list the trails in this state or region")
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	trails, err := e.store.Trails.List()
+	if err != nil {
+		return err
+	}
+	if *region != "" {
+		trails = slices.DeleteFunc(trails, func(t Trail.Trail) bool { return !t.Location.InRegion(*region) })
+	}
+	// The table shows the status in effect today and the next scheduled
+	// change, the JSON output the trails as they are stored
+	today := Trail.Today()
+	t := table{columns: []string{"id", "name", "location", "trailhead", "difficulty", "length", "elevation_gain", "status", "upcoming", "version"}, value: nonNil(trails)}
+	for _, trail := range trails {
+		history, err := Trail.History(e.store.StatusHistory, trail.ID)
+		if err != nil {
+			return err
+		}
+		schedule, err := Trail.Schedule(e.store.StatusSchedule, trail.ID)
+		if err != nil {
+			return err
+		}
+		status, _ := Trail.StatusOn(trail, history, schedule, today)
+		upcoming := ""
+		if next := Trail.Upcoming(schedule, today); len(next) > 0 {
+			upcoming = next[0].String()
+		}
+		t.add(trail.ID, trail.Name, trail.Location.String(), trailhead(trail), string(trail.Difficulty), trail.Length.Format(e.config.Units),
+			trail.ElevationGain.Format(e.config.Units), string(status), upcoming, strconv.Itoa(trail.Version))
+	}
+	return e.write(*format, t)
+}
+
+// trailFlags adds the flags holding the fields of a trail other than its
+// status, which is changed with trails status. Lengths and elevations
+// without a unit are in units.
+func trailFlags(fs *flag.FlagSet, trail *Trail.Trail, units Trail.Units) {
+	fs.StringVar(&trail.Name, "name", trail.Name, "trail name")
+	fs.Func("location", "trail location: city, state and land unit in parentheses, e.g. \"Boise, ID (Boise National Forest)\"", func(text string) error {
+		trailhead := trail.Location.Trailhead
+		trail.Location = Trail.ParseLocation(text)
+		trail.Location.Trailhead = trailhead
+		return nil
+	})
+	fs.Func("trailhead", "trailhead coordinates as LATITUDE,LONGITUDE", func(text string) error {
+		trailhead, err := Trail.ParseCoordinates(text)
+		trail.Location.Trailhead = &trailhead
+		return err
+	})
+	fs.Var(&trail.Difficulty, "difficulty", "difficulty: "+Trail.DifficultyHelp)
+	fs.Func("length", fmt.Sprintf("length, e.g. 5.2km or 3mi (%s without a unit)", units.Distance()), func(text string) error {
+		var err error
+		trail.Length, err = Trail.ParseDistance(text, units.Distance())
+		return err
+	})
+	fs.Func("elevation-gain", fmt.Sprintf("elevation gain, e.g. 1200ft or 350m (%s without a unit)", units.Elevation()), func(text string) error {
+		var err error
+		trail.ElevationGain, err = Trail.ParseElevation(text, units.Elevation())
+		return err
+	})
+}
+
+// statusUsage describes the flags taking a trail status
+var statusUsage = "status: " + Trail.JoinStatuses(Trail.Statuses)
+
+func addTrail(e *env, fs *flag.FlagSet, args []string) error {
+	var trail Trail.Trail
+	trailFlags(fs, &trail, e.config.Units)
+	fs.Var(&trail.Status, "status", statusUsage)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if trail.Difficulty == "" {
+		trail.Difficulty = Trail.SuggestDifficulty(trail.Length, trail.ElevationGain)
+	}
+	if err := trail.Validate(); err != nil {
+		return err
+	}
+	trail, err := e.store.Trails.Create(trail)
+	if err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Added trail %s.\n", trail.ID)
+	return nil
+}
+
+func updateTrail(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the trail to change")
+	version := fs.Int("version", 0, "version the changes are based on, the update fails if the trail has changed since (default: the current version)")
+	// The fields are parsed into a scratch trail and copied over the
+	// stored one only when given
+	var changes Trail.Trail
+	trailFlags(fs, &changes, e.config.Units)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	trail, err := e.store.Trails.Get(*id)
+	if err != nil {
+		return err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "version":
+			trail.Version = *version
+		case "name":
+			trail.Name = changes.Name
+		case "location":
+			changes.Location.Trailhead = trail.Location.Trailhead
+			trail.Location = changes.Location
+		case "trailhead":
+			trail.Location.Trailhead = changes.Location.Trailhead
+		case "difficulty":
+			trail.Difficulty = changes.Difficulty
+		case "length":
+			trail.Length = changes.Length
+		case "elevation-gain":
+			trail.ElevationGain = changes.ElevationGain
+		}
+	})
+	if err := trail.Validate(); err != nil {
+		return err
+	}
+	if err := e.store.Trails.Update(trail.ID, trail); err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Updated trail %s.\n", trail.ID)
+	return nil
+}
+
+func deleteTrail(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the trail to delete")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	if err := e.store.Trails.Delete(*id); err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Deleted trail %s.\n", *id)
+	return nil
+}
+
+func changeStatus(e *env, fs *flag.FlagSet, args []string) error {
+	var change Trail.StatusChange
+	fs.StringVar(&change.TrailID, "id", "", "ID of the trail")
+	fs.Var(&change.To, "status", "new "+statusUsage)
+	fs.StringVar(&change.Reason, "reason", "", "why the status changes")
+	fs.StringVar(&change.EffectiveDate, "date", "", "date the change takes effect, YYYY-MM-DD (default: today)")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if change.TrailID == "" {
+		return usagef("--id is required")
+	}
+	if change.To == "" {
+		return usagef("--status is required")
+	}
+	change, err := e.store.StatusHistory.Create(change)
+	if err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Trail %s is %s.\n", change.TrailID, change)
+	return nil
+}
+
+func statusHistory(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the trail")
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	if _, err := e.store.Trails.Get(*id); err != nil {
+		return err
+	}
+	history, err := Trail.History(e.store.StatusHistory, *id)
+	if err != nil {
+		return err
+	}
+	t := table{columns: []string{"id", "effective_date", "from", "to", "reason"}, value: nonNil(history)}
+	for _, c := range history {
+		t.add(c.ID, c.EffectiveDate, string(c.From), string(c.To), c.Reason)
+	}
+	return e.write(*format, t)
+}
+
+// trailhead returns the trailhead coordinates of a trail, or "" when they
+// are not known
+func trailhead(trail Trail.Trail) string {
+	if trail.Location.Trailhead == nil {
+		return ""
+	}
+	return trail.Location.Trailhead.String()
+}
+
+func nearbyTrails(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the trail to measure from")
+	at := fs.String("at", "", "coordinates to measure from, LATITUDE,LONGITUDE")
+	var within Trail.Distance
+	fs.Func("within", fmt.Sprintf("only list the trails within this distance, e.g. 10km (%s without a unit; default: no limit)", e.config.Units.Distance()), func(text string) error {
+		var err error
+		within, err = Trail.ParseDistance(text, e.config.Units.Distance())
+		return err
+	})
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	var from Trail.Coordinates
+	switch {
+	case *id != "" && *at != "":
+		return usagef("give --id or --at, not both")
+	case *id != "":
+		trail, err := e.store.Trails.Get(*id)
+		if err != nil {
+			return err
+		}
+		if trail.Location.Trailhead == nil {
+			return fmt.Errorf("the trailhead of trail %s is not known, set it with trails update --trailhead", trail.ID)
+		}
+		from = *trail.Location.Trailhead
+	case *at != "":
+		var err error
+		if from, err = Trail.ParseCoordinates(*at); err != nil {
+			return usageError{err: err}
+		}
+	default:
+		return usagef("--id or --at is required")
+	}
+
+	trails, err := e.store.Trails.List()
+	if err != nil {
+		return err
+	}
+	nearby := slices.DeleteFunc(Trail.Nearby(trails, from, within), func(t Trail.NearbyTrail) bool { return t.ID == *id })
+	t := table{columns: []string{"id", "name", "location", "trailhead", "distance"}, value: nonNil(nearby)}
+	for _, n := range nearby {
+		t.add(n.ID, n.Name, n.Location.String(), trailhead(n.Trail), n.Distance.Format(e.config.Units))
+	}
+	return e.write(*format, t)
+}
+
+func importGPX(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the trail the track is for (default: add a trail)")
+	var fields Trail.Trail
+	fs.StringVar(&fields.Name, "name", "", "name of the new trail (default: the name in the file)")
+	fs.Func("location", "location of the new trail, e.g. \"Boise, ID (Boise National Forest)\"", func(text string) error {
+		fields.Location = Trail.ParseLocation(text)
+		return nil
+	})
+	fs.Var(&fields.Status, "status", "status of the new trail: "+Trail.JoinStatuses(Trail.Statuses))
+	fs.Var(&fields.Difficulty, "difficulty", "difficulty: "+Trail.DifficultyHelp+" (default: the current one, or the one suggested by the track)")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	track, err := Trail.ReadGPX(f)
+	if err != nil {
+		return err
+	}
+
+	var trail Trail.Trail
+	if *id != "" {
+		if fields.Name != "" || !fields.Location.Empty() || fields.Status != "" {
+			return usagef("--name, --location and --status are for a new trail, not with --id")
+		}
+		if trail, err = e.store.Trails.Get(*id); err != nil {
+			return err
+		}
+	} else {
+		trail = fields
+		if trail.Name == "" {
+			trail.Name = track.Name
+		}
+		switch {
+		case trail.Name == "":
+			return usagef("--name is required, the file does not name the track")
+		case trail.Location.Empty():
+			return usagef("--location is required")
+		case trail.Status == "":
+			return usagef("--status is required")
+		}
+	}
+	if fields.Difficulty != "" {
+		trail.Difficulty = fields.Difficulty
+	} else if trail.Difficulty == "" {
+		stats := track.Stats()
+		trail.Difficulty = Trail.SuggestDifficulty(stats.Length, stats.ElevationGain)
+	}
+
+	trail, stats, err := e.store.ImportGPX(trail, track)
+	if err != nil {
+		return err
+	}
+	e.changed = true
+	action := "Updated"
+	if *id == "" {
+		action = "Added"
+	}
+	fmt.Fprintf(e.stdout, "%s trail %s from %d track points.\n", action, trail.ID, len(track.Points))
+	units := e.config.Units
+	fmt.Fprintf(e.stdout, "Length: %s\n", stats.Length.Format(units))
+	fmt.Fprintf(e.stdout, "Elevation gain: %s, loss: %s\n", stats.ElevationGain.Format(units), stats.ElevationLoss.Format(units))
+	fmt.Fprintf(e.stdout, "Elevation: %s to %s\n", stats.MinElevation.Format(units), stats.MaxElevation.Format(units))
+	fmt.Fprintf(e.stdout, "Trailhead: %s\n", stats.Trailhead)
+	return nil
+}
+
+func exportGPX(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the trail")
+	if err := parse(fs, args, 0, 1); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	trail, err := e.store.Trails.Get(*id)
+	if err != nil {
+		return err
+	}
+	track, err := Trail.TrackOf(e.store.Tracks, trail.ID)
+	if err != nil {
+		return err
+	}
+	path := fs.Arg(0)
+	if path == "" || path == "-" {
+		return Trail.WriteGPX(e.stdout, trail, track)
+	}
+	if err := utils.WriteFileAtomic(path, func(w io.Writer) error {
+		return Trail.WriteGPX(w, trail, track)
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stderr, "Track exported to", path)
+	return nil
+}
+
+func listSchedule(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "only list the changes of the trail with this ID")
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	var schedule []Trail.ScheduledChange
+	var err error
+	if *id != "" {
+		if _, err := e.store.Trails.Get(*id); err != nil {
+			return err
+		}
+		schedule, err = Trail.Schedule(e.store.StatusSchedule, *id)
+	} else {
+		schedule, err = e.store.StatusSchedule.List()
+	}
+	if err != nil {
+		return err
+	}
+	t := table{columns: []string{"id", "trail_id", "status", "start", "end", "reason", "version"}, value: nonNil(schedule)}
+	for _, c := range schedule {
+		t.add(c.ID, c.TrailID, string(c.Status), c.Start, c.End, c.Reason, strconv.Itoa(c.Version))
+	}
+	return e.write(*format, t)
+}
+
+func addSchedule(e *env, fs *flag.FlagSet, args []string) error {
+	var change Trail.ScheduledChange
+	fs.StringVar(&change.TrailID, "id", "", "ID of the trail")
+	fs.Var(&change.Status, "status", "scheduled "+statusUsage)
+	fs.StringVar(&change.Reason, "reason", "", "why the status changes")
+	fs.StringVar(&change.Start, "from", "", "first day of the change, YYYY-MM-DD")
+	fs.StringVar(&change.End, "until", "", "last day of the change, YYYY-MM-DD (default: until cancelled)")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	switch {
+	case change.TrailID == "":
+		return usagef("--id is required")
+	case change.Status == "":
+		return usagef("--status is required")
+	case change.Start == "":
+		return usagef("--from is required")
+	}
+	change, err := e.store.StatusSchedule.Create(change)
+	if err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Scheduled change %s: trail %s is %s.\n", change.ID, change.TrailID, change)
+	return nil
+}
+
+func cancelSchedule(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the scheduled change to cancel")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	if err := e.store.StatusSchedule.Delete(*id); err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Cancelled scheduled change %s.\n", *id)
+	return nil
+}
+
+// findTrail returns the trail with the given ID, or else the only trail
+// with the given name
+func findTrail(e *env, name, id string) (Trail.Trail, error) {
+	if id != "" {
+		return e.store.Trails.Get(id)
+	}
+	if name == "" {
+		return Trail.Trail{}, usagef("--trail or --trail-id is required")
+	}
+	trail, err := Trail.Unique(e.store.Trails, name)
+	if errors.Is(err, Trail.ErrAmbiguous) {
+		return trail, fmt.Errorf("%w, use --trail-id", err)
+	}
+	return trail, err
+}
+
+func listVisitors(e *env, fs *flag.FlagSet, args []string) error {
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	visitors, err := e.store.Visitors.List()
+	if err != nil {
+		return err
+	}
+	t := table{columns: []string{"id", "name", "visit_date", "trail_id", "trail", "satisfaction", "feedback", "version"}, value: nonNil(visitors)}
+	for _, v := range visitors {
+		t.add(v.ID, v.Name, v.VisitDate, v.TrailID, v.Trail, v.Satisfaction, v.Feedback, strconv.Itoa(v.Version))
+	}
+	return e.write(*format, t)
+}
+
+func addVisitor(e *env, fs *flag.FlagSet, args []string) error {
+	var visitor Visitor.Visitor
+	fs.StringVar(&visitor.Name, "name", "", "visitor name")
+	fs.StringVar(&visitor.VisitDate, "date", "", "visit date, YYYY-MM-DD")
+	fs.StringVar(&visitor.Trail, "trail", "", "name of the trail visited")
+	fs.StringVar(&visitor.TrailID, "trail-id", "", "ID of the trail visited")
+	fs.StringVar(&visitor.Satisfaction, "satisfaction", "", "satisfaction score, 1 to 5")
+	fs.StringVar(&visitor.Feedback, "feedback", "", "feedback, e.g. 'satisfied, wildlife'")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	trail, err := findTrail(e, visitor.Trail, visitor.TrailID)
+	if err != nil {
+		return err
+	}
+	visitor.TrailID, visitor.Trail = trail.ID, trail.Name
+	if err := visitor.Validate(); err != nil {
+		return err
+	}
+	if visitor, err = e.store.Visitors.Create(visitor); err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Added visitor %s.\n", visitor.ID)
+	return nil
+}
+
+func deleteVisitor(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the visit to delete")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	if err := e.store.Visitors.Delete(*id); err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Deleted visitor %s.\n", *id)
+	return nil
+}
+
+func listMaintenance(e *env, fs *flag.FlagSet, args []string) error {
+	trailID := fs.String("trail-id", "", "only list the work orders of the trail with this ID")
+	var status Maintenance.WorkStatus
+	fs.Var(&status, "status", "only list the work orders with this status: "+Maintenance.JoinWorkStatuses(Maintenance.WorkStatuses))
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	records, err := e.store.Maintenance.List()
+	if err != nil {
+		return err
+	}
+	records = slices.DeleteFunc(records, func(m Maintenance.Maintenance) bool {
+		return (*trailID != "" && m.TrailID != *trailID) || (status != "" && m.Status != status)
+	})
+	t := table{columns: []string{"id", "trail_id", "trail_name", "date", "type", "priority", "status", "crew", "estimated_hours", "actual_hours", "version"}, value: nonNil(records)}
+	for _, m := range records {
+		t.add(m.ID, m.TrailID, m.TrailName, m.Date, m.Type, string(m.Priority), string(m.Status), m.Crew,
+			strconv.FormatFloat(m.EstimatedHours, 'f', -1, 64), strconv.FormatFloat(m.ActualHours, 'f', -1, 64), strconv.Itoa(m.Version))
+	}
+	return e.write(*format, t)
+}
+
+// workOrderFlags adds the flags holding the details of a work order
+func workOrderFlags(fs *flag.FlagSet, record *Maintenance.Maintenance) {
+	fs.StringVar(&record.Date, "date", record.Date, "date the work is planned for or was done on, YYYY-MM-DD")
+	fs.StringVar(&record.Type, "type", record.Type, "maintenance type, e.g. cleaning or repair")
+	fs.Var(&record.Priority, "priority", "priority: "+Maintenance.JoinPriorities()+" (default: normal)")
+	fs.StringVar(&record.Description, "description", record.Description, "description of the work")
+	fs.StringVar(&record.Crew, "crew", record.Crew, "crew assigned to the work")
+	fs.Float64Var(&record.EstimatedHours, "estimated-hours", record.EstimatedHours, "hours the work is expected to take")
+}
+
+func addMaintenance(e *env, fs *flag.FlagSet, args []string) error {
+	var record Maintenance.Maintenance
+	fs.StringVar(&record.TrailName, "trail", "", "name of the trail maintained")
+	fs.StringVar(&record.TrailID, "trail-id", "", "ID of the trail maintained")
+	workOrderFlags(fs, &record)
+	fs.Var(&record.Status, "status", "status: "+Maintenance.JoinWorkStatuses(Maintenance.WorkStatuses)+" (default: requested)")
+	fs.Float64Var(&record.ActualHours, "actual-hours", 0, "hours the work took, for work already done")
+	fs.StringVar(&record.Notes, "notes", "", "completion notes, for work already done")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	trail, err := findTrail(e, record.TrailName, record.TrailID)
+	if err != nil {
+		return err
+	}
+	record.TrailID, record.TrailName = trail.ID, trail.Name
+	if err := record.Validate(); err != nil {
+		return err
+	}
+	if record, err = e.store.Maintenance.Create(record); err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Added maintenance record %s.\n", record.ID)
+	return nil
+}
+
+func updateMaintenance(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the work order to change")
+	version := fs.Int("version", 0, "version the changes are based on, the update fails if the work order has changed since (default: the current version)")
+	var changes Maintenance.Maintenance
+	workOrderFlags(fs, &changes)
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	record, err := e.store.Maintenance.Get(*id)
+	if err != nil {
+		return err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "version":
+			record.Version = *version
+		case "date":
+			record.Date = changes.Date
+		case "type":
+			record.Type = changes.Type
+		case "priority":
+			record.Priority = changes.Priority
+		case "description":
+			record.Description = changes.Description
+		case "crew":
+			record.Crew = changes.Crew
+		case "estimated-hours":
+			record.EstimatedHours = changes.EstimatedHours
+		}
+	})
+	if err := record.Validate(); err != nil {
+		return err
+	}
+	if err := e.store.Maintenance.Update(record.ID, record); err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Updated work order %s.\n", record.ID)
+	return nil
+}
+
+func changeWorkStatus(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the work order")
+	var status Maintenance.WorkStatus
+	fs.Var(&status, "status", "new status: "+Maintenance.JoinWorkStatuses(Maintenance.WorkStatuses))
+	date := fs.String("date", "", "date the work was done on, YYYY-MM-DD (default: the date of the work order)")
+	hours := fs.Float64("actual-hours", 0, "hours the work took")
+	notes := fs.String("notes", "", "completion notes, or why the work order is cancelled")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	switch {
+	case *id == "":
+		return usagef("--id is required")
+	case status == "":
+		return usagef("--status is required")
+	}
+	record, err := e.store.Maintenance.Get(*id)
+	if err != nil {
+		return err
+	}
+	record.Status = status
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "date":
+			record.Date = *date
+		case "actual-hours":
+			record.ActualHours = *hours
+		case "notes":
+			record.Notes = *notes
+		}
+	})
+	if err := record.Validate(); err != nil {
+		return err
+	}
+	if err := e.store.Maintenance.Update(record.ID, record); err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Work order %s is now %s.\n", record.ID, record.Status)
+	return nil
+}
+
+func deleteMaintenance(e *env, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the maintenance record to delete")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	if err := e.store.Maintenance.Delete(*id); err != nil {
+		return err
+	}
+	e.changed = true
+	fmt.Fprintf(e.stdout, "Deleted maintenance record %s.\n", *id)
+	return nil
+}
+
+// nonNil makes an empty list print as [] rather than null in JSON
+func nonNil[T any](records []T) []T {
+	if records == nil {
+		return []T{}
+	}
+	return records
+}
@@ -0,0 +1,94 @@
+package CLI
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	Search "project/Search"
+	Trail "project/Trail"
+	"strconv"
+	"strings"
+)
+
+func searchTrails(e *env, fs *flag.FlagSet, args []string) error {
+	var query Search.Query
+	fs.Func("difficulty", "only the trails rated like one of these difficulties, separated by commas: "+Trail.DifficultyHelp, func(text string) error {
+		for _, part := range strings.Split(text, ",") {
+			difficulty, err := Trail.ParseDifficulty(part)
+			if err != nil {
+				return err
+			}
+			query.Difficulties = append(query.Difficulties, difficulty)
+		}
+		return nil
+	})
+	for _, bound := range []struct {
+		name, bound string
+		length      *Trail.Distance
+	}{{"min-length", "at least", &query.MinLength}, {"max-length", "at most", &query.MaxLength}} {
+		fs.Func(bound.name, fmt.Sprintf("only the trails %s this long, e.g. 5km (%s without a unit)", bound.bound, e.config.Units.Distance()), func(text string) error {
+			var err error
+			*bound.length, err = Trail.ParseDistance(text, e.config.Units.Distance())
+			return err
+		})
+	}
+	fs.Func("status", "only the trails with one of these statuses on --date, separated by commas", func(text string) error {
+		for _, part := range strings.Split(text, ",") {
+			status, err := Trail.ParseStatus(part)
+			if err != nil {
+				return err
+			}
+			query.Statuses = append(query.Statuses, status)
+		}
+		return nil
+	})
+	fs.StringVar(&query.Region, "region", "", "only the trails in this state or region")
+	fs.StringVar(&query.LandUnit, "land-unit", "", "only the trails in a land unit with this in its name")
+	fs.StringVar(&query.MaintainedBefore, "maintained-before", "", "only the trails last maintained on or before this date, YYYY-MM-DD, or never")
+	fs.StringVar(&query.MaintainedAfter, "maintained-after", "", "only the trails last maintained on or after this date, YYYY-MM-DD")
+	fs.Float64Var(&query.MinSatisfaction, "min-satisfaction", 0, "only the trails with an average satisfaction of at least this, 1 to 5")
+	fs.Float64Var(&query.MaxSatisfaction, "max-satisfaction", 0, "only the trails with an average satisfaction of at most this, 1 to 5")
+	fs.StringVar(&query.Date, "date", Trail.Today(), "date to take the statuses on, YYYY-MM-DD")
+	fs.Var(&query.Sort, "sort", "order of the trails: "+Search.JoinSortKeys()+" (default: relevance with TEXT, name without)")
+	fs.BoolVar(&query.Descending, "desc", false, "list in descending order")
+	fs.IntVar(&query.Page, "page", 1, "page to list")
+	fs.IntVar(&query.PageSize, "page-size", Search.DefaultPageSize, "trails on a page")
+	format := formatFlag(fs)
+	if err := parse(fs, args, 0, math.MaxInt); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	query.Text = strings.Join(fs.Args(), " ")
+	if query.Page < 1 || query.PageSize < 1 {
+		return usagef("--page and --page-size start at 1")
+	}
+	if err := query.Validate(); err != nil {
+		return usageError{err: err}
+	}
+
+	snapshot, err := e.store.Snapshot()
+	if err != nil {
+		return err
+	}
+	page, err := Search.Trails(snapshot.Trails, snapshot.Visitors, snapshot.Maintenance, snapshot.StatusHistory, snapshot.StatusSchedule, query)
+	if err != nil {
+		return err
+	}
+
+	// Say where the page is without mixing it into the output
+	if len(page.Results) > 0 {
+		fmt.Fprintf(e.stderr, "Page %d of %d, %d trails found.\n", page.Page, page.Pages, page.Total)
+	}
+	t := table{columns: []string{"id", "name", "location", "difficulty", "length", "status", "last_maintained", "satisfaction", "visits"}, value: page}
+	for _, result := range page.Results {
+		satisfaction := ""
+		if result.Visits > 0 {
+			satisfaction = strconv.FormatFloat(result.Satisfaction, 'f', 2, 64)
+		}
+		t.add(result.Trail.ID, result.Trail.Name, result.Trail.Location.String(), string(result.Trail.Difficulty), result.Trail.Length.Format(e.config.Units),
+			string(result.Status), result.LastMaintained, satisfaction, strconv.Itoa(result.Visits))
+	}
+	return e.write(*format, t)
+}
@@ -0,0 +1,47 @@
+package CLI
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	Server "project/Server"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout bounds how long serve waits for requests in progress
+// when it is stopped
+const shutdownTimeout = 10 * time.Second
+
+func serve(e *env, fs *flag.FlagSet, args []string) error {
+	addr := fs.String("addr", ":8080", "address to listen on")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server := &http.Server{Addr: *addr, Handler: Server.New(e.store)}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	fmt.Fprintf(e.stderr, "Serving the API on %s, stop with Ctrl+C.\n", *addr)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	// Every change was journaled or committed as it was made, saving
+	// compacts the data files once the server is done
+	e.changed = true
+	fmt.Fprintln(e.stderr, "Server stopped.")
+	return err
+}
@@ -0,0 +1,106 @@
+package DataStore
+
+import (
+	"database/sql"
+	"fmt"
+	Storage "project/Storage"
+)
+
+// batch is a change to several datasets that is stored as a whole or not at
+// all. On the SQLite backend it runs in one database transaction; on the CSV
+// backend it keeps what undoes each change made so far.
+type batch struct {
+	tx   *sql.Tx
+	undo []func() error
+}
+
+// batched runs change as a batch. If it fails, the database transaction is
+// rolled back or the changes it made are undone, newest first. The caller
+// holds the lock.
+func (s *DataStore) batched(change func(b *batch) error) error {
+	b := &batch{}
+	if s.db != nil {
+		tx, err := s.db.DB.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		b.tx = tx
+		if err := change(b); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	err := change(b)
+	if err == nil {
+		return nil
+	}
+	for i := len(b.undo) - 1; i >= 0; i-- {
+		if undoErr := b.undo[i](); undoErr != nil {
+			return fmt.Errorf("%w (undoing the changes already made failed: %v)", err, undoErr)
+		}
+	}
+	return err
+}
+
+// in returns store as it reads and changes records within batch b. Every
+// dataset a batch touches must be reached through it: on the SQLite backend
+// the database takes one connection, which the transaction holds.
+func in[T any](b *batch, store Storage.Store[T]) Storage.Store[T] {
+	if table, ok := store.(*Storage.SQL[T]); ok && b.tx != nil {
+		return table.In(b.tx)
+	}
+	return undoable[T]{store, b}
+}
+
+// undoable records in its batch how to undo each change made to a store
+type undoable[T any] struct {
+	Storage.Store[T]
+	batch *batch
+}
+
+func (u undoable[T]) Create(record T) (T, error) {
+	record, err := u.Store.Create(record)
+	if err == nil {
+		u.batch.undo = append(u.batch.undo, func() error { return u.Store.Delete(u.Key(record)) })
+	}
+	return record, err
+}
+
+func (u undoable[T]) Update(key string, record T) error {
+	return u.change(key, func() error { return u.Store.Update(key, record) })
+}
+
+func (u undoable[T]) Overwrite(key string, record T) error {
+	return u.change(key, func() error { return u.Store.Overwrite(key, record) })
+}
+
+// change applies an update of the record with the given ID, keeping the
+// record as it was to put it back
+func (u undoable[T]) change(key string, apply func() error) error {
+	previous, err := u.Store.Get(key)
+	if err != nil {
+		return err
+	}
+	if err := apply(); err != nil {
+		return err
+	}
+	u.batch.undo = append(u.batch.undo, func() error { return u.Store.Overwrite(key, previous) })
+	return nil
+}
+
+func (u undoable[T]) Delete(key string) error {
+	previous, err := u.Store.Get(key)
+	if err != nil {
+		return err
+	}
+	if err := u.Store.Delete(key); err != nil {
+		return err
+	}
+	u.batch.undo = append(u.batch.undo, func() error {
+		_, err := u.Store.Create(previous)
+		return err
+	})
+	return nil
+}
@@ -0,0 +1,160 @@
+package DataStore
+
+import (
+	"fmt"
+	Trail "project/Trail"
+	Visitor "project/Visitor"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	workers = 8
+	rounds  = 15
+)
+
+// open creates a loaded, empty store of the backend that cascades trail
+// deletes and renames to the visitors
+func open(t *testing.T, backend string) *DataStore {
+	t.Helper()
+	store, err := New(Config{DataDir: t.TempDir(), Backend: backend, ReferencePolicy: Cascade, Units: Trail.Imperial})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	for _, result := range store.Load() {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+	}
+	return store
+}
+
+// checkSnapshot reports visitors that a snapshot shows without their trail
+// or under an old name of it, which means it caught a change half done
+func checkSnapshot(snapshot Snapshot) error {
+	trails, err := snapshot.Trails.List()
+	if err != nil {
+		return err
+	}
+	names := make(map[string]string)
+	for _, trail := range trails {
+		names[trail.ID] = trail.Name
+	}
+	visitors, err := snapshot.Visitors.List()
+	if err != nil {
+		return err
+	}
+	for _, visitor := range visitors {
+		if name, ok := names[visitor.TrailID]; !ok || name != visitor.Trail {
+			return fmt.Errorf("visitor %s names trail %s %q, the snapshot has %q", visitor.ID, visitor.TrailID, visitor.Trail, name)
+		}
+	}
+	return nil
+}
+
+func TestConcurrentChanges(t *testing.T) {
+	for _, backend := range []string{BackendCSV, BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			store := open(t, backend)
+			var wg sync.WaitGroup
+			// Readers take snapshots and the store is saved while the
+			// workers change it
+			for range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range rounds {
+						snapshot, err := store.Snapshot()
+						if err != nil {
+							t.Errorf("taking a snapshot: %v", err)
+							return
+						}
+						if err := checkSnapshot(snapshot); err != nil {
+							t.Error(err)
+							return
+						}
+						if _, err := store.Visitors.List(); err != nil {
+							t.Errorf("listing visitors: %v", err)
+							return
+						}
+					}
+				}()
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 3 {
+					if err := store.Save(); err != nil {
+						t.Errorf("saving: %v", err)
+						return
+					}
+				}
+			}()
+
+			for w := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range rounds {
+						trail, err := store.Trails.Create(Trail.Trail{
+							Name:          fmt.Sprintf("Trail %d.%d", w, i),
+							Location:      Trail.ParseLocation("Las Vegas, NV"),
+							Difficulty:    Trail.Moderate,
+							Length:        Trail.Distance{Value: 2.5, Unit: Trail.Mile},
+							ElevationGain: Trail.Elevation{Unit: Trail.Foot},
+							Status:        Trail.Open,
+						})
+						if err != nil {
+							t.Errorf("creating a trail: %v", err)
+							return
+						}
+						if _, err := store.Visitors.Create(Visitor.Visitor{Name: "Hiker", VisitDate: "2026-05-01", Satisfaction: "5", TrailID: trail.ID}); err != nil {
+							t.Errorf("creating a visitor of %s: %v", trail.ID, err)
+							return
+						}
+						trail, err = store.Trails.Get(trail.ID)
+						if err != nil {
+							t.Errorf("getting %s: %v", trail.ID, err)
+							return
+						}
+						// Renaming the trail renames its visitor
+						trail.Name += " renamed"
+						if err := store.Trails.Update(trail.ID, trail); err != nil {
+							t.Errorf("renaming %s: %v", trail.ID, err)
+							return
+						}
+						// Deleting it deletes the visitor
+						if i%2 == 1 {
+							if err := store.Trails.Delete(trail.ID); err != nil {
+								t.Errorf("deleting %s: %v", trail.ID, err)
+								return
+							}
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			snapshot, err := store.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := checkSnapshot(snapshot); err != nil {
+				t.Error(err)
+			}
+			trails, _ := snapshot.Trails.List()
+			visitors, _ := snapshot.Visitors.List()
+			want := workers * ((rounds + 1) / 2)
+			if len(trails) != want || len(visitors) != want {
+				t.Errorf("got %d trails and %d visitors, want %d of each", len(trails), len(visitors), want)
+			}
+			for _, trail := range trails {
+				if !strings.HasSuffix(trail.Name, " renamed") || trail.Version != 2 {
+					t.Errorf("trail %s is %q at version %d, want it renamed at version 2", trail.ID, trail.Name, trail.Version)
+				}
+			}
+		})
+	}
+}
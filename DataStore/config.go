@@ -0,0 +1,78 @@
+package DataStore
+
+import (
+	"fmt"
+	"os"
+	Trail "project/Trail"
+)
+
+// Config controls where the data store keeps its files, how it keeps
+// references between them consistent and the units lengths and elevations
+// are shown in
+type Config struct {
+	DataDir         string
+	Backend         string
+	ReferencePolicy ReferencePolicy
+	Units           Trail.Units
+}
+
+// Storage backends
+const (
+	// BackendCSV keeps each dataset in a CSV file with a change journal
+	BackendCSV = "csv"
+	// BackendSQLite keeps every dataset in the SQLite database trails.db
+	BackendSQLite = "sqlite"
+)
+
+// ReferencePolicy decides what happens to the visitor and maintenance
+// records of a trail that is deleted or renamed
+type ReferencePolicy string
+
+const (
+	// Restrict refuses to delete or rename a trail that has records
+	Restrict ReferencePolicy = "restrict"
+	// Cascade deletes the records with their trail and renames them with it
+	Cascade ReferencePolicy = "cascade"
+	// Orphan keeps the records, unlinked from a deleted trail and under the
+	// old name of a renamed one
+	Orphan ReferencePolicy = "orphan"
+)
+
+// ParseReferencePolicy parses "restrict", "cascade" or "orphan"
+func ParseReferencePolicy(s string) (ReferencePolicy, error) {
+	switch policy := ReferencePolicy(s); policy {
+	case Restrict, Cascade, Orphan:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown reference policy %q, use restrict, cascade or orphan", s)
+}
+
+// ConfigFromEnv returns the default configuration, overridden by the
+// TRAILS_DATA_DIR, TRAILS_BACKEND, TRAILS_REFERENCE_POLICY and TRAILS_UNITS
+// environment variables
+func ConfigFromEnv() (Config, error) {
+	config := Config{DataDir: "data", Backend: BackendCSV, ReferencePolicy: Restrict, Units: Trail.Imperial}
+	if dir := os.Getenv("TRAILS_DATA_DIR"); dir != "" {
+		config.DataDir = dir
+	}
+	switch backend := os.Getenv("TRAILS_BACKEND"); backend {
+	case "":
+	case BackendCSV, BackendSQLite:
+		config.Backend = backend
+	default:
+		return config, fmt.Errorf("unknown backend %q, use csv or sqlite", backend)
+	}
+	if policy := os.Getenv("TRAILS_REFERENCE_POLICY"); policy != "" {
+		var err error
+		if config.ReferencePolicy, err = ParseReferencePolicy(policy); err != nil {
+			return config, err
+		}
+	}
+	if units := os.Getenv("TRAILS_UNITS"); units != "" {
+		var err error
+		if config.Units, err = Trail.ParseUnits(units); err != nil {
+			return config, err
+		}
+	}
+	return config, nil
+}
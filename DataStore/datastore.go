@@ -0,0 +1,401 @@
+package DataStore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	Maintenance "project/Maintenance"
+	Network "project/Network"
+	Storage "project/Storage"
+	Trail "project/Trail"
+	Visitor "project/Visitor"
+	"project/utils"
+	"sync"
+	"time"
+
+	// Registers the pure Go "sqlite" database/sql driver
+	_ "modernc.org/sqlite"
+)
+
+// DataStore owns the trail, visitor, maintenance, maintenance plan, status
+// history, status schedule, track, junction and segment datasets and is
+// responsible for loading, validating and saving them. Its repositories keep
+// the trail references of the records valid, and change the status of a
+// trail only along with its history. It is safe for concurrent use.
+type DataStore struct {
+	config      Config
+	mu          sync.RWMutex
+	Trails      Trail.Repository
+	Visitors    Visitor.Repository
+	Maintenance Maintenance.Repository
+	// Plans holds the recurring maintenance of the trails, which generates
+	// work orders. A trail's plans are deleted with it.
+	Plans Maintenance.PlanRepository
+	// StatusHistory records the status changes of the trails. Creating a
+	// status change changes the status of its trail; recorded changes
+	// cannot be edited.
+	StatusHistory Trail.HistoryRepository
+	// StatusSchedule holds the statuses planned for trails over a period
+	StatusSchedule Trail.ScheduleRepository
+	// Tracks holds the recorded geometry of the trails, one per trail
+	Tracks Trail.TrackRepository
+	// Junctions and Segments make up the trail network. A junction cannot
+	// be deleted while segments meet at it; a trail's segments are deleted
+	// with it.
+	Junctions Network.JunctionRepository
+	Segments  Network.SegmentRepository
+
+	trailStore       Storage.Store[Trail.Trail]
+	visitorStore     Storage.Store[Visitor.Visitor]
+	maintenanceStore Storage.Store[Maintenance.Maintenance]
+	planStore        Storage.Store[Maintenance.Plan]
+	historyStore     Storage.Store[Trail.StatusChange]
+	scheduleStore    Storage.Store[Trail.ScheduledChange]
+	trackStore       Storage.Store[Trail.Track]
+	junctionStore    Storage.Store[Network.Junction]
+	segmentStore     Storage.Store[Network.Segment]
+	db               *Storage.Database // set for the SQLite backend
+}
+
+// dataset is one of the stored datasets
+type dataset struct {
+	store interface {
+		Path() string
+		Load() (Storage.LoadResult, error)
+		PlanMigration() (Storage.MigrationPlan, error)
+		Save() error
+	}
+	validate func() []string
+}
+
+// LoadResult reports the outcome of loading one dataset
+type LoadResult struct {
+	File string
+	Storage.LoadResult
+	Invalid []string // loaded records that fail validation
+	Linked  int      // records whose trail reference was filled in by name
+	Err     error
+}
+
+// Backup is a backup of one of the data files
+type Backup struct {
+	File  string
+	Path  string
+	Taken time.Time
+}
+
+// New creates a data store for config.DataDir using the configured
+// backend. Call Load to read the data.
+func New(config Config) (*DataStore, error) {
+	s := &DataStore{config: config}
+	switch config.Backend {
+	case BackendCSV:
+		s.trailStore = Trail.NewCSVRepository(filepath.Join(config.DataDir, "trails.csv"))
+		s.visitorStore = Visitor.NewCSVRepository(filepath.Join(config.DataDir, "visitors.csv"))
+		s.maintenanceStore = Maintenance.NewCSVRepository(filepath.Join(config.DataDir, "maintenance.csv"))
+		s.planStore = Maintenance.NewCSVPlanRepository(filepath.Join(config.DataDir, "maintenance_plans.csv"))
+		s.historyStore = Trail.NewCSVHistoryRepository(filepath.Join(config.DataDir, "status_history.csv"))
+		s.scheduleStore = Trail.NewCSVScheduleRepository(filepath.Join(config.DataDir, "status_schedule.csv"))
+		s.trackStore = Trail.NewCSVTrackRepository(filepath.Join(config.DataDir, "tracks.csv"))
+		s.junctionStore = Network.NewCSVJunctionRepository(filepath.Join(config.DataDir, "junctions.csv"))
+		s.segmentStore = Network.NewCSVSegmentRepository(filepath.Join(config.DataDir, "segments.csv"))
+	case BackendSQLite:
+		if err := os.MkdirAll(config.DataDir, 0o755); err != nil {
+			return nil, err
+		}
+		db, err := Storage.OpenDatabase("sqlite", filepath.Join(config.DataDir, "trails.db"))
+		if err != nil {
+			return nil, err
+		}
+		s.db = db
+		s.trailStore = Trail.NewSQLRepository(db)
+		s.visitorStore = Visitor.NewSQLRepository(db)
+		s.maintenanceStore = Maintenance.NewSQLRepository(db)
+		s.planStore = Maintenance.NewSQLPlanRepository(db)
+		s.historyStore = Trail.NewSQLHistoryRepository(db)
+		s.scheduleStore = Trail.NewSQLScheduleRepository(db)
+		s.trackStore = Trail.NewSQLTrackRepository(db)
+		s.junctionStore = Network.NewSQLJunctionRepository(db)
+		s.segmentStore = Network.NewSQLSegmentRepository(db)
+	default:
+		return nil, fmt.Errorf("unknown backend %q", config.Backend)
+	}
+
+	s.Trails = locked[Trail.Trail]{trailRepository{s.trailStore, s}, &s.mu}
+	s.Visitors = locked[Visitor.Visitor]{visitorRepository{s.visitorStore, s}, &s.mu}
+	s.Maintenance = locked[Maintenance.Maintenance]{maintenanceRepository{s.maintenanceStore, s}, &s.mu}
+	s.Plans = locked[Maintenance.Plan]{planRepository{s.planStore, s}, &s.mu}
+	s.StatusHistory = locked[Trail.StatusChange]{historyRepository{s.historyStore, s}, &s.mu}
+	s.StatusSchedule = locked[Trail.ScheduledChange]{scheduleRepository{s.scheduleStore, s}, &s.mu}
+	s.Tracks = locked[Trail.Track]{trackRepository{s.trackStore, s}, &s.mu}
+	s.Junctions = locked[Network.Junction]{junctionRepository{s.junctionStore, s}, &s.mu}
+	s.Segments = locked[Network.Segment]{segmentRepository{s.segmentStore, s}, &s.mu}
+	return s, nil
+}
+
+// Config returns the configuration the store was created with
+func (s *DataStore) Config() Config {
+	return s.config
+}
+
+// Close releases the database of the SQLite backend
+func (s *DataStore) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+func (s *DataStore) datasets() []dataset {
+	return []dataset{
+		{s.trailStore, func() []string {
+			return invalid(s.trailStore, func(t Trail.Trail) string {
+				return fmt.Sprintf("trail '%s' at '%s'", t.Name, t.Location)
+			})
+		}},
+		{s.visitorStore, func() []string {
+			return invalid(s.visitorStore, func(v Visitor.Visitor) string {
+				return fmt.Sprintf("visitor '%s' on %s", v.Name, v.VisitDate)
+			})
+		}},
+		{s.maintenanceStore, func() []string {
+			return invalid(s.maintenanceStore, func(m Maintenance.Maintenance) string {
+				return fmt.Sprintf("maintenance on '%s' on %s", m.TrailName, m.Date)
+			})
+		}},
+		{s.planStore, func() []string {
+			return invalid(s.planStore, func(p Maintenance.Plan) string {
+				return fmt.Sprintf("%s plan of trail %s", p.Type, p.TrailID)
+			})
+		}},
+		{s.historyStore, func() []string {
+			return invalid(s.historyStore, func(c Trail.StatusChange) string {
+				return fmt.Sprintf("status change of trail %s on %s", c.TrailID, c.EffectiveDate)
+			})
+		}},
+		{s.scheduleStore, func() []string {
+			return invalid(s.scheduleStore, func(c Trail.ScheduledChange) string {
+				return fmt.Sprintf("scheduled change of trail %s from %s", c.TrailID, c.Start)
+			})
+		}},
+		{s.trackStore, func() []string {
+			return invalid(s.trackStore, func(t Trail.Track) string {
+				return fmt.Sprintf("track of trail %s", t.TrailID)
+			})
+		}},
+		{s.junctionStore, func() []string {
+			return invalid(s.junctionStore, func(j Network.Junction) string {
+				return fmt.Sprintf("junction '%s'", j.Name)
+			})
+		}},
+		{s.segmentStore, func() []string {
+			return invalid(s.segmentStore, func(seg Network.Segment) string {
+				return fmt.Sprintf("segment of trail %s from %s to %s", seg.TrailID, seg.From, seg.To)
+			})
+		}},
+	}
+}
+
+// files returns the files the data is kept in
+func (s *DataStore) files() []string {
+	if s.db != nil {
+		return []string{s.db.Path}
+	}
+	var files []string
+	for _, data := range s.datasets() {
+		files = append(files, data.store.Path())
+	}
+	return files
+}
+
+// Load reads every dataset, replaying unsaved journal entries and migrating
+// old schemas, and validates the records it read
+func (s *DataStore) Load() []LoadResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadAll()
+}
+
+func (s *DataStore) loadAll() []LoadResult {
+	if err := os.MkdirAll(s.config.DataDir, 0o755); err != nil {
+		return []LoadResult{{File: s.config.DataDir, Err: err}}
+	}
+	var results []LoadResult
+	for _, data := range s.datasets() {
+		results = append(results, s.load(data))
+	}
+	s.linkTrails(results)
+	return results
+}
+
+func (s *DataStore) load(data dataset) LoadResult {
+	result := LoadResult{File: data.store.Path()}
+	result.LoadResult, result.Err = data.store.Load()
+	if result.Err == nil {
+		result.Invalid = data.validate()
+	}
+	return result
+}
+
+// linkTrails fills in the trail ID of visitor and maintenance records
+// written before records had IDs, matching them to the only trail with the
+// name they mention
+func (s *DataStore) linkTrails(results []LoadResult) {
+	trails, err := s.trailStore.List()
+	if err != nil {
+		return
+	}
+	byName := make(map[string][]Trail.Trail)
+	for _, trail := range trails {
+		byName[trail.Name] = append(byName[trail.Name], trail)
+	}
+	trailID := func(name string) string {
+		if named := byName[name]; len(named) == 1 {
+			return named[0].ID
+		}
+		return ""
+	}
+
+	for i := range results {
+		result := &results[i]
+		if result.Err != nil {
+			continue
+		}
+		switch result.File {
+		case s.visitorStore.Path():
+			result.Linked, result.Err = link(s.visitorStore, func(v Visitor.Visitor) (Visitor.Visitor, bool) {
+				if v.TrailID != "" {
+					return v, false
+				}
+				v.TrailID = trailID(v.Trail)
+				return v, v.TrailID != ""
+			})
+		case s.maintenanceStore.Path():
+			result.Linked, result.Err = link(s.maintenanceStore, func(m Maintenance.Maintenance) (Maintenance.Maintenance, bool) {
+				if m.TrailID != "" {
+					return m, false
+				}
+				m.TrailID = trailID(m.TrailName)
+				return m, m.TrailID != ""
+			})
+		}
+	}
+}
+
+// link updates the records that fill links to a trail, then saves the
+// store so the links are part of the snapshot
+func link[T any](store Storage.Store[T], fill func(T) (T, bool)) (int, error) {
+	records, err := store.List()
+	if err != nil {
+		return 0, err
+	}
+	linked := 0
+	for _, record := range records {
+		if record, ok := fill(record); ok {
+			if err := store.Update(store.Key(record), record); err != nil {
+				return linked, err
+			}
+			linked++
+		}
+	}
+	if linked == 0 {
+		return 0, nil
+	}
+	return linked, store.Save()
+}
+
+// invalid lists the records in repo that fail validation
+func invalid[T interface{ Validate() error }](repo Storage.Repository[T], describe func(T) string) []string {
+	records, err := repo.List()
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var problems []string
+	for _, record := range records {
+		if err := record.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", describe(record), err))
+		}
+	}
+	return problems
+}
+
+// PlanMigrations reports how each dataset would be migrated to the current
+// schema, without loading or changing anything
+func (s *DataStore) PlanMigrations() ([]Storage.MigrationPlan, error) {
+	var plans []Storage.MigrationPlan
+	for _, data := range s.datasets() {
+		plan, err := data.store.PlanMigration()
+		if err != nil {
+			return plans, fmt.Errorf("%s: %w", data.store.Path(), err)
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// Save writes every dataset and clears the journals. The SQLite backend
+// commits every change as it is made, so saving only takes a backup of the
+// database.
+func (s *DataStore) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for _, data := range s.datasets() {
+		if err := data.store.Save(); err != nil {
+			errs = append(errs, fmt.Errorf("saving %s: %w", data.store.Path(), err))
+		}
+	}
+	if s.db != nil {
+		if err := utils.BackupFile(s.db.Path); err != nil {
+			errs = append(errs, fmt.Errorf("backing up %s: %w", s.db.Path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Backups lists the backups of every data file, newest first per file
+func (s *DataStore) Backups() ([]Backup, error) {
+	var backups []Backup
+	for _, file := range s.files() {
+		paths, err := utils.ListBackups(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			taken, _ := utils.BackupTime(file, path)
+			backups = append(backups, Backup{File: file, Path: path, Taken: taken})
+		}
+	}
+	return backups, nil
+}
+
+// Restore replaces a data file with one of its backups and reloads the
+// datasets kept in it
+func (s *DataStore) Restore(backup Backup) []LoadResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db != nil && backup.File == s.db.Path {
+		s.db.Close()
+		err := utils.RestoreBackup(backup.Path, s.db.Path)
+		// Reopen even if the restore failed so the store stays usable
+		if reopenErr := s.db.Reopen(); err == nil {
+			err = reopenErr
+		}
+		if err != nil {
+			return []LoadResult{{File: backup.File, Err: err}}
+		}
+		return s.loadAll()
+	}
+
+	for _, data := range s.datasets() {
+		if data.store.Path() != backup.File {
+			continue
+		}
+		if err := utils.RestoreBackup(backup.Path, backup.File); err != nil {
+			return []LoadResult{{File: backup.File, Err: err}}
+		}
+		return []LoadResult{s.load(data)}
+	}
+	return []LoadResult{{File: backup.File, Err: fmt.Errorf("%s is not a data file", backup.File)}}
+}
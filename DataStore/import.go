@@ -0,0 +1,120 @@
+package DataStore
+
+import (
+	"fmt"
+	Maintenance "project/Maintenance"
+	Network "project/Network"
+	Trail "project/Trail"
+	Visitor "project/Visitor"
+)
+
+// ImportResult reports how many records ImportCSV copied
+type ImportResult struct {
+	Trails, Visitors, Maintenance, Plans, StatusHistory, StatusSchedule, Tracks, Junctions, Segments int
+}
+
+// ImportCSV copies the records of the CSV files in config.DataDir into the
+// SQLite database there, in a single transaction. The CSV files are loaded
+// the same way the CSV backend loads them, so they are migrated first. The
+// import fails without changing the database if any record already exists
+// in it.
+func ImportCSV(config Config) (ImportResult, []LoadResult, error) {
+	var imported ImportResult
+	config.Backend = BackendCSV
+	source, err := New(config)
+	if err != nil {
+		return imported, nil, err
+	}
+	results := source.Load()
+	for _, result := range results {
+		if result.Err != nil {
+			return imported, results, fmt.Errorf("loading %s: %w", result.File, result.Err)
+		}
+	}
+
+	config.Backend = BackendSQLite
+	target, err := New(config)
+	if err != nil {
+		return imported, results, err
+	}
+	defer target.Close()
+	for _, result := range target.Load() {
+		if result.Err != nil {
+			return imported, results, fmt.Errorf("loading %s: %w", result.File, result.Err)
+		}
+	}
+
+	trails, err := source.trailStore.List()
+	if err != nil {
+		return imported, results, err
+	}
+	visitors, err := source.visitorStore.List()
+	if err != nil {
+		return imported, results, err
+	}
+	records, err := source.maintenanceStore.List()
+	if err != nil {
+		return imported, results, err
+	}
+	plans, err := source.planStore.List()
+	if err != nil {
+		return imported, results, err
+	}
+	history, err := source.historyStore.List()
+	if err != nil {
+		return imported, results, err
+	}
+	schedule, err := source.scheduleStore.List()
+	if err != nil {
+		return imported, results, err
+	}
+	tracks, err := source.trackStore.List()
+	if err != nil {
+		return imported, results, err
+	}
+	junctions, err := source.junctionStore.List()
+	if err != nil {
+		return imported, results, err
+	}
+	segments, err := source.segmentStore.List()
+	if err != nil {
+		return imported, results, err
+	}
+
+	tx, err := target.db.DB.Begin()
+	if err != nil {
+		return imported, results, err
+	}
+	defer tx.Rollback()
+	if err := target.trailStore.(*Trail.SQLRepository).Import(tx, trails); err != nil {
+		return imported, results, err
+	}
+	if err := target.visitorStore.(*Visitor.SQLRepository).Import(tx, visitors); err != nil {
+		return imported, results, err
+	}
+	if err := target.maintenanceStore.(*Maintenance.SQLRepository).Import(tx, records); err != nil {
+		return imported, results, err
+	}
+	if err := target.planStore.(*Maintenance.SQLPlanRepository).Import(tx, plans); err != nil {
+		return imported, results, err
+	}
+	if err := target.historyStore.(*Trail.SQLHistoryRepository).Import(tx, history); err != nil {
+		return imported, results, err
+	}
+	if err := target.scheduleStore.(*Trail.SQLScheduleRepository).Import(tx, schedule); err != nil {
+		return imported, results, err
+	}
+	if err := target.trackStore.(*Trail.SQLTrackRepository).Import(tx, tracks); err != nil {
+		return imported, results, err
+	}
+	if err := target.junctionStore.(*Network.SQLJunctionRepository).Import(tx, junctions); err != nil {
+		return imported, results, err
+	}
+	if err := target.segmentStore.(*Network.SQLSegmentRepository).Import(tx, segments); err != nil {
+		return imported, results, err
+	}
+	if err := tx.Commit(); err != nil {
+		return imported, results, err
+	}
+	return ImportResult{Trails: len(trails), Visitors: len(visitors), Maintenance: len(records), Plans: len(plans), StatusHistory: len(history), StatusSchedule: len(schedule), Tracks: len(tracks), Junctions: len(junctions), Segments: len(segments)}, results, nil
+}
@@ -0,0 +1,114 @@
+package DataStore
+
+import (
+	"fmt"
+	Trail "project/Trail"
+)
+
+// Issues reported by CheckIntegrity
+const (
+	IssueUnlinked  = "not linked to a trail"
+	IssueDangling  = "links to a trail that does not exist"
+	IssueStaleName = "has a trail name that differs from its trail"
+)
+
+// Entities that reference trails
+const (
+	EntityVisitor     = "visitor"
+	EntityMaintenance = "maintenance"
+)
+
+// Problem is a visitor or maintenance record with a broken trail reference
+type Problem struct {
+	Entity    string `json:"entity"`
+	ID        string `json:"id"`
+	TrailID   string `json:"trail_id"`
+	TrailName string `json:"trail_name"`
+	Issue     string `json:"issue"`
+	// Candidates are the trails the record can be linked to
+	Candidates []Trail.Trail `json:"candidates,omitempty"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s record %s for trail '%s' %s", p.Entity, p.ID, p.TrailName, p.Issue)
+}
+
+// CheckIntegrity lists the visitor and maintenance records whose trail
+// reference is missing, dangling or out of date
+func (s *DataStore) CheckIntegrity() ([]Problem, error) {
+	s.mu.RLock()
+	all, err := s.records()
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	trails, visitors, records := all.Trails, all.Visitors, all.Maintenance
+
+	byID := make(map[string]Trail.Trail)
+	for _, trail := range trails {
+		byID[trail.ID] = trail
+	}
+	check := func(entity, id, trailID, trailName string) *Problem {
+		problem := Problem{Entity: entity, ID: id, TrailID: trailID, TrailName: trailName}
+		trail, ok := byID[trailID]
+		switch {
+		case trailID == "":
+			problem.Issue = IssueUnlinked
+		case !ok:
+			problem.Issue = IssueDangling
+		case trail.Name != trailName:
+			problem.Issue = IssueStaleName
+			problem.Candidates = []Trail.Trail{trail}
+			return &problem
+		default:
+			return nil
+		}
+		problem.Candidates, _ = Trail.Match(trails, trailName)
+		return &problem
+	}
+
+	var problems []Problem
+	for _, visitor := range visitors {
+		if problem := check(EntityVisitor, visitor.ID, visitor.TrailID, visitor.Trail); problem != nil {
+			problems = append(problems, *problem)
+		}
+	}
+	for _, record := range records {
+		if problem := check(EntityMaintenance, record.ID, record.TrailID, record.TrailName); problem != nil {
+			problems = append(problems, *problem)
+		}
+	}
+	return problems, nil
+}
+
+// Relink repairs a problem by pointing the record at trail
+func (s *DataStore) Relink(problem Problem, trail Trail.Trail) error {
+	switch problem.Entity {
+	case EntityVisitor:
+		visitor, err := s.Visitors.Get(problem.ID)
+		if err != nil {
+			return err
+		}
+		visitor.TrailID, visitor.Trail = trail.ID, trail.Name
+		return s.Visitors.Update(visitor.ID, visitor)
+	case EntityMaintenance:
+		record, err := s.Maintenance.Get(problem.ID)
+		if err != nil {
+			return err
+		}
+		record.TrailID, record.TrailName = trail.ID, trail.Name
+		return s.Maintenance.Update(record.ID, record)
+	}
+	return fmt.Errorf("unknown entity %q", problem.Entity)
+}
+
+// DeleteRecord repairs a problem by deleting the record
+func (s *DataStore) DeleteRecord(problem Problem) error {
+	switch problem.Entity {
+	case EntityVisitor:
+		return s.Visitors.Delete(problem.ID)
+	case EntityMaintenance:
+		return s.Maintenance.Delete(problem.ID)
+	}
+	return fmt.Errorf("unknown entity %q", problem.Entity)
+}
@@ -0,0 +1,620 @@
+package DataStore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	Maintenance "project/Maintenance"
+	Network "project/Network"
+	Storage "project/Storage"
+	Trail "project/Trail"
+	Visitor "project/Visitor"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Datasets that can be exported and imported on their own
+const (
+	DatasetTrails         = "trails"
+	DatasetVisitors       = "visitors"
+	DatasetMaintenance    = "maintenance"
+	DatasetPlans          = "maintenance_plans"
+	DatasetStatusHistory  = "status_history"
+	DatasetStatusSchedule = "status_schedule"
+	DatasetTracks         = "tracks"
+	DatasetJunctions      = "junctions"
+	DatasetSegments       = "segments"
+)
+
+// Datasets lists the dataset names in the order they are stored
+var Datasets = []string{DatasetTrails, DatasetVisitors, DatasetMaintenance, DatasetPlans, DatasetStatusHistory, DatasetStatusSchedule, DatasetTracks, DatasetJunctions, DatasetSegments}
+
+// ImportMode decides what an import does with the records already stored
+type ImportMode string
+
+const (
+	// Replace removes the stored records of every imported dataset and
+	// stores the imported ones instead
+	Replace ImportMode = "replace"
+	// Merge updates the stored records that share an ID with an imported
+	// record, creates the others and keeps the rest
+	Merge ImportMode = "merge"
+)
+
+// Records holds every dataset. It is the JSON document written by
+// ExportJSON when no dataset is selected.
+type Records struct {
+	Trails         []Trail.Trail             `json:"trails"`
+	Visitors       []Visitor.Visitor         `json:"visitors"`
+	Maintenance    []Maintenance.Maintenance `json:"maintenance"`
+	Plans          []Maintenance.Plan        `json:"maintenance_plans"`
+	StatusHistory  []Trail.StatusChange      `json:"status_history"`
+	StatusSchedule []Trail.ScheduledChange   `json:"status_schedule"`
+	Tracks         []Trail.Track             `json:"tracks"`
+	Junctions      []Network.Junction        `json:"junctions"`
+	Segments       []Network.Segment         `json:"segments"`
+}
+
+// Changes counts the records an import changed in one dataset
+type Changes struct {
+	Created, Updated, Deleted int
+}
+
+// JSONImportResult reports what ImportJSON changed in each dataset
+type JSONImportResult struct {
+	// Imported lists the datasets read from the JSON, in the order of
+	// Datasets. Other datasets can change as well, when their records
+	// follow a trail the import renames or removes.
+	Imported []string
+
+	Trails, Visitors, Maintenance, Plans, StatusHistory, StatusSchedule, Tracks, Junctions, Segments Changes
+}
+
+// ExportJSON writes the records of dataset to w as a JSON array, or every
+// dataset as a Records document when dataset is empty
+func (s *DataStore) ExportJSON(w io.Writer, dataset string) error {
+	s.mu.RLock()
+	records, err := s.records()
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	// Export empty datasets as [] rather than null
+	records.Trails = append([]Trail.Trail{}, records.Trails...)
+	records.Visitors = append([]Visitor.Visitor{}, records.Visitors...)
+	records.Maintenance = append([]Maintenance.Maintenance{}, records.Maintenance...)
+	records.Plans = append([]Maintenance.Plan{}, records.Plans...)
+	records.StatusHistory = append([]Trail.StatusChange{}, records.StatusHistory...)
+	records.StatusSchedule = append([]Trail.ScheduledChange{}, records.StatusSchedule...)
+	records.Tracks = append([]Trail.Track{}, records.Tracks...)
+	records.Junctions = append([]Network.Junction{}, records.Junctions...)
+	records.Segments = append([]Network.Segment{}, records.Segments...)
+
+	var document any
+	switch dataset {
+	case "":
+		document = records
+	case DatasetTrails:
+		document = records.Trails
+	case DatasetVisitors:
+		document = records.Visitors
+	case DatasetMaintenance:
+		document = records.Maintenance
+	case DatasetPlans:
+		document = records.Plans
+	case DatasetStatusHistory:
+		document = records.StatusHistory
+	case DatasetStatusSchedule:
+		document = records.StatusSchedule
+	case DatasetTracks:
+		document = records.Tracks
+	case DatasetJunctions:
+		document = records.Junctions
+	case DatasetSegments:
+		document = records.Segments
+	default:
+		return unknownDataset(dataset)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
+
+// ImportJSON reads records written by ExportJSON, or by anyone following
+// the same format, and stores them. dataset selects the JSON array of one
+// dataset; when it is empty r holds a Records document and the datasets
+// present in it are imported.
+//
+// Imported records are checked with the same rules as records entered in
+// the menus. Records without an ID get a new one, and visitor and
+// maintenance records without a trail ID are linked to the only trail with
+// the name they mention. Maintenance plans, status changes, scheduled
+// changes, tracks and segments must give the ID of their trail, and
+// segments those of their junctions; status changes are stored as they
+// are, without changing the trail's status, and tracks without changing
+// the trail's length. Imported records replace the stored ones whatever
+// version they give.
+//
+// The import keeps references valid. A trail that stored records still
+// reference cannot be removed, though its maintenance plans, status
+// history, schedule, track and segments are removed with it. A junction
+// that segments meet at cannot be removed either.
+//
+// Every record is checked before anything is stored, and if any fails the
+// check nothing is stored and the error lists every failure. The records
+// are then stored as a whole: on the SQLite backend in one transaction, on
+// the CSV backend by undoing the changes already made if one fails. Only
+// if undoing fails too, which the error reports, is part of the import
+// left stored.
+func (s *DataStore) ImportJSON(r io.Reader, dataset string, mode ImportMode) (JSONImportResult, error) {
+	var result JSONImportResult
+	if mode != Replace && mode != Merge {
+		return result, fmt.Errorf("unknown import mode %q", mode)
+	}
+	imported, present, err := decodeRecords(r, dataset)
+	if err != nil {
+		return result, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, err := s.records()
+	if err != nil {
+		return result, err
+	}
+
+	// Apply the import to in-memory copies first, so that nothing is
+	// stored unless every record can be
+	trails := Trail.NewMemoryRepository(current.Trails...)
+	visitors := Visitor.NewMemoryRepository(current.Visitors...)
+	maintenance := Maintenance.NewMemoryRepository(current.Maintenance...)
+	plans := Maintenance.NewMemoryPlanRepository(current.Plans...)
+	history := Trail.NewMemoryHistoryRepository(current.StatusHistory...)
+	schedule := Trail.NewMemoryScheduleRepository(current.StatusSchedule...)
+	tracks := Trail.NewMemoryTrackRepository(current.Tracks...)
+	junctions := Network.NewMemoryJunctionRepository(current.Junctions...)
+	segments := Network.NewMemorySegmentRepository(current.Segments...)
+
+	var problems []error
+	if present[DatasetTrails] {
+		storedTrails := make(map[string]Trail.Trail)
+		for _, trail := range current.Trails {
+			storedTrails[trail.ID] = trail
+		}
+		problems = append(problems, stage(trails, imported.Trails, mode, DatasetTrails, func(t *Trail.Trail) error {
+			t.Version = storedTrails[t.ID].Version
+			return nil
+		})...)
+	}
+	staged, _ := trails.List()
+	link := linker(staged)
+	// Records that were already dangling can be imported unchanged, the
+	// integrity check reports them
+	storedVisitors := make(map[string]Visitor.Visitor)
+	for _, visitor := range current.Visitors {
+		storedVisitors[visitor.ID] = visitor
+	}
+	storedMaintenance := make(map[string]Maintenance.Maintenance)
+	for _, record := range current.Maintenance {
+		storedMaintenance[record.ID] = record
+	}
+	if present[DatasetVisitors] {
+		problems = append(problems, stage(visitors, imported.Visitors, mode, DatasetVisitors, func(v *Visitor.Visitor) error {
+			stored, ok := storedVisitors[v.ID]
+			v.Version = stored.Version
+			if ok && stored.TrailID == v.TrailID && stored.Trail == v.Trail {
+				return nil
+			}
+			return link(&v.TrailID, &v.Trail)
+		})...)
+	}
+	if present[DatasetMaintenance] {
+		problems = append(problems, stage(maintenance, imported.Maintenance, mode, DatasetMaintenance, func(m *Maintenance.Maintenance) error {
+			// Records exported before they were work orders are of work
+			// already done
+			if m.Status == "" {
+				m.Status = Maintenance.Done
+			}
+			if m.Priority == "" {
+				m.Priority = Maintenance.Normal
+			}
+			stored, ok := storedMaintenance[m.ID]
+			m.Version = stored.Version
+			if ok && stored.TrailID == m.TrailID && stored.TrailName == m.TrailName {
+				return nil
+			}
+			return link(&m.TrailID, &m.TrailName)
+		})...)
+	}
+	trailExists := func(trailID string) error {
+		if !slices.ContainsFunc(staged, func(t Trail.Trail) bool { return t.ID == trailID }) {
+			return &Storage.RecordError{Entity: "trail", Key: trailID, Err: Storage.ErrInvalidReference}
+		}
+		return nil
+	}
+	if present[DatasetPlans] {
+		storedPlans := make(map[string]Maintenance.Plan)
+		for _, plan := range current.Plans {
+			storedPlans[plan.ID] = plan
+		}
+		problems = append(problems, stage(plans, imported.Plans, mode, DatasetPlans, func(p *Maintenance.Plan) error {
+			p.Version = storedPlans[p.ID].Version
+			return trailExists(p.TrailID)
+		})...)
+	}
+	if present[DatasetStatusHistory] {
+		problems = append(problems, stage(history, imported.StatusHistory, mode, DatasetStatusHistory, func(c *Trail.StatusChange) error {
+			return trailExists(c.TrailID)
+		})...)
+	}
+	if present[DatasetStatusSchedule] {
+		storedSchedule := make(map[string]Trail.ScheduledChange)
+		for _, change := range current.StatusSchedule {
+			storedSchedule[change.ID] = change
+		}
+		problems = append(problems, stage(schedule, imported.StatusSchedule, mode, DatasetStatusSchedule, func(c *Trail.ScheduledChange) error {
+			c.Version = storedSchedule[c.ID].Version
+			return trailExists(c.TrailID)
+		})...)
+	}
+	if present[DatasetTracks] {
+		problems = append(problems, stage(tracks, imported.Tracks, mode, DatasetTracks, func(t *Trail.Track) error {
+			return trailExists(t.TrailID)
+		})...)
+	}
+	if present[DatasetJunctions] {
+		storedJunctions := make(map[string]Network.Junction)
+		for _, junction := range current.Junctions {
+			storedJunctions[junction.ID] = junction
+		}
+		problems = append(problems, stage(junctions, imported.Junctions, mode, DatasetJunctions, func(j *Network.Junction) error {
+			j.Version = storedJunctions[j.ID].Version
+			return nil
+		})...)
+	}
+	stagedJunctions, _ := junctions.List()
+	junctionIDs := make(map[string]bool)
+	for _, junction := range stagedJunctions {
+		junctionIDs[junction.ID] = true
+	}
+	if present[DatasetSegments] {
+		storedSegments := make(map[string]Network.Segment)
+		for _, segment := range current.Segments {
+			storedSegments[segment.ID] = segment
+		}
+		problems = append(problems, stage(segments, imported.Segments, mode, DatasetSegments, func(seg *Network.Segment) error {
+			seg.Version = storedSegments[seg.ID].Version
+			if err := trailExists(seg.TrailID); err != nil {
+				return err
+			}
+			for _, id := range []string{seg.From, seg.To} {
+				if !junctionIDs[id] && !slices.ContainsFunc(current.Junctions, func(j Network.Junction) bool { return j.ID == id }) {
+					return &Storage.RecordError{Entity: "junction", Key: id, Err: Storage.ErrInvalidReference}
+				}
+			}
+			return nil
+		})...)
+	}
+	followed, removed := followTrails(current.Trails, staged, visitors, maintenance)
+	problems = append(problems, followed...)
+	// The maintenance plans, status history, schedule, track and segments
+	// of a removed trail are removed with it
+	dropRemoved(plans, removed, func(p Maintenance.Plan) (string, string) { return p.ID, p.TrailID })
+	dropRemoved(history, removed, func(c Trail.StatusChange) (string, string) { return c.ID, c.TrailID })
+	dropRemoved(schedule, removed, func(c Trail.ScheduledChange) (string, string) { return c.ID, c.TrailID })
+	dropRemoved(tracks, removed, func(t Trail.Track) (string, string) { return t.ID, t.TrailID })
+	dropRemoved(segments, removed, func(s Network.Segment) (string, string) { return s.ID, s.TrailID })
+	// A junction the remaining segments meet at cannot be removed
+	stagedSegments, _ := segments.List()
+	for _, segment := range stagedSegments {
+		for _, id := range []string{segment.From, segment.To} {
+			if !junctionIDs[id] {
+				problems = append(problems, fmt.Errorf("segment %s references junction %s, which the import removes", segment.ID, id))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return result, fmt.Errorf("%d records cannot be imported:\n%w", len(problems), errors.Join(problems...))
+	}
+
+	// Remove records before storing the imported ones, so that they do not
+	// clash with the records they replace
+	trailChanges := diff(s.trailStore, current.Trails, staged)
+	stagedVisitors, _ := visitors.List()
+	visitorChanges := diff(s.visitorStore, current.Visitors, stagedVisitors)
+	stagedMaintenance, _ := maintenance.List()
+	maintenanceChanges := diff(s.maintenanceStore, current.Maintenance, stagedMaintenance)
+	stagedPlans, _ := plans.List()
+	planChanges := diff(s.planStore, current.Plans, stagedPlans)
+	stagedHistory, _ := history.List()
+	historyChanges := diff(s.historyStore, current.StatusHistory, stagedHistory)
+	stagedSchedule, _ := schedule.List()
+	scheduleChanges := diff(s.scheduleStore, current.StatusSchedule, stagedSchedule)
+	stagedTracks, _ := tracks.List()
+	trackChanges := diff(s.trackStore, current.Tracks, stagedTracks)
+	junctionChanges := diff(s.junctionStore, current.Junctions, stagedJunctions)
+	segmentChanges := diff(s.segmentStore, current.Segments, stagedSegments)
+	// The removals and stores are made as a whole or not at all
+	err = s.batched(func(b *batch) error {
+		for _, step := range []func() error{
+			func() error { return visitorChanges.remove(in(b, s.visitorStore)) },
+			func() error { return maintenanceChanges.remove(in(b, s.maintenanceStore)) },
+			func() error { return planChanges.remove(in(b, s.planStore)) },
+			func() error { return historyChanges.remove(in(b, s.historyStore)) },
+			func() error { return scheduleChanges.remove(in(b, s.scheduleStore)) },
+			func() error { return trackChanges.remove(in(b, s.trackStore)) },
+			func() error { return segmentChanges.remove(in(b, s.segmentStore)) },
+			func() error { return junctionChanges.remove(in(b, s.junctionStore)) },
+			func() error { return trailChanges.remove(in(b, s.trailStore)) },
+			func() error { return trailChanges.store(in(b, s.trailStore)) },
+			func() error { return visitorChanges.store(in(b, s.visitorStore)) },
+			func() error { return maintenanceChanges.store(in(b, s.maintenanceStore)) },
+			func() error { return planChanges.store(in(b, s.planStore)) },
+			func() error { return historyChanges.store(in(b, s.historyStore)) },
+			func() error { return scheduleChanges.store(in(b, s.scheduleStore)) },
+			func() error { return trackChanges.store(in(b, s.trackStore)) },
+			func() error { return junctionChanges.store(in(b, s.junctionStore)) },
+			func() error { return segmentChanges.store(in(b, s.segmentStore)) },
+		} {
+			if err := step(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	result.Trails = trailChanges.count()
+	result.Visitors = visitorChanges.count()
+	result.Maintenance = maintenanceChanges.count()
+	result.Plans = planChanges.count()
+	result.StatusHistory = historyChanges.count()
+	result.StatusSchedule = scheduleChanges.count()
+	result.Tracks = trackChanges.count()
+	result.Junctions = junctionChanges.count()
+	result.Segments = segmentChanges.count()
+	for _, name := range Datasets {
+		if present[name] {
+			result.Imported = append(result.Imported, name)
+		}
+	}
+	return result, nil
+}
+
+// decodeRecords reads the JSON array of dataset, or a Records document
+// when dataset is empty, and reports which datasets it held
+func decodeRecords(r io.Reader, dataset string) (Records, map[string]bool, error) {
+	var records Records
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return records, nil, err
+	}
+	parts := make(map[string]json.RawMessage)
+	if dataset == "" {
+		if err := json.Unmarshal(data, &parts); err != nil {
+			return records, nil, fmt.Errorf("invalid JSON document: %w", err)
+		}
+	} else {
+		parts[dataset] = data
+	}
+
+	present := make(map[string]bool)
+	for name, part := range parts {
+		switch name {
+		case DatasetTrails:
+			err = json.Unmarshal(part, &records.Trails)
+		case DatasetVisitors:
+			err = json.Unmarshal(part, &records.Visitors)
+		case DatasetMaintenance:
+			err = json.Unmarshal(part, &records.Maintenance)
+		case DatasetPlans:
+			err = json.Unmarshal(part, &records.Plans)
+		case DatasetStatusHistory:
+			err = json.Unmarshal(part, &records.StatusHistory)
+		case DatasetStatusSchedule:
+			err = json.Unmarshal(part, &records.StatusSchedule)
+		case DatasetTracks:
+			err = json.Unmarshal(part, &records.Tracks)
+		case DatasetJunctions:
+			err = json.Unmarshal(part, &records.Junctions)
+		case DatasetSegments:
+			err = json.Unmarshal(part, &records.Segments)
+		default:
+			return records, nil, unknownDataset(name)
+		}
+		if err != nil {
+			return records, nil, fmt.Errorf("invalid %s JSON: %w", name, err)
+		}
+		present[name] = true
+	}
+	return records, present, nil
+}
+
+func unknownDataset(name string) error {
+	return fmt.Errorf("unknown dataset %q, use %s", name, strings.Join(Datasets, ", "))
+}
+
+// stage applies imported records to repo. prepare fills in the version of
+// the stored record and checks what a record references before it is
+// validated.
+func stage[T interface{ Validate() error }](repo *Storage.Memory[T], records []T, mode ImportMode, dataset string, prepare func(*T) error) []error {
+	if mode == Replace {
+		existing, _ := repo.List()
+		for _, record := range existing {
+			repo.Delete(repo.Key(record))
+		}
+	}
+
+	var problems []error
+	seen := make(map[string]bool)
+	for i, record := range records {
+		err := func() error {
+			key := repo.Key(record)
+			if key != "" && seen[key] {
+				return fmt.Errorf("ID %s appears more than once", key)
+			}
+			seen[key] = true
+			if err := prepare(&record); err != nil {
+				return err
+			}
+			if err := record.Validate(); err != nil {
+				return err
+			}
+			if existing, err := repo.Get(key); key != "" && err == nil {
+				if reflect.DeepEqual(existing, record) {
+					return nil
+				}
+				return repo.Update(key, record)
+			}
+			_, err := repo.Create(record)
+			return err
+		}()
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s[%d]: %w", dataset, i, err))
+		}
+	}
+	return problems
+}
+
+// linker returns a function that checks the trail a record references
+// and fills in its name. A record without a trail ID is linked to the only
+// trail with the name it mentions, ignoring case and spacing.
+func linker(trails []Trail.Trail) func(trailID, trailName *string) error {
+	return func(trailID, trailName *string) error {
+		if *trailID != "" {
+			i := slices.IndexFunc(trails, func(t Trail.Trail) bool { return t.ID == *trailID })
+			if i < 0 {
+				return &Storage.RecordError{Entity: "trail", Key: *trailID, Err: Storage.ErrInvalidReference}
+			}
+			*trailName = trails[i].Name
+			return nil
+		}
+
+		matches, exact := Trail.Match(trails, *trailName)
+		switch {
+		case exact && len(matches) == 1:
+			*trailID, *trailName = matches[0].ID, matches[0].Name
+			return nil
+		case exact:
+			return fmt.Errorf("%d trails are named '%s', give a trail_id", len(matches), *trailName)
+		case len(matches) > 0:
+			return fmt.Errorf("trail '%s' not found, did you mean '%s'?", *trailName, matches[0].Name)
+		}
+		return fmt.Errorf("trail '%s' not found", *trailName)
+	}
+}
+
+// followTrails checks that no staged record references a trail the import
+// removes, and carries the new name of a trail the import renames over to
+// its records. It returns the problems it found and the IDs of the trails
+// the import removes.
+func followTrails(current, staged []Trail.Trail, visitors *Visitor.MemoryRepository, maintenance *Maintenance.MemoryRepository) ([]error, map[string]bool) {
+	names := make(map[string]string)
+	for _, trail := range staged {
+		names[trail.ID] = trail.Name
+	}
+	removed := make(map[string]bool)
+	renamed := make(map[string]bool)
+	for _, trail := range current {
+		name, ok := names[trail.ID]
+		removed[trail.ID] = !ok
+		renamed[trail.ID] = ok && name != trail.Name
+	}
+
+	var problems []error
+	stagedVisitors, _ := visitors.List()
+	for _, visitor := range stagedVisitors {
+		switch {
+		case removed[visitor.TrailID]:
+			problems = append(problems, fmt.Errorf("visitor %s references trail %s, which the import removes", visitor.ID, visitor.TrailID))
+		case renamed[visitor.TrailID]:
+			visitor.Trail = names[visitor.TrailID]
+			visitors.Update(visitor.ID, visitor)
+		}
+	}
+	stagedMaintenance, _ := maintenance.List()
+	for _, record := range stagedMaintenance {
+		switch {
+		case removed[record.TrailID]:
+			problems = append(problems, fmt.Errorf("maintenance record %s references trail %s, which the import removes", record.ID, record.TrailID))
+		case renamed[record.TrailID]:
+			record.TrailName = names[record.TrailID]
+			maintenance.Update(record.ID, record)
+		}
+	}
+	return problems, removed
+}
+
+// dropRemoved deletes the staged records that belong to a removed trail.
+// ids returns the ID of a record and of its trail.
+func dropRemoved[T any](repo *Storage.Memory[T], removed map[string]bool, ids func(T) (id, trailID string)) {
+	records, _ := repo.List()
+	for _, record := range records {
+		if id, trailID := ids(record); removed[trailID] {
+			repo.Delete(id)
+		}
+	}
+}
+
+// changes are the writes that make a store hold the staged records
+type changes[T any] struct {
+	created, updated []T
+	deleted          []string
+}
+
+func diff[T any](store Storage.Store[T], current, staged []T) changes[T] {
+	remaining := make(map[string]T)
+	for _, record := range current {
+		remaining[store.Key(record)] = record
+	}
+	var c changes[T]
+	for _, record := range staged {
+		key := store.Key(record)
+		old, ok := remaining[key]
+		switch {
+		case !ok:
+			c.created = append(c.created, record)
+		case !reflect.DeepEqual(old, record):
+			c.updated = append(c.updated, record)
+		}
+		delete(remaining, key)
+	}
+	for _, record := range current {
+		if _, ok := remaining[store.Key(record)]; ok {
+			c.deleted = append(c.deleted, store.Key(record))
+		}
+	}
+	return c
+}
+
+// store creates and updates records
+func (c changes[T]) store(store Storage.Store[T]) error {
+	for _, record := range c.created {
+		if _, err := store.Create(record); err != nil {
+			return err
+		}
+	}
+	for _, record := range c.updated {
+		if err := store.Overwrite(store.Key(record), record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove deletes records
+func (c changes[T]) remove(store Storage.Store[T]) error {
+	for _, key := range c.deleted {
+		if err := store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c changes[T]) count() Changes {
+	return Changes{Created: len(c.created), Updated: len(c.updated), Deleted: len(c.deleted)}
+}
@@ -0,0 +1,32 @@
+package DataStore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImportFailureChangesNothing(t *testing.T) {
+	for _, backend := range []string{BackendCSV, BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			store := open(t, backend)
+			breakMaintenance(t, store)
+
+			// The trail is stored before its maintenance record fails, and
+			// must be removed again
+			document := `{
+				"trails": [{"name": "Ridge", "location": "Reno, NV", "difficulty": "Moderate", "length": 3, "status": "open"}],
+				"maintenance": [{"trail_name": "Ridge", "date": "2026-05-02", "type": "Clearing"}]
+			}`
+			if _, err := store.ImportJSON(strings.NewReader(document), "", Merge); err == nil {
+				t.Fatal("the import succeeded with the maintenance records broken")
+			}
+			trails, err := store.Trails.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(trails) != 0 {
+				t.Errorf("got %d trails after the failed import, want none", len(trails))
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package DataStore
+
+import (
+	Maintenance "project/Maintenance"
+)
+
+// GenerateWorkOrders creates the work orders the maintenance plans call for
+// through the date through, as Maintenance.Generate does, without letting
+// a change in between. Running it again for the same date creates nothing.
+func (s *DataStore) GenerateWorkOrders(through string) ([]Maintenance.Maintenance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Maintenance.Generate(planRepository{s.planStore, s}, maintenanceRepository{s.maintenanceStore, s}, through)
+}
@@ -0,0 +1,547 @@
+package DataStore
+
+import (
+	"errors"
+	"fmt"
+	Maintenance "project/Maintenance"
+	Network "project/Network"
+	Storage "project/Storage"
+	Trail "project/Trail"
+	Visitor "project/Visitor"
+	"time"
+)
+
+// trailRepository applies the reference policy when a trail is deleted or
+// renamed, and keeps status changes out of plain updates
+type trailRepository struct {
+	Trail.Repository
+	store *DataStore
+}
+
+func (r trailRepository) Update(id string, trail Trail.Trail) error {
+	current, err := r.Repository.Get(id)
+	if err != nil {
+		return err
+	}
+	// A stale edit is a conflict whatever it changes, so that it can be
+	// resolved like any other
+	if trail.Version != current.Version {
+		return &Storage.RecordError{Entity: "trail", Key: id, Err: fmt.Errorf("%w (read at version %d, now at version %d)", Storage.ErrConflict, trail.Version, current.Version)}
+	}
+	if current.Status != trail.Status {
+		return &Storage.RecordError{Entity: "trail", Key: id, Err: Trail.ErrStatusChange}
+	}
+	if current.Name == trail.Name {
+		return r.Repository.Update(id, trail)
+	}
+
+	visitors, maintenance, err := r.store.references(id)
+	if err != nil {
+		return err
+	}
+	if r.store.config.ReferencePolicy == Restrict {
+		if err := referenced(id, visitors, maintenance); err != nil {
+			return err
+		}
+	}
+	// The trail and the records that carry its name are renamed together
+	return r.store.batched(func(b *batch) error {
+		if err := in(b, r.store.trailStore).Update(id, trail); err != nil {
+			return err
+		}
+		if r.store.config.ReferencePolicy != Cascade {
+			return nil
+		}
+		for _, visitor := range visitors {
+			visitor.Trail = trail.Name
+			if err := in(b, r.store.visitorStore).Update(visitor.ID, visitor); err != nil {
+				return err
+			}
+		}
+		for _, record := range maintenance {
+			record.TrailName = trail.Name
+			if err := in(b, r.store.maintenanceStore).Update(record.ID, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete deletes a trail along with everything that belongs to it, and
+// applies the reference policy to its records. Either all of it is done or
+// none of it.
+func (r trailRepository) Delete(id string) error {
+	if _, err := r.Repository.Get(id); err != nil {
+		return err
+	}
+	visitors, maintenance, err := r.store.references(id)
+	if err != nil {
+		return err
+	}
+	if r.store.config.ReferencePolicy == Restrict {
+		if err := referenced(id, visitors, maintenance); err != nil {
+			return err
+		}
+	}
+	return r.store.batched(func(b *batch) error {
+		return r.delete(b, id, visitors, maintenance)
+	})
+}
+
+func (r trailRepository) delete(b *batch, id string, visitors []Visitor.Visitor, maintenance []Maintenance.Maintenance) error {
+	visitorStore, maintenanceStore := in(b, r.store.visitorStore), in(b, r.store.maintenanceStore)
+	switch r.store.config.ReferencePolicy {
+	case Cascade:
+		for _, visitor := range visitors {
+			if err := visitorStore.Delete(visitor.ID); err != nil {
+				return err
+			}
+		}
+		for _, record := range maintenance {
+			if err := maintenanceStore.Delete(record.ID); err != nil {
+				return err
+			}
+		}
+	case Orphan:
+		for _, visitor := range visitors {
+			visitor.TrailID = ""
+			if err := visitorStore.Update(visitor.ID, visitor); err != nil {
+				return err
+			}
+		}
+		for _, record := range maintenance {
+			record.TrailID = ""
+			if err := maintenanceStore.Update(record.ID, record); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The maintenance plans, status history, schedule, track and segments
+	// belong to the trail, whatever the policy
+	planStore := in(b, r.store.planStore)
+	plans, err := Maintenance.Plans(planStore, id)
+	if err != nil {
+		return err
+	}
+	for _, plan := range plans {
+		if err := planStore.Delete(plan.ID); err != nil {
+			return err
+		}
+	}
+	historyStore := in(b, r.store.historyStore)
+	history, err := Trail.History(historyStore, id)
+	if err != nil {
+		return err
+	}
+	for _, change := range history {
+		if err := historyStore.Delete(change.ID); err != nil {
+			return err
+		}
+	}
+	scheduleStore := in(b, r.store.scheduleStore)
+	schedule, err := Trail.Schedule(scheduleStore, id)
+	if err != nil {
+		return err
+	}
+	for _, change := range schedule {
+		if err := scheduleStore.Delete(change.ID); err != nil {
+			return err
+		}
+	}
+	trackStore := in(b, r.store.trackStore)
+	track, err := Trail.TrackOf(trackStore, id)
+	if err == nil {
+		err = trackStore.Delete(track.ID)
+	}
+	if err != nil && !errors.Is(err, Storage.ErrNotFound) {
+		return err
+	}
+	segmentStore := in(b, r.store.segmentStore)
+	segments, err := Network.Segments(segmentStore, id)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if err := segmentStore.Delete(segment.ID); err != nil {
+			return err
+		}
+	}
+	return in(b, r.store.trailStore).Delete(id)
+}
+
+// referenced returns an ErrReferenced error if the trail has records
+func referenced(id string, visitors []Visitor.Visitor, maintenance []Maintenance.Maintenance) error {
+	if len(visitors) == 0 && len(maintenance) == 0 {
+		return nil
+	}
+	return &Storage.RecordError{
+		Entity: "trail",
+		Key:    id,
+		Err:    fmt.Errorf("%w by %d visitor and %d maintenance records", Storage.ErrReferenced, len(visitors), len(maintenance)),
+	}
+}
+
+// references returns the visitor and maintenance records of a trail
+func (s *DataStore) references(trailID string) ([]Visitor.Visitor, []Maintenance.Maintenance, error) {
+	visitors, err := s.visitorStore.List()
+	if err != nil {
+		return nil, nil, err
+	}
+	records, err := s.maintenanceStore.List()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var trailVisitors []Visitor.Visitor
+	for _, visitor := range visitors {
+		if visitor.TrailID == trailID {
+			trailVisitors = append(trailVisitors, visitor)
+		}
+	}
+	var trailMaintenance []Maintenance.Maintenance
+	for _, record := range records {
+		if record.TrailID == trailID {
+			trailMaintenance = append(trailMaintenance, record)
+		}
+	}
+	return trailVisitors, trailMaintenance, nil
+}
+
+// trail returns the trail a record references, or an ErrInvalidReference
+// error if there is none
+func (s *DataStore) trail(trailID string) (Trail.Trail, error) {
+	trail, err := s.trailStore.Get(trailID)
+	if errors.Is(err, Storage.ErrNotFound) || trailID == "" {
+		return trail, &Storage.RecordError{Entity: "trail", Key: trailID, Err: Storage.ErrInvalidReference}
+	}
+	return trail, err
+}
+
+// visitorRepository checks visitor records and the trail they reference
+type visitorRepository struct {
+	Visitor.Repository
+	store *DataStore
+}
+
+func (r visitorRepository) Create(visitor Visitor.Visitor) (Visitor.Visitor, error) {
+	trail, err := r.store.trail(visitor.TrailID)
+	if err != nil {
+		return visitor, err
+	}
+	visitor.Trail = trail.Name
+	if err := visitor.Validate(); err != nil {
+		return visitor, err
+	}
+	return r.Repository.Create(visitor)
+}
+
+func (r visitorRepository) Update(id string, visitor Visitor.Visitor) error {
+	current, err := r.Repository.Get(id)
+	if err != nil {
+		return err
+	}
+	// Records that were already dangling can still be edited, the
+	// integrity check reports them
+	if visitor.TrailID != current.TrailID || visitor.Trail != current.Trail {
+		trail, err := r.store.trail(visitor.TrailID)
+		if err != nil {
+			return err
+		}
+		visitor.Trail = trail.Name
+	}
+	if err := visitor.Validate(); err != nil {
+		return err
+	}
+	return r.Repository.Update(id, visitor)
+}
+
+// maintenanceRepository checks maintenance records and the trail they
+// reference
+type maintenanceRepository struct {
+	Maintenance.Repository
+	store *DataStore
+}
+
+// Create opens a work order, requested and of normal priority unless the
+// record says otherwise, and stamps the time it was created
+func (r maintenanceRepository) Create(record Maintenance.Maintenance) (Maintenance.Maintenance, error) {
+	trail, err := r.store.trail(record.TrailID)
+	if err != nil {
+		return record, err
+	}
+	record.TrailName = trail.Name
+	if record.Priority == "" {
+		record.Priority = Maintenance.Normal
+	}
+	if record.Status == "" {
+		record.Status = Maintenance.Requested
+	}
+	now := time.Now()
+	record.CreatedAt, record.StartedAt, record.CompletedAt, record.CancelledAt = Maintenance.Timestamp(now), "", "", ""
+	record = record.Stamp(now)
+	if err := record.Validate(); err != nil {
+		return record, err
+	}
+	return r.Repository.Create(record)
+}
+
+// Update changes a work order. Its status can only move on as the work
+// order lifecycle allows, and the times it reached each status are kept
+// as they were recorded.
+func (r maintenanceRepository) Update(id string, record Maintenance.Maintenance) error {
+	current, err := r.Repository.Get(id)
+	if err != nil {
+		return err
+	}
+	if record.Priority == "" {
+		record.Priority = current.Priority
+	}
+	if record.Status == "" {
+		record.Status = current.Status
+	}
+	if record.Status != current.Status && !current.Status.CanChangeTo(record.Status) {
+		return &Storage.RecordError{Entity: "maintenance record", Key: id, Err: fmt.Errorf("%w: from %s to %s", Maintenance.ErrTransition, current.Status, record.Status)}
+	}
+	record.CreatedAt, record.StartedAt, record.CompletedAt, record.CancelledAt = current.CreatedAt, current.StartedAt, current.CompletedAt, current.CancelledAt
+	if record.Status != current.Status {
+		record = record.Stamp(time.Now())
+	}
+	if record.TrailID != current.TrailID || record.TrailName != current.TrailName {
+		trail, err := r.store.trail(record.TrailID)
+		if err != nil {
+			return err
+		}
+		record.TrailName = trail.Name
+	}
+	if err := record.Validate(); err != nil {
+		return err
+	}
+	return r.Repository.Update(id, record)
+}
+
+// planRepository checks maintenance plans and the trail they are for
+type planRepository struct {
+	Maintenance.PlanRepository
+	store *DataStore
+}
+
+// Create stores a plan, of normal priority unless it says otherwise
+func (r planRepository) Create(plan Maintenance.Plan) (Maintenance.Plan, error) {
+	if plan.Priority == "" {
+		plan.Priority = Maintenance.Normal
+	}
+	if err := plan.Validate(); err != nil {
+		return plan, err
+	}
+	if _, err := r.store.trail(plan.TrailID); err != nil {
+		return plan, err
+	}
+	return r.PlanRepository.Create(plan)
+}
+
+func (r planRepository) Update(id string, plan Maintenance.Plan) error {
+	if plan.Priority == "" {
+		plan.Priority = Maintenance.Normal
+	}
+	if err := plan.Validate(); err != nil {
+		return err
+	}
+	if _, err := r.store.trail(plan.TrailID); err != nil {
+		return err
+	}
+	return r.PlanRepository.Update(id, plan)
+}
+
+// historyRepository changes the status of a trail when a status change is
+// recorded for it, and keeps recorded changes as they are
+type historyRepository struct {
+	Trail.HistoryRepository
+	store *DataStore
+}
+
+// Create records a status change and applies it to the trail. The change
+// starts from the trail's current status, which must allow it, and takes
+// effect today unless it gives an effective date. It cannot take effect
+// before the trail's last change or in the future.
+func (r historyRepository) Create(change Trail.StatusChange) (Trail.StatusChange, error) {
+	trail, err := r.store.trail(change.TrailID)
+	if err != nil {
+		return change, err
+	}
+	change.From = trail.Status
+	if change.EffectiveDate == "" {
+		change.EffectiveDate = Trail.Today()
+	}
+	if err := change.Validate(); err != nil {
+		return change, err
+	}
+
+	rejected := func(format string, args ...any) error {
+		return &Storage.RecordError{Entity: "trail", Key: trail.ID, Err: fmt.Errorf("%w: "+format, append([]any{Trail.ErrTransition}, args...)...)}
+	}
+	if !change.From.CanChangeTo(change.To) {
+		return change, rejected("a trail that is %s cannot be changed to %s", change.From, change.To)
+	}
+	if change.EffectiveDate > Trail.Today() {
+		return change, rejected("effective date %s is in the future", change.EffectiveDate)
+	}
+	history, err := Trail.History(r.HistoryRepository, trail.ID)
+	if err != nil {
+		return change, err
+	}
+	if n := len(history); n > 0 && change.EffectiveDate < history[n-1].EffectiveDate {
+		return change, rejected("effective date %s is before the last change on %s", change.EffectiveDate, history[n-1].EffectiveDate)
+	}
+
+	change, err = r.HistoryRepository.Create(change)
+	if err != nil {
+		return change, err
+	}
+	trail.Status = change.To
+	if err := r.store.trailStore.Update(trail.ID, trail); err != nil {
+		r.HistoryRepository.Delete(change.ID)
+		return change, err
+	}
+	return change, nil
+}
+
+func (r historyRepository) Update(id string, change Trail.StatusChange) error {
+	return &Storage.RecordError{Entity: "status change", Key: id, Err: Trail.ErrHistory}
+}
+
+func (r historyRepository) Delete(id string) error {
+	return &Storage.RecordError{Entity: "status change", Key: id, Err: Trail.ErrHistory}
+}
+
+// scheduleRepository checks scheduled changes and the trail they are for
+type scheduleRepository struct {
+	Trail.ScheduleRepository
+	store *DataStore
+}
+
+func (r scheduleRepository) Create(change Trail.ScheduledChange) (Trail.ScheduledChange, error) {
+	if err := change.Validate(); err != nil {
+		return change, err
+	}
+	if _, err := r.store.trail(change.TrailID); err != nil {
+		return change, err
+	}
+	return r.ScheduleRepository.Create(change)
+}
+
+func (r scheduleRepository) Update(id string, change Trail.ScheduledChange) error {
+	if err := change.Validate(); err != nil {
+		return err
+	}
+	if _, err := r.store.trail(change.TrailID); err != nil {
+		return err
+	}
+	return r.ScheduleRepository.Update(id, change)
+}
+
+// trackRepository checks tracks and the trail they are for
+type trackRepository struct {
+	Trail.TrackRepository
+	store *DataStore
+}
+
+func (r trackRepository) Create(track Trail.Track) (Trail.Track, error) {
+	if err := track.Validate(); err != nil {
+		return track, err
+	}
+	if _, err := r.store.trail(track.TrailID); err != nil {
+		return track, err
+	}
+	return r.TrackRepository.Create(track)
+}
+
+func (r trackRepository) Update(id string, track Trail.Track) error {
+	if err := track.Validate(); err != nil {
+		return err
+	}
+	if _, err := r.store.trail(track.TrailID); err != nil {
+		return err
+	}
+	return r.TrackRepository.Update(id, track)
+}
+
+// junctionRepository checks junctions, and keeps the junctions segments
+// meet at
+type junctionRepository struct {
+	Network.JunctionRepository
+	store *DataStore
+}
+
+func (r junctionRepository) Create(junction Network.Junction) (Network.Junction, error) {
+	if err := junction.Validate(); err != nil {
+		return junction, err
+	}
+	return r.JunctionRepository.Create(junction)
+}
+
+func (r junctionRepository) Update(id string, junction Network.Junction) error {
+	if err := junction.Validate(); err != nil {
+		return err
+	}
+	return r.JunctionRepository.Update(id, junction)
+}
+
+func (r junctionRepository) Delete(id string) error {
+	if _, err := r.JunctionRepository.Get(id); err != nil {
+		return err
+	}
+	segments, err := Network.AtJunction(r.store.segmentStore, id)
+	if err != nil {
+		return err
+	}
+	if len(segments) > 0 {
+		return &Storage.RecordError{
+			Entity: "junction",
+			Key:    id,
+			Err:    fmt.Errorf("%w by %d segments", Storage.ErrReferenced, len(segments)),
+		}
+	}
+	return r.JunctionRepository.Delete(id)
+}
+
+// segmentRepository checks segments, the trail they are part of and the
+// junctions they run between
+type segmentRepository struct {
+	Network.SegmentRepository
+	store *DataStore
+}
+
+func (r segmentRepository) check(segment Network.Segment) error {
+	if err := segment.Validate(); err != nil {
+		return err
+	}
+	if _, err := r.store.trail(segment.TrailID); err != nil {
+		return err
+	}
+	for _, id := range []string{segment.From, segment.To} {
+		_, err := r.store.junctionStore.Get(id)
+		if errors.Is(err, Storage.ErrNotFound) {
+			return &Storage.RecordError{Entity: "junction", Key: id, Err: Storage.ErrInvalidReference}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r segmentRepository) Create(segment Network.Segment) (Network.Segment, error) {
+	if err := r.check(segment); err != nil {
+		return segment, err
+	}
+	return r.SegmentRepository.Create(segment)
+}
+
+func (r segmentRepository) Update(id string, segment Network.Segment) error {
+	if err := r.check(segment); err != nil {
+		return err
+	}
+	return r.SegmentRepository.Update(id, segment)
+}
@@ -0,0 +1,110 @@
+package DataStore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	Maintenance "project/Maintenance"
+	Storage "project/Storage"
+	Trail "project/Trail"
+	Visitor "project/Visitor"
+	"testing"
+)
+
+// breakMaintenance makes every later write to the maintenance records fail
+func breakMaintenance(t *testing.T, store *DataStore) {
+	t.Helper()
+	if store.db != nil {
+		for _, op := range []string{"INSERT", "UPDATE", "DELETE"} {
+			_, err := store.db.DB.Exec("CREATE TRIGGER broken_" + op + " BEFORE " + op + " ON maintenance BEGIN SELECT RAISE(ABORT, 'broken'); END")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+	journal := filepath.Join(store.config.DataDir, "maintenance.journal")
+	if err := os.Remove(journal); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(journal, 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCascadeFailureChangesNothing(t *testing.T) {
+	for _, backend := range []string{BackendCSV, BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			store := open(t, backend)
+			trail, err := store.Trails.Create(Trail.Trail{
+				Name:          "Ridge",
+				Location:      Trail.ParseLocation("Reno, NV"),
+				Difficulty:    Trail.Moderate,
+				Length:        Trail.Distance{Value: 3, Unit: Trail.Mile},
+				ElevationGain: Trail.Elevation{Unit: Trail.Foot},
+				Status:        Trail.Open,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			visitor, err := store.Visitors.Create(Visitor.Visitor{Name: "Hiker", VisitDate: "2026-05-01", Satisfaction: "5", TrailID: trail.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Maintenance.Create(Maintenance.Maintenance{TrailID: trail.ID, Date: "2026-05-02", Type: "Clearing"}); err != nil {
+				t.Fatal(err)
+			}
+			breakMaintenance(t, store)
+
+			// The visitor is renamed or deleted before the maintenance
+			// record fails, and must be put back
+			renamed := trail
+			renamed.Name = "High Ridge"
+			if err := store.Trails.Update(trail.ID, renamed); err == nil {
+				t.Fatal("renaming the trail succeeded with the maintenance records broken")
+			}
+			if err := store.Trails.Delete(trail.ID); err == nil {
+				t.Fatal("deleting the trail succeeded with the maintenance records broken")
+			}
+
+			got, err := store.Trails.Get(trail.ID)
+			if err != nil {
+				t.Fatalf("the trail is gone: %v", err)
+			}
+			if got.Name != trail.Name {
+				t.Errorf("the trail is named %q, want %q", got.Name, trail.Name)
+			}
+			gotVisitor, err := store.Visitors.Get(visitor.ID)
+			if err != nil {
+				t.Fatalf("the visitor is gone: %v", err)
+			}
+			if gotVisitor.Trail != trail.Name || gotVisitor.TrailID != trail.ID {
+				t.Errorf("the visitor names trail %s %q, want %s %q", gotVisitor.TrailID, gotVisitor.Trail, trail.ID, trail.Name)
+			}
+		})
+	}
+}
+
+func TestStaleTrailEditConflicts(t *testing.T) {
+	store := open(t, BackendCSV)
+	trail, err := store.Trails.Create(Trail.Trail{
+		Name:          "Ridge",
+		Location:      Trail.ParseLocation("Reno, NV"),
+		Difficulty:    Trail.Moderate,
+		Length:        Trail.Distance{Value: 3, Unit: Trail.Mile},
+		ElevationGain: Trail.Elevation{Unit: Trail.Foot},
+		Status:        Trail.Open,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.StatusHistory.Create(Trail.StatusChange{TrailID: trail.ID, To: Trail.Closed, Reason: "Rockfall"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The edit was read before the trail was closed
+	trail.Name = "High Ridge"
+	if err := store.Trails.Update(trail.ID, trail); !errors.Is(err, Storage.ErrConflict) {
+		t.Errorf("got %v, want %v", err, Storage.ErrConflict)
+	}
+}
@@ -0,0 +1,116 @@
+package DataStore
+
+import (
+	Maintenance "project/Maintenance"
+	Network "project/Network"
+	Storage "project/Storage"
+	Trail "project/Trail"
+	Visitor "project/Visitor"
+	"sync"
+)
+
+// locked guards a repository with the data store's lock. A change made
+// through the public repositories can touch several datasets (deleting a
+// trail applies the reference policy to its records), so it holds the lock
+// until the whole change is done and readers never see half of it.
+type locked[T any] struct {
+	repo Storage.Repository[T]
+	mu   *sync.RWMutex
+}
+
+func (l locked[T]) Get(key string) (T, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.repo.Get(key)
+}
+
+func (l locked[T]) List() ([]T, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.repo.List()
+}
+
+func (l locked[T]) Create(record T) (T, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.repo.Create(record)
+}
+
+func (l locked[T]) Update(key string, record T) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.repo.Update(key, record)
+}
+
+func (l locked[T]) Delete(key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.repo.Delete(key)
+}
+
+// Snapshot is a copy of every dataset taken at one moment, for reports
+// that read several datasets. Changes made to it are not stored.
+type Snapshot struct {
+	Trails         Trail.Repository
+	Visitors       Visitor.Repository
+	Maintenance    Maintenance.Repository
+	Plans          Maintenance.PlanRepository
+	StatusHistory  Trail.HistoryRepository
+	StatusSchedule Trail.ScheduleRepository
+	Tracks         Trail.TrackRepository
+	Junctions      Network.JunctionRepository
+	Segments       Network.SegmentRepository
+}
+
+// Snapshot copies every dataset without letting a change in between
+func (s *DataStore) Snapshot() (Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records, err := s.records()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return Snapshot{
+		Trails:         Trail.NewMemoryRepository(records.Trails...),
+		Visitors:       Visitor.NewMemoryRepository(records.Visitors...),
+		Maintenance:    Maintenance.NewMemoryRepository(records.Maintenance...),
+		Plans:          Maintenance.NewMemoryPlanRepository(records.Plans...),
+		StatusHistory:  Trail.NewMemoryHistoryRepository(records.StatusHistory...),
+		StatusSchedule: Trail.NewMemoryScheduleRepository(records.StatusSchedule...),
+		Tracks:         Trail.NewMemoryTrackRepository(records.Tracks...),
+		Junctions:      Network.NewMemoryJunctionRepository(records.Junctions...),
+		Segments:       Network.NewMemorySegmentRepository(records.Segments...),
+	}, nil
+}
+
+// records lists every dataset. The caller holds the lock.
+func (s *DataStore) records() (Records, error) {
+	var records Records
+	var err error
+	if records.Trails, err = s.trailStore.List(); err != nil {
+		return records, err
+	}
+	if records.Visitors, err = s.visitorStore.List(); err != nil {
+		return records, err
+	}
+	if records.Maintenance, err = s.maintenanceStore.List(); err != nil {
+		return records, err
+	}
+	if records.Plans, err = s.planStore.List(); err != nil {
+		return records, err
+	}
+	if records.StatusHistory, err = s.historyStore.List(); err != nil {
+		return records, err
+	}
+	if records.StatusSchedule, err = s.scheduleStore.List(); err != nil {
+		return records, err
+	}
+	if records.Tracks, err = s.trackStore.List(); err != nil {
+		return records, err
+	}
+	if records.Junctions, err = s.junctionStore.List(); err != nil {
+		return records, err
+	}
+	records.Segments, err = s.segmentStore.List()
+	return records, err
+}
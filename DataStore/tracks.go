@@ -0,0 +1,70 @@
+package DataStore
+
+import (
+	"errors"
+
+	Storage "project/Storage"
+	Trail "project/Trail"
+)
+
+// ImportGPX stores track as the geometry of trail and fills in the trail's
+// length, elevation gain and trailhead from it. A trail without an ID is
+// created; otherwise the stored trail is updated and its previous track,
+// if any, is replaced. Nothing is stored if any step fails.
+func (s *DataStore) ImportGPX(trail Trail.Trail, track Trail.Track) (Trail.Trail, Trail.TrackStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := track.Stats()
+	stats.Apply(&trail)
+	if err := trail.Validate(); err != nil {
+		return trail, stats, err
+	}
+	trails := trailRepository{s.trailStore, s}
+	tracks := trackRepository{s.trackStore, s}
+
+	// Check the track before changing the trail. A new trail has no ID
+	// yet, so the track is checked as if it had one.
+	track.TrailID = trail.ID
+	if track.TrailID == "" {
+		track.TrailID = "new"
+	}
+	if err := track.Validate(); err != nil {
+		return trail, stats, err
+	}
+
+	created := trail.ID == ""
+	var previous Trail.Trail
+	var err error
+	if created {
+		trail, err = trails.Create(trail)
+	} else if previous, err = s.trail(trail.ID); err == nil {
+		err = trails.Update(trail.ID, trail)
+	}
+	if err != nil {
+		return trail, stats, err
+	}
+	if trail, err = s.trailStore.Get(trail.ID); err != nil {
+		return trail, stats, err
+	}
+
+	track.TrailID = trail.ID
+	existing, err := Trail.TrackOf(s.trackStore, trail.ID)
+	switch {
+	case err == nil:
+		track.ID = existing.ID
+		err = tracks.Update(existing.ID, track)
+	case errors.Is(err, Storage.ErrNotFound):
+		track.ID = ""
+		_, err = tracks.Create(track)
+	}
+	if err != nil {
+		if created {
+			s.trailStore.Delete(trail.ID)
+		} else {
+			s.trailStore.Overwrite(previous.ID, previous)
+		}
+		return previous, stats, err
+	}
+	return trail, stats, nil
+}
@@ -6,32 +6,63 @@ import (
 	"strconv"
 )
 
-// ViewFeedbackSummary aggregates and analyzes visitor satisfaction scores.
-func ViewFeedbackSummary() {
-	if len(Visitor.Visitors) == 0 {
-		fmt.Println("No visitor feedback available to analyze.")
-		return
-	}
-
-	fmt.Println("\nFeedback Summary")
+// Summary aggregates the satisfaction scores of all visits
+type Summary struct {
+	// Scores counts the visits per satisfaction score, 1 to 5
+	Scores  map[int]int `json:"scores"`
+	Entries int         `json:"entries"`
+	Average float64     `json:"average"`
+	// Invalid are the visits skipped for an invalid satisfaction score
+	Invalid []Visitor.Visitor `json:"invalid,omitempty"`
+}
 
-	// Satisfaction score counts and average calculation
-	satisfactionCounts := make(map[int]int)
-	var totalSatisfaction, totalEntries int
+// Summarize aggregates the satisfaction scores of the visits in visitors
+func Summarize(visitors Visitor.Repository) (Summary, error) {
+	summary := Summary{Scores: make(map[int]int)}
+	visitorRecords, err := visitors.List()
+	if err != nil {
+		return summary, fmt.Errorf("reading visitors: %w", err)
+	}
 
-	for _, visitor := range Visitor.Visitors {
+	var totalSatisfaction int
+	for i := 1; i <= 5; i++ {
+		summary.Scores[i] = 0
+	}
+	for _, visitor := range visitorRecords {
 		score, err := strconv.Atoi(visitor.Satisfaction)
 		if err != nil || score < 1 || score > 5 {
-			fmt.Printf("Skipping invalid satisfaction score for visitor %s: %s\n", visitor.Name, visitor.Satisfaction)
+			summary.Invalid = append(summary.Invalid, visitor)
 			continue
 		}
 
-		satisfactionCounts[score]++
+		summary.Scores[score]++
 		totalSatisfaction += score
-		totalEntries++
+		summary.Entries++
+	}
+	if summary.Entries > 0 {
+		summary.Average = float64(totalSatisfaction) / float64(summary.Entries)
+	}
+	return summary, nil
+}
+
+// ViewFeedbackSummary aggregates and analyzes visitor satisfaction scores.
+func ViewFeedbackSummary(visitors Visitor.Repository) {
+	summary, err := Summarize(visitors)
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+	if summary.Entries == 0 && len(summary.Invalid) == 0 {
+		fmt.Println("No visitor feedback available to analyze.")
+		return
+	}
+
+	fmt.Println("\nFeedback Summary")
+	for _, visitor := range summary.Invalid {
+		fmt.Printf("Skipping invalid satisfaction score for visitor %s: %s\n", visitor.Name, visitor.Satisfaction)
 	}
 
-	if totalEntries == 0 {
+	if summary.Entries == 0 {
 		fmt.Println("No valid satisfaction data to display.")
 		return
 	}
@@ -39,10 +70,9 @@ func ViewFeedbackSummary() {
 	// Display satisfaction counts
 	fmt.Println("\nSatisfaction Score Distribution:")
 	for i := 1; i <= 5; i++ {
-		fmt.Printf("Score %d: %d entries\n", i, satisfactionCounts[i])
+		fmt.Printf("Score %d: %d entries\n", i, summary.Scores[i])
 	}
 
-	// Calculate and display average satisfaction score
-	averageSatisfaction := float64(totalSatisfaction) / float64(totalEntries)
-	fmt.Printf("\nAverage Satisfaction Score: %.2f\n", averageSatisfaction)
+	// Display average satisfaction score
+	fmt.Printf("\nAverage Satisfaction Score: %.2f\n", summary.Average)
 }
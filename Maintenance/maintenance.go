@@ -2,109 +2,154 @@ package Maintenance
 
 import (
 	"bufio"
-	"encoding/csv"
+	"errors"
 	"fmt"
 	"os"
-	"regexp"
+	"project/Storage"
+	Trail "project/Trail"
+	"project/utils"
+	"strconv"
 	"strings"
 )
 
+// Maintenance is a work order: maintenance of a trail from its request to
+// its completion
 type Maintenance struct {
-	TrailName string
-	Date      string
-	Type      string
+	ID        string `json:"id"`
+	TrailID   string `json:"trail_id"`
+	TrailName string `json:"trail_name"`
+	// Date is the day the work is planned for, or was done on
+	Date        string   `json:"date"`
+	Type        string   `json:"type"`
+	Priority    Priority `json:"priority"`
+	Description string   `json:"description,omitempty"`
+	Crew        string   `json:"crew,omitempty"`
+	// EstimatedHours and ActualHours are 0 when not known
+	EstimatedHours float64    `json:"estimated_hours,omitempty"`
+	ActualHours    float64    `json:"actual_hours,omitempty"`
+	Status         WorkStatus `json:"status"`
+	// Notes are written when the work is completed or cancelled
+	Notes string `json:"notes,omitempty"`
+	// CreatedAt, StartedAt, CompletedAt and CancelledAt are RFC 3339
+	// times, empty until the work order reaches the status. Records kept
+	// before work orders existed have none.
+	CreatedAt   string `json:"created_at,omitempty"`
+	StartedAt   string `json:"started_at,omitempty"`
+	CompletedAt string `json:"completed_at,omitempty"`
+	CancelledAt string `json:"cancelled_at,omitempty"`
+	// PlanID is the ID of the maintenance plan that generated the work
+	// order, empty for one entered by hand
+	PlanID  string `json:"plan_id,omitempty"`
+	Version int    `json:"version"`
 }
 
-var MaintenanceRecords []Maintenance
-
-// Load maintenance data from a CSV file
-func LoadMaintenanceData(filePath string) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		fmt.Println("Error loading file:", err)
-		return
+// Validate checks a maintenance record against the rules addMaintenance
+// enforces. The priority and status can be left empty for the defaults a
+// new work order is given.
+func (m Maintenance) Validate() error {
+	switch {
+	case strings.TrimSpace(m.TrailName) == "":
+		return errors.New("trail name cannot be empty")
+	case !Trail.ValidDate(m.Date):
+		return fmt.Errorf("invalid maintenance date %q, please use YYYY-MM-DD", m.Date)
+	case strings.TrimSpace(m.Type) == "":
+		return errors.New("maintenance type cannot be empty")
+	case m.Priority != "" && !m.Priority.Valid():
+		return fmt.Errorf("unknown priority %q, use one of: %s", m.Priority, JoinPriorities())
+	case m.Status != "" && !m.Status.Valid():
+		return fmt.Errorf("unknown work order status %q, use one of: %s", m.Status, JoinWorkStatuses(WorkStatuses))
+	case m.EstimatedHours < 0 || m.ActualHours < 0:
+		return errors.New("hours cannot be negative")
+	}
+	for _, t := range []string{m.CreatedAt, m.StartedAt, m.CompletedAt, m.CancelledAt} {
+		if !validTimestamp(t) {
+			return fmt.Errorf("invalid time %q, please use RFC 3339, e.g. 2024-05-01T14:30:00Z", t)
+		}
 	}
-	defer file.Close()
+	return nil
+}
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+// chooseMaintenance finds the maintenance records for trailName on date,
+// asking the user to pick one when there are several. The trail name is
+// matched the way trails are looked up: ignoring case and spacing, or else
+// by a similar name.
+func chooseMaintenance(repo Repository, trailName, date string) (Maintenance, bool) {
+	records, err := repo.List()
 	if err != nil {
-		fmt.Println("Error reading CSV:", err)
-		return
+		fmt.Println("Error reading maintenance records:", err)
+		return Maintenance{}, false
 	}
 
-	for _, record := range records {
-		if len(record) < 3 {
-			fmt.Println("Skipping invalid record:", record)
-			continue
-		}
-
-		// Validate and parse date
-		if !isValidDate(record[1]) {
-			fmt.Println("Skipping record with invalid date:", record)
-			continue
+	var matches []Maintenance
+	similar := false
+	for _, same := range []func(name, typed string) bool{Trail.SameName, Trail.Similar} {
+		for _, record := range records {
+			if record.Date == date && same(record.TrailName, trailName) {
+				matches = append(matches, record)
+			}
 		}
-
-		maintenance := Maintenance{
-			TrailName: record[0],
-			Date:      record[1],
-			Type:      record[2],
+		if len(matches) > 0 {
+			break
 		}
-		MaintenanceRecords = append(MaintenanceRecords, maintenance)
+		similar = true
 	}
-}
-
-// Save maintenance data to a CSV file
-func SaveMaintenanceData(filePath string) {
-	file, err := os.Create(filePath)
-	if err != nil {
-		fmt.Println("Error creating file:", err)
-		return
+	options := make([]string, len(matches))
+	for i, record := range matches {
+		options[i] = fmt.Sprintf("%s on %s: %s (ID %s)", record.TrailName, record.Date, record.Type, record.ID)
 	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	for _, record := range MaintenanceRecords {
-		data := []string{record.TrailName, record.Date, record.Type}
-		if err := writer.Write(data); err != nil {
-			fmt.Println("Error writing to CSV:", err)
-			return
+	switch {
+	case len(matches) == 0:
+		fmt.Println("Maintenance record not found.")
+		return Maintenance{}, false
+	case similar:
+		fmt.Printf("No maintenance record for '%s' on %s, showing the records for similar trail names.\n", trailName, date)
+		if len(matches) == 1 {
+			fmt.Println("Found", options[0])
 		}
 	}
-}
 
-// Validate if the date is in the correct format (YYYY-MM-DD)
-func isValidDate(date string) bool {
-	// Using regex to match the date format (YYYY-MM-DD)
-	re := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
-	return re.MatchString(date)
+	i := utils.Choose("Select the maintenance record", options)
+	if i < 0 {
+		fmt.Println("Operation cancelled.")
+		return Maintenance{}, false
+	}
+	return matches[i], true
 }
 
-// Maintenance menu for managing maintenance records
-func MaintenanceMenu() {
+// Maintenance menu for managing maintenance records and the plans that
+// generate them. generate creates the work orders the plans call for
+// through a date.
+func MaintenanceMenu(repo Repository, plans PlanRepository, trails Trail.Repository, generate func(through string) ([]Maintenance, error)) {
 	for {
 		fmt.Println("\nMaintenance Scheduling")
-		fmt.Println("1. Add Maintenance Record")
-		fmt.Println("2. Update Maintenance Record")
-		fmt.Println("3. Delete Maintenance Record")
-		fmt.Println("4. View Maintenance Records")
-		fmt.Println("5. Back to Main Menu")
+		fmt.Println("1. Add Work Order")
+		fmt.Println("2. Update Work Order")
+		fmt.Println("3. Change Work Order Status")
+		fmt.Println("4. Delete Work Order")
+		fmt.Println("5. View Work Orders")
+		fmt.Println("6. Maintenance Plans")
+		fmt.Println("7. View Due and Overdue Work")
+		fmt.Println("8. Back to Main Menu")
 
 		var choice int
 		fmt.Scanln(&choice)
 
 		switch choice {
 		case 1:
-			addMaintenance()
+			addMaintenance(repo, trails)
 		case 2:
-			updateMaintenance()
+			updateMaintenance(repo)
 		case 3:
-			deleteMaintenance()
+			changeWorkStatus(repo)
 		case 4:
-			viewMaintenanceRecords()
+			deleteMaintenance(repo)
 		case 5:
+			viewMaintenanceRecords(repo)
+		case 6:
+			planMenu(plans, trails, generate)
+		case 7:
+			viewDueWork(repo, plans, trails)
+		case 8:
 			return
 		default:
 			fmt.Println("Invalid option.")
@@ -113,7 +158,7 @@ func MaintenanceMenu() {
 }
 
 // Add a new maintenance record
-func addMaintenance() {
+func addMaintenance(repo Repository, trails Trail.Repository) {
 	var record Maintenance
 	reader := bufio.NewReader(os.Stdin)
 
@@ -125,12 +170,17 @@ func addMaintenance() {
 		fmt.Println("Trail name cannot be empty.")
 		return
 	}
+	trail, ok := Trail.ChooseByName(trails, record.TrailName)
+	if !ok {
+		return
+	}
+	record.TrailID, record.TrailName = trail.ID, trail.Name
 
 	// Get date with validation
 	fmt.Print("Enter maintenance date (YYYY-MM-DD): ")
 	record.Date, _ = reader.ReadString('\n')
 	record.Date = strings.TrimSpace(record.Date)
-	if !isValidDate(record.Date) {
+	if !Trail.ValidDate(record.Date) {
 		fmt.Println("Invalid date format. Please use YYYY-MM-DD.")
 		return
 	}
@@ -144,13 +194,47 @@ func addMaintenance() {
 		return
 	}
 
+	// Get the work order details
+	var err error
+	fmt.Printf("Enter priority (%s; blank for %s): ", JoinPriorities(), Normal)
+	if record.Priority, err = readPriority(reader, Normal); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print("Enter a description of the work (blank for none): ")
+	record.Description = readLine(reader)
+	fmt.Print("Enter the crew assigned (blank for none yet): ")
+	record.Crew = readLine(reader)
+	fmt.Print("Enter the estimated hours (blank if not known): ")
+	if record.EstimatedHours, err = readHours(reader, 0); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Enter status (%s; blank for %s): ", JoinWorkStatuses(WorkStatuses[:4]), Requested)
+	text := readLine(reader)
+	record.Status = Requested
+	if text != "" {
+		if record.Status, err = ParseWorkStatus(text); err != nil || record.Status == Cancelled {
+			fmt.Println("Invalid status for a new work order:", text)
+			return
+		}
+	}
+	if record.Status == Done {
+		if !readCompletion(reader, &record) {
+			return
+		}
+	}
+
 	// Add the maintenance record
-	MaintenanceRecords = append(MaintenanceRecords, record)
+	if _, err := repo.Create(record); err != nil {
+		fmt.Println("Error adding maintenance record:", err)
+		return
+	}
 	fmt.Println("Maintenance record added successfully.")
 }
 
 // Update an existing maintenance record
-func updateMaintenance() {
+func updateMaintenance(repo Repository) {
 	var trailName, date string
 	reader := bufio.NewReader(os.Stdin)
 
@@ -163,39 +247,180 @@ func updateMaintenance() {
 	fmt.Print("Enter the maintenance date (YYYY-MM-DD): ")
 	date, _ = reader.ReadString('\n')
 	date = strings.TrimSpace(date)
-	if !isValidDate(date) {
+	if !Trail.ValidDate(date) {
 		fmt.Println("Invalid date format. Please use YYYY-MM-DD.")
 		return
 	}
 
 	// Find the record by trail name and date
-	for i, record := range MaintenanceRecords {
-		if record.TrailName == trailName && record.Date == date {
-			// Update the maintenance record details
-			fmt.Print("Enter new maintenance type: ")
-			record.Type, _ = reader.ReadString('\n')
-			record.Type = strings.TrimSpace(record.Type)
-			if record.Type == "" {
-				fmt.Println("Maintenance type cannot be empty.")
-				return
-			}
+	record, ok := chooseMaintenance(repo, trailName, date)
+	if !ok {
+		return
+	}
 
-			// Update the record in the MaintenanceRecords slice
-			MaintenanceRecords[i] = record
-			fmt.Println("Maintenance record updated successfully.")
+	// Update the maintenance record details, keeping what is left blank
+	fmt.Printf("Enter new maintenance date (YYYY-MM-DD, blank to keep %s): ", record.Date)
+	if text := readLine(reader); text != "" {
+		if !Trail.ValidDate(text) {
+			fmt.Println("Invalid date format. Please use YYYY-MM-DD.")
+			return
+		}
+		record.Date = text
+	}
+	fmt.Printf("Enter new maintenance type (blank to keep %s): ", record.Type)
+	if text := readLine(reader); text != "" {
+		record.Type = text
+	}
+	var err error
+	fmt.Printf("Enter new priority (%s; blank to keep %s): ", JoinPriorities(), record.Priority)
+	if record.Priority, err = readPriority(reader, record.Priority); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print("Enter new description (blank to keep, - to clear): ")
+	record.Description = readKept(reader, record.Description)
+	fmt.Print("Enter new crew (blank to keep, - to clear): ")
+	record.Crew = readKept(reader, record.Crew)
+	fmt.Printf("Enter new estimated hours (blank to keep %g): ", record.EstimatedHours)
+	if record.EstimatedHours, err = readHours(reader, record.EstimatedHours); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	err = codec.UpdateResolving(repo, record, utils.ConfirmOverwrite)
+	if errors.Is(err, Storage.ErrConflict) {
+		fmt.Println("Update cancelled, the maintenance record keeps the other changes.")
+		return
+	}
+	if err != nil {
+		fmt.Println("Error updating maintenance record:", err)
+		return
+	}
+	fmt.Println("Maintenance record updated successfully.")
+}
+
+// changeWorkStatus moves a work order on in its lifecycle, asking for the
+// actual hours and completion notes when it is done and the reason when it
+// is cancelled
+func changeWorkStatus(repo Repository) {
+	reader := bufio.NewReader(os.Stdin)
 
-			// Save the updated data back to the CSV file
-			SaveMaintenanceData("data/maintenance.csv")
+	fmt.Print("Enter the trail name of the work order: ")
+	trailName := readLine(reader)
+	fmt.Print("Enter the maintenance date (YYYY-MM-DD): ")
+	date := readLine(reader)
+	if !Trail.ValidDate(date) {
+		fmt.Println("Invalid date format. Please use YYYY-MM-DD.")
+		return
+	}
+	record, ok := chooseMaintenance(repo, trailName, date)
+	if !ok {
+		return
+	}
+
+	fmt.Printf("The work order is %s.\n", record.Status)
+	next := record.Status.Next()
+	if len(next) == 0 {
+		fmt.Println("The work order is closed, its status cannot change.")
+		return
+	}
+	options := make([]string, len(next))
+	for i, status := range next {
+		options[i] = string(status)
+	}
+	i := utils.Choose("Select the new status", options)
+	if i < 0 {
+		fmt.Println("Operation cancelled.")
+		return
+	}
+	record.Status = next[i]
+	switch record.Status {
+	case Done:
+		if !readCompletion(reader, &record) {
 			return
 		}
+	case Cancelled:
+		fmt.Print("Enter why the work order is cancelled: ")
+		record.Notes = readLine(reader)
 	}
 
-	// If the maintenance record wasn't found
-	fmt.Println("Maintenance record not found.")
+	err := codec.UpdateResolving(repo, record, utils.ConfirmOverwrite)
+	if errors.Is(err, Storage.ErrConflict) {
+		fmt.Println("Status change cancelled, the work order keeps the other changes.")
+		return
+	}
+	if err != nil {
+		fmt.Println("Error changing work order status:", err)
+		return
+	}
+	fmt.Printf("Work order is now %s.\n", record.Status)
+}
+
+// readCompletion asks for the date the work of a record was done on, the
+// hours it took and notes on it
+func readCompletion(reader *bufio.Reader, record *Maintenance) bool {
+	fmt.Printf("Enter the date the work was done (YYYY-MM-DD, blank for %s): ", record.Date)
+	if text := readLine(reader); text != "" {
+		if !Trail.ValidDate(text) {
+			fmt.Println("Invalid date format. Please use YYYY-MM-DD.")
+			return false
+		}
+		record.Date = text
+	}
+	fmt.Print("Enter the actual hours (blank if not known): ")
+	var err error
+	if record.ActualHours, err = readHours(reader, record.ActualHours); err != nil {
+		fmt.Println(err)
+		return false
+	}
+	fmt.Print("Enter completion notes (blank for none): ")
+	record.Notes = readLine(reader)
+	return true
+}
+
+// readLine reads a line without its surrounding spaces
+func readLine(reader *bufio.Reader) string {
+	text, _ := reader.ReadString('\n')
+	return strings.TrimSpace(text)
+}
+
+// readKept reads a line, returning current for a blank one and nothing for
+// a dash
+func readKept(reader *bufio.Reader, current string) string {
+	switch text := readLine(reader); text {
+	case "":
+		return current
+	case "-":
+		return ""
+	default:
+		return text
+	}
+}
+
+// readPriority reads a priority, returning fallback for a blank line
+func readPriority(reader *bufio.Reader, fallback Priority) (Priority, error) {
+	text := readLine(reader)
+	if text == "" {
+		return fallback, nil
+	}
+	return ParsePriority(text)
+}
+
+// readHours reads a number of hours, returning fallback for a blank line
+func readHours(reader *bufio.Reader, fallback float64) (float64, error) {
+	text := readLine(reader)
+	if text == "" {
+		return fallback, nil
+	}
+	hours, err := strconv.ParseFloat(text, 64)
+	if err != nil || hours < 0 {
+		return fallback, fmt.Errorf("invalid number of hours %q", text)
+	}
+	return hours, nil
 }
 
 // Delete an existing maintenance record
-func deleteMaintenance() {
+func deleteMaintenance(repo Repository) {
 	var trailName, date string
 	reader := bufio.NewReader(os.Stdin)
 
@@ -208,13 +433,19 @@ func deleteMaintenance() {
 	fmt.Print("Enter the maintenance date (YYYY-MM-DD): ")
 	date, _ = reader.ReadString('\n')
 	date = strings.TrimSpace(date)
-	if !isValidDate(date) {
+	if !Trail.ValidDate(date) {
 		fmt.Println("Invalid date format. Please use YYYY-MM-DD.")
 		return
 	}
 
+	// Find the record by trail name and date
+	record, ok := chooseMaintenance(repo, trailName, date)
+	if !ok {
+		return
+	}
+
 	// Confirm before deletion
-	fmt.Printf("Are you sure you want to delete the maintenance record for '%s' on '%s'? (y/n): ", trailName, date)
+	fmt.Printf("Are you sure you want to delete the maintenance record for '%s' on '%s'? (y/n): ", record.TrailName, date)
 	var confirmation string
 	fmt.Scanln(&confirmation)
 	if confirmation != "y" {
@@ -222,36 +453,56 @@ func deleteMaintenance() {
 		return
 	}
 
-	// Find and delete the record
-	found := false
-	for i, record := range MaintenanceRecords {
-		if record.TrailName == trailName && record.Date == date {
-			MaintenanceRecords = append(MaintenanceRecords[:i], MaintenanceRecords[i+1:]...)
-			found = true
-			fmt.Println("Maintenance record deleted successfully.")
-			break
-		}
-	}
-
-	if !found {
-		fmt.Println("Maintenance record not found.")
+	// Delete the record
+	if err := repo.Delete(record.ID); err != nil {
+		fmt.Println("Error deleting maintenance record:", err)
 		return
 	}
-
-	// Save the updated data to the CSV file
-	SaveMaintenanceData("data/maintenance.csv")
+	fmt.Println("Maintenance record deleted successfully.")
 }
 
 // View all maintenance records
-func viewMaintenanceRecords() {
-	if len(MaintenanceRecords) == 0 {
+func viewMaintenanceRecords(repo Repository) {
+	records, err := repo.List()
+	if err != nil {
+		fmt.Println("Error reading maintenance records:", err)
+		return
+	}
+	if len(records) == 0 {
 		fmt.Println("No maintenance records to display.")
 		return
 	}
 
-	fmt.Println("Maintenance Records:")
-	for _, record := range MaintenanceRecords {
-		fmt.Printf("Trail Name: %s, Date: %s, Type: %s\n",
-			record.TrailName, record.Date, record.Type)
+	fmt.Println("Work Orders:")
+	for _, record := range records {
+		fmt.Printf("ID: %s, Trail Name: %s, Date: %s, Type: %s, Priority: %s, Status: %s\n",
+			record.ID, record.TrailName, record.Date, record.Type, record.Priority, record.Status)
+		if record.Description != "" {
+			fmt.Printf("  Description: %s\n", record.Description)
+		}
+		if record.Crew != "" || record.EstimatedHours > 0 || record.ActualHours > 0 {
+			fmt.Printf("  Crew: %s, Estimated Hours: %g, Actual Hours: %g\n", nonEmpty(record.Crew), record.EstimatedHours, record.ActualHours)
+		}
+		for _, stamp := range []struct{ label, at string }{
+			{"Created", record.CreatedAt}, {"Started", record.StartedAt}, {"Completed", record.CompletedAt}, {"Cancelled", record.CancelledAt},
+		} {
+			if stamp.at != "" {
+				fmt.Printf("  %s: %s\n", stamp.label, stamp.at)
+			}
+		}
+		if record.Notes != "" {
+			fmt.Printf("  Notes: %s\n", record.Notes)
+		}
+		if record.PlanID != "" {
+			fmt.Printf("  Generated by plan %s\n", record.PlanID)
+		}
+	}
+}
+
+// nonEmpty returns text, or "none" if it is empty
+func nonEmpty(text string) string {
+	if text == "" {
+		return "none"
 	}
+	return text
 }
@@ -0,0 +1,635 @@
+package Maintenance
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	Trail "project/Trail"
+	"project/utils"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Period is the unit a plan's interval is counted in
+type Period string
+
+// Plan intervals
+const (
+	Days  Period = "days"
+	Weeks Period = "weeks"
+)
+
+// Periods lists every period
+var Periods = []Period{Days, Weeks}
+
+// ParsePeriod returns the period named s, ignoring case and accepting the
+// singular
+func ParsePeriod(s string) (Period, error) {
+	period := Period(strings.ToLower(strings.TrimSpace(s)))
+	if !strings.HasSuffix(string(period), "s") {
+		period += "s"
+	}
+	if !period.Valid() {
+		return "", fmt.Errorf("unknown period %q, use days or weeks", s)
+	}
+	return period, nil
+}
+
+// Valid reports whether p is one of the periods
+func (p Period) Valid() bool {
+	return slices.Contains(Periods, p)
+}
+
+func (p Period) String() string {
+	return string(p)
+}
+
+// Set parses a period, so that a *Period can be used as a flag
+func (p *Period) Set(value string) error {
+	period, err := ParsePeriod(value)
+	if err != nil {
+		return err
+	}
+	*p = period
+	return nil
+}
+
+// DefaultLead is how many days ahead the work plans call for is generated
+// and shown as due, unless told otherwise
+const DefaultLead = 30
+
+const dateLayout = "2006-01-02"
+
+// Plan is recurring preventive maintenance of a trail: work of one type
+// due every so many days or weeks from a start date, optionally only in a
+// season of the year. Plans generate the work orders they call for ahead
+// of time.
+type Plan struct {
+	ID      string `json:"id"`
+	TrailID string `json:"trail_id"`
+	Type    string `json:"type"`
+	Every   int    `json:"every"`
+	Period  Period `json:"period"`
+	// Start is the first day the work is due, End the last day it can be,
+	// empty for a plan that goes on
+	Start string `json:"start"`
+	End   string `json:"end,omitempty"`
+	// SeasonStart and SeasonEnd are the first and last days of the season,
+	// as MM-DD, both empty for work due all year. A season can run over the
+	// new year. Work falling outside it is due when the season opens, and
+	// the interval is counted from there.
+	SeasonStart string `json:"season_start,omitempty"`
+	SeasonEnd   string `json:"season_end,omitempty"`
+	// Priority, Description, Crew and EstimatedHours are given to the work
+	// orders the plan generates
+	Priority       Priority `json:"priority"`
+	Description    string   `json:"description,omitempty"`
+	Crew           string   `json:"crew,omitempty"`
+	EstimatedHours float64  `json:"estimated_hours,omitempty"`
+	// GeneratedThrough is the last day work orders have been generated for,
+	// empty until they first are
+	GeneratedThrough string `json:"generated_through,omitempty"`
+	Version          int    `json:"version"`
+}
+
+// Validate checks the fields of a plan. The priority can be left empty for
+// normal. Whether the trail exists is checked where the plan is stored.
+func (p Plan) Validate() error {
+	switch {
+	case p.TrailID == "":
+		return errors.New("a maintenance plan needs the trail ID")
+	case strings.TrimSpace(p.Type) == "":
+		return errors.New("maintenance type cannot be empty")
+	case p.Every < 1:
+		return errors.New("a maintenance plan must recur every 1 or more days or weeks")
+	case !p.Period.Valid():
+		return fmt.Errorf("unknown period %q, use days or weeks", p.Period)
+	case !Trail.ValidDate(p.Start):
+		return fmt.Errorf("invalid start date %q, please use YYYY-MM-DD", p.Start)
+	case p.End != "" && !Trail.ValidDate(p.End):
+		return fmt.Errorf("invalid end date %q, please use YYYY-MM-DD", p.End)
+	case p.End != "" && p.End < p.Start:
+		return errors.New("the end date cannot be before the start date")
+	case (p.SeasonStart == "") != (p.SeasonEnd == ""):
+		return errors.New("a season needs both its first and last day")
+	case p.Priority != "" && !p.Priority.Valid():
+		return fmt.Errorf("unknown priority %q, use one of: %s", p.Priority, JoinPriorities())
+	case p.EstimatedHours < 0:
+		return errors.New("hours cannot be negative")
+	case p.GeneratedThrough != "" && !Trail.ValidDate(p.GeneratedThrough):
+		return fmt.Errorf("invalid generated through date %q, please use YYYY-MM-DD", p.GeneratedThrough)
+	}
+	for _, day := range []string{p.SeasonStart, p.SeasonEnd} {
+		if _, err := parseSeasonDay(day); day != "" && err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Interval describes how often the work is due, and in which season
+func (p Plan) Interval() string {
+	interval := fmt.Sprintf("every %d %s", p.Every, p.Period)
+	if p.Every == 1 {
+		interval = "every " + strings.TrimSuffix(string(p.Period), "s")
+	}
+	if p.SeasonStart != "" {
+		interval += fmt.Sprintf(" from %s to %s", p.SeasonStart, p.SeasonEnd)
+	}
+	return interval
+}
+
+// parseSeasonDay reads a day of the year written as MM-DD
+func parseSeasonDay(day string) (time.Time, error) {
+	t, err := time.Parse("01-02", day)
+	if err != nil {
+		return t, fmt.Errorf("invalid season day %q, please use MM-DD", day)
+	}
+	return t, nil
+}
+
+// inSeason reports whether the work of p can be due on d
+func (p Plan) inSeason(d time.Time) bool {
+	if p.SeasonStart == "" {
+		return true
+	}
+	day := func(t time.Time) int { return int(t.Month())*100 + t.Day() }
+	from, _ := parseSeasonDay(p.SeasonStart)
+	to, _ := parseSeasonDay(p.SeasonEnd)
+	if day(from) <= day(to) {
+		return day(from) <= day(d) && day(d) <= day(to)
+	}
+	return day(d) >= day(from) || day(d) <= day(to)
+}
+
+// opening returns the first day of the season after d
+func (p Plan) opening(d time.Time) time.Time {
+	from, _ := parseSeasonDay(p.SeasonStart)
+	opening := time.Date(d.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	if !opening.After(d) {
+		opening = time.Date(d.Year()+1, from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return opening
+}
+
+// Occurrences lists the days the work of p is due after the date after,
+// which can be empty, and through the date through, oldest first
+func (p Plan) Occurrences(after, through string) []string {
+	if p.Validate() != nil || !Trail.ValidDate(through) {
+		return nil
+	}
+	if p.End != "" && p.End < through {
+		through = p.End
+	}
+	days := p.Every
+	if p.Period == Weeks {
+		days *= 7
+	}
+
+	var dates []string
+	last, _ := time.Parse(dateLayout, through)
+	for d, _ := time.Parse(dateLayout, p.Start); !d.After(last); {
+		if !p.inSeason(d) {
+			d = p.opening(d)
+			continue
+		}
+		if date := d.Format(dateLayout); date > after {
+			dates = append(dates, date)
+		}
+		d = d.AddDate(0, 0, days)
+	}
+	return dates
+}
+
+// WorkOrders returns the work orders p calls for after the day it was
+// last generated through and through the date through, leaving out the
+// days records already have work of its type on its trail
+func (p Plan) WorkOrders(records []Maintenance, through string) []Maintenance {
+	priority := p.Priority
+	if priority == "" {
+		priority = Normal
+	}
+	var orders []Maintenance
+	for _, date := range p.Occurrences(p.GeneratedThrough, through) {
+		if slices.ContainsFunc(records, func(m Maintenance) bool {
+			return m.TrailID == p.TrailID && m.Date == date && strings.EqualFold(m.Type, p.Type)
+		}) {
+			continue
+		}
+		orders = append(orders, Maintenance{
+			TrailID:        p.TrailID,
+			Date:           date,
+			Type:           p.Type,
+			Priority:       priority,
+			Description:    p.Description,
+			Crew:           p.Crew,
+			EstimatedHours: p.EstimatedHours,
+			Status:         Requested,
+			PlanID:         p.ID,
+		})
+	}
+	return orders
+}
+
+// Generate creates the work orders the plans call for through the date
+// through and records on each plan how far it has been generated. A day
+// that already has work of a plan's type on its trail is left alone, so
+// generating again creates nothing new.
+func Generate(plans PlanRepository, repo Repository, through string) ([]Maintenance, error) {
+	if !Trail.ValidDate(through) {
+		return nil, fmt.Errorf("invalid date %q, please use YYYY-MM-DD", through)
+	}
+	list, err := plans.List()
+	if err != nil {
+		return nil, err
+	}
+	records, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var created []Maintenance
+	for _, plan := range list {
+		for _, order := range plan.WorkOrders(records, through) {
+			order, err := repo.Create(order)
+			if err != nil {
+				return created, err
+			}
+			created = append(created, order)
+			records = append(records, order)
+		}
+		if plan.GeneratedThrough < through {
+			plan.GeneratedThrough = through
+			if err := plans.Update(plan.ID, plan); err != nil {
+				return created, err
+			}
+		}
+	}
+	return created, nil
+}
+
+// DueWork is work due by a date: an open work order, or the next work a
+// plan calls for that has not been generated yet, which has no ID or
+// status
+type DueWork struct {
+	Maintenance
+	// DaysLate counts the days since the work was due, negative when it is
+	// due later
+	DaysLate int `json:"days_late"`
+}
+
+// Overdue reports whether the day the work was due has passed
+func (w DueWork) Overdue() bool {
+	return w.DaysLate > 0
+}
+
+// Due lists the work due by date or through the date through after it,
+// oldest first and the most urgent first on a day: the open work orders,
+// which include the ones overdue, and for each plan the work it calls for
+// that has not been generated, the last day it was missed or else the next
+// day it is due
+func Due(plans []Plan, records []Maintenance, trails []Trail.Trail, date, through string) ([]DueWork, error) {
+	for _, d := range []string{date, through} {
+		if !Trail.ValidDate(d) {
+			return nil, fmt.Errorf("invalid date %q, please use YYYY-MM-DD", d)
+		}
+	}
+	if through < date {
+		return nil, errors.New("the last day to look ahead to cannot be before the day work is due by")
+	}
+	day, _ := time.Parse(dateLayout, date)
+	due := func(m Maintenance) (DueWork, error) {
+		d, err := time.Parse(dateLayout, m.Date)
+		if err != nil {
+			return DueWork{}, fmt.Errorf("maintenance record %s has an invalid date %q", m.ID, m.Date)
+		}
+		return DueWork{Maintenance: m, DaysLate: int(day.Sub(d).Hours() / 24)}, nil
+	}
+
+	var work []DueWork
+	for _, record := range records {
+		if !record.Status.Closed() && record.Date <= through {
+			w, err := due(record)
+			if err != nil {
+				return nil, err
+			}
+			work = append(work, w)
+		}
+	}
+	names := make(map[string]string)
+	for _, trail := range trails {
+		names[trail.ID] = trail.Name
+	}
+	for _, plan := range plans {
+		orders := plan.WorkOrders(records, through)
+		if len(orders) == 0 {
+			continue
+		}
+		next := orders[0]
+		for _, order := range orders {
+			if order.Date <= date {
+				next = order
+			}
+		}
+		next.TrailName, next.Status = names[plan.TrailID], ""
+		w, err := due(next)
+		if err != nil {
+			return nil, err
+		}
+		work = append(work, w)
+	}
+
+	slices.SortStableFunc(work, func(a, b DueWork) int {
+		return cmp.Or(strings.Compare(a.Date, b.Date), cmp.Compare(b.Priority.Rank(), a.Priority.Rank()), strings.Compare(a.TrailName, b.TrailName))
+	})
+	return work, nil
+}
+
+// Plans returns the maintenance plans of a trail
+func Plans(repo PlanRepository, trailID string) ([]Plan, error) {
+	plans, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	var trailPlans []Plan
+	for _, plan := range plans {
+		if plan.TrailID == trailID {
+			trailPlans = append(trailPlans, plan)
+		}
+	}
+	return trailPlans, nil
+}
+
+// Maintenance plans menu for recurring maintenance. generate creates the
+// work orders the plans call for through a date.
+func planMenu(plans PlanRepository, trails Trail.Repository, generate func(through string) ([]Maintenance, error)) {
+	for {
+		fmt.Println("\nMaintenance Plans")
+		fmt.Println("1. Add Plan")
+		fmt.Println("2. Delete Plan")
+		fmt.Println("3. View Plans")
+		fmt.Println("4. Generate Upcoming Work Orders")
+		fmt.Println("5. Back to Maintenance Menu")
+
+		var choice int
+		fmt.Scanln(&choice)
+
+		switch choice {
+		case 1:
+			addPlan(plans, trails)
+		case 2:
+			deletePlan(plans, trails)
+		case 3:
+			viewPlans(plans, trails)
+		case 4:
+			generateWorkOrders(generate)
+		case 5:
+			return
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// Add a maintenance plan for a trail
+func addPlan(plans PlanRepository, trails Trail.Repository) {
+	reader := bufio.NewReader(os.Stdin)
+	var plan Plan
+
+	fmt.Print("Enter trail name: ")
+	name := readLine(reader)
+	trail, ok := Trail.ChooseByName(trails, name)
+	if !ok {
+		return
+	}
+	plan.TrailID = trail.ID
+
+	fmt.Print("Enter maintenance type (e.g., cleaning, inspection): ")
+	if plan.Type = readLine(reader); plan.Type == "" {
+		fmt.Println("Maintenance type cannot be empty.")
+		return
+	}
+	fmt.Print("Repeat every (e.g., 14 days, 2 weeks): ")
+	fields := strings.Fields(readLine(reader))
+	if len(fields) != 2 {
+		fmt.Println("Please give a number and days or weeks.")
+		return
+	}
+	var err error
+	if plan.Every, err = strconv.Atoi(fields[0]); err != nil || plan.Every < 1 {
+		fmt.Println("Invalid interval:", fields[0])
+		return
+	}
+	if plan.Period, err = ParsePeriod(fields[1]); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print("Enter the first day it is due (YYYY-MM-DD, blank for today): ")
+	if plan.Start = readLine(reader); plan.Start == "" {
+		plan.Start = Trail.Today()
+	}
+	fmt.Print("Enter the last day it can be due (YYYY-MM-DD, blank for none): ")
+	plan.End = readLine(reader)
+	fmt.Print("Enter the season it is due in, as MM-DD to MM-DD (blank for all year): ")
+	if text := readLine(reader); text != "" {
+		from, to, found := strings.Cut(text, " to ")
+		if !found {
+			fmt.Println("Please give the season as MM-DD to MM-DD.")
+			return
+		}
+		plan.SeasonStart, plan.SeasonEnd = strings.TrimSpace(from), strings.TrimSpace(to)
+	}
+	fmt.Printf("Enter priority (%s; blank for %s): ", JoinPriorities(), Normal)
+	if plan.Priority, err = readPriority(reader, Normal); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print("Enter a description of the work (blank for none): ")
+	plan.Description = readLine(reader)
+	fmt.Print("Enter the crew assigned (blank for none yet): ")
+	plan.Crew = readLine(reader)
+	fmt.Print("Enter the estimated hours (blank if not known): ")
+	if plan.EstimatedHours, err = readHours(reader, 0); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := plan.Validate(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if _, err := plans.Create(plan); err != nil {
+		fmt.Println("Error adding maintenance plan:", err)
+		return
+	}
+	fmt.Printf("Maintenance plan added: %s of %s %s.\n", plan.Type, trail.Name, plan.Interval())
+}
+
+// choosePlan asks for a trail and picks one of its plans
+func choosePlan(plans PlanRepository, trails Trail.Repository) (Plan, bool) {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("Enter the trail name of the plan: ")
+	name := readLine(reader)
+	trail, ok := Trail.ChooseByName(trails, name)
+	if !ok {
+		return Plan{}, false
+	}
+	trailPlans, err := Plans(plans, trail.ID)
+	if err != nil {
+		fmt.Println("Error reading maintenance plans:", err)
+		return Plan{}, false
+	}
+	if len(trailPlans) == 0 {
+		fmt.Printf("Trail '%s' has no maintenance plans.\n", trail.Name)
+		return Plan{}, false
+	}
+	options := make([]string, len(trailPlans))
+	for i, plan := range trailPlans {
+		options[i] = fmt.Sprintf("%s %s (ID %s)", plan.Type, plan.Interval(), plan.ID)
+	}
+	i := utils.Choose("Select the maintenance plan", options)
+	if i < 0 {
+		fmt.Println("Operation cancelled.")
+		return Plan{}, false
+	}
+	return trailPlans[i], true
+}
+
+// Delete a maintenance plan. The work orders it generated are kept.
+func deletePlan(plans PlanRepository, trails Trail.Repository) {
+	plan, ok := choosePlan(plans, trails)
+	if !ok {
+		return
+	}
+	fmt.Printf("Delete the %s plan? The work orders it generated are kept. (y/n): ", plan.Type)
+	var confirmation string
+	fmt.Scanln(&confirmation)
+	if confirmation != "y" {
+		fmt.Println("Deletion cancelled.")
+		return
+	}
+	if err := plans.Delete(plan.ID); err != nil {
+		fmt.Println("Error deleting maintenance plan:", err)
+		return
+	}
+	fmt.Println("Maintenance plan deleted successfully.")
+}
+
+// View every maintenance plan
+func viewPlans(plans PlanRepository, trails Trail.Repository) {
+	list, err := plans.List()
+	if err != nil {
+		fmt.Println("Error reading maintenance plans:", err)
+		return
+	}
+	if len(list) == 0 {
+		fmt.Println("No maintenance plans to display.")
+		return
+	}
+
+	fmt.Println("Maintenance Plans:")
+	for _, plan := range list {
+		trailName := plan.TrailID
+		if trail, err := trails.Get(plan.TrailID); err == nil {
+			trailName = trail.Name
+		}
+		fmt.Printf("ID: %s, Trail Name: %s, Type: %s, Due: %s, Priority: %s\n", plan.ID, trailName, plan.Type, plan.Interval(), plan.Priority)
+		until := "no end"
+		if plan.End != "" {
+			until = "until " + plan.End
+		}
+		fmt.Printf("  From %s, %s, generated through: %s\n", plan.Start, until, nonEmpty(plan.GeneratedThrough))
+		if plan.Description != "" || plan.Crew != "" {
+			fmt.Printf("  Description: %s, Crew: %s\n", nonEmpty(plan.Description), nonEmpty(plan.Crew))
+		}
+	}
+}
+
+// Generate the work orders the plans call for in the days ahead
+func generateWorkOrders(generate func(through string) ([]Maintenance, error)) {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("Generate work orders for how many days ahead? (blank for %d): ", DefaultLead)
+	days, ok := readDays(reader)
+	if !ok {
+		return
+	}
+	through := time.Now().AddDate(0, 0, days).Format(dateLayout)
+	created, err := generate(through)
+	for _, order := range created {
+		fmt.Printf("Work order %s: %s of %s on %s\n", order.ID, order.Type, order.TrailName, order.Date)
+	}
+	if err != nil {
+		fmt.Println("Error generating work orders:", err)
+		return
+	}
+	fmt.Printf("%d work orders generated through %s.\n", len(created), through)
+}
+
+// readDays reads a number of days, DefaultLead for a blank line
+func readDays(reader *bufio.Reader) (int, bool) {
+	text := readLine(reader)
+	if text == "" {
+		return DefaultLead, true
+	}
+	days, err := strconv.Atoi(text)
+	if err != nil || days < 0 {
+		fmt.Println("Invalid number of days:", text)
+		return 0, false
+	}
+	return days, true
+}
+
+// View the work due in the days ahead and the work overdue
+func viewDueWork(repo Repository, plans PlanRepository, trails Trail.Repository) {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("Show the work due in how many days? (blank for %d): ", DefaultLead)
+	days, ok := readDays(reader)
+	if !ok {
+		return
+	}
+	records, err := repo.List()
+	if err != nil {
+		fmt.Println("Error reading maintenance records:", err)
+		return
+	}
+	planList, err := plans.List()
+	if err != nil {
+		fmt.Println("Error reading maintenance plans:", err)
+		return
+	}
+	trailList, err := trails.List()
+	if err != nil {
+		fmt.Println("Error reading trails:", err)
+		return
+	}
+	through := time.Now().AddDate(0, 0, days).Format(dateLayout)
+	work, err := Due(planList, records, trailList, Trail.Today(), through)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if len(work) == 0 {
+		fmt.Println("No work is due.")
+		return
+	}
+
+	fmt.Println("Work Due:")
+	for _, w := range work {
+		when := "due today"
+		switch {
+		case w.Overdue():
+			when = fmt.Sprintf("OVERDUE by %d days", w.DaysLate)
+		case w.DaysLate < 0:
+			when = fmt.Sprintf("due in %d days", -w.DaysLate)
+		}
+		status := string(w.Status)
+		if w.ID == "" {
+			status = "not generated yet"
+		}
+		fmt.Printf("%s, %s: %s of %s, Priority: %s, Status: %s\n", w.Date, when, w.Type, w.TrailName, w.Priority, status)
+	}
+}
@@ -0,0 +1,247 @@
+package Maintenance
+
+import (
+	"fmt"
+	"project/Storage"
+	"project/utils"
+	"strconv"
+	"strings"
+)
+
+// Repository stores maintenance records, keyed by ID
+type Repository = Storage.Repository[Maintenance]
+
+// MemoryRepository, CSVRepository and SQLRepository are the available
+// Repository implementations
+type (
+	MemoryRepository = Storage.Memory[Maintenance]
+	CSVRepository    = Storage.CSV[Maintenance]
+	SQLRepository    = Storage.SQL[Maintenance]
+)
+
+const idPrefix = "mnt"
+
+var schema = Storage.Schema{
+	Name:    "maintenance",
+	Version: 6,
+	Columns: []string{"id", "trail_id", "trail_name", "date", "type", "priority", "description", "crew", "estimated_hours", "actual_hours", "status", "notes",
+		"created_at", "started_at", "completed_at", "cancelled_at", "plan_id", "version"},
+	Types:   map[string]string{"estimated_hours": "REAL", "actual_hours": "REAL", "version": "INTEGER"},
+	Indexes: []string{"trail_id", "date", "status", "plan_id"},
+	Legacy: func(width int) (int, []string, bool) {
+		switch width {
+		case 3:
+			return 1, []string{"trail_name", "date", "type"}, true
+		case 5:
+			return 2, []string{"id", "trail_id", "trail_name", "date", "type"}, true
+		}
+		return 0, nil, false
+	},
+	Migrations: []Storage.Migration{
+		{To: 2, Description: "give every record a stable id and a trail id", Apply: func(t *Storage.Table) {
+			t.AddColumn("id", func([]string) string { return utils.NewID(idPrefix) })
+			// Filled in by name once the trails are loaded
+			t.AddColumn("trail_id", func([]string) string { return "" })
+		}},
+		{To: 3, Description: "add a schema version marker and header row"},
+		{To: 4, Description: "give every record a version number", Apply: func(t *Storage.Table) {
+			t.AddColumn("version", func([]string) string { return "1" })
+		}},
+		{To: 5, Description: "turn every record into a work order, done and of normal priority", Apply: func(t *Storage.Table) {
+			for _, column := range []string{"priority", "description", "crew", "estimated_hours", "actual_hours", "status", "notes", "created_at", "started_at", "completed_at", "cancelled_at"} {
+				t.AddColumn(column, func([]string) string { return "" })
+			}
+			priority, status := t.Index("priority"), t.Index("status")
+			estimated, actual := t.Index("estimated_hours"), t.Index("actual_hours")
+			for _, row := range t.Rows {
+				row[priority], row[status] = string(Normal), string(Done)
+				row[estimated], row[actual] = "0", "0"
+			}
+		}},
+		{To: 6, Description: "link work orders to the maintenance plan that generated them", Apply: func(t *Storage.Table) {
+			t.AddColumn("plan_id", func([]string) string { return "" })
+		}},
+	},
+}
+
+var codec = Storage.Codec[Maintenance]{
+	Entity:     "maintenance record",
+	Prefix:     idPrefix,
+	Key:        func(m Maintenance) string { return m.ID },
+	SetKey:     func(m Maintenance, id string) Maintenance { m.ID = id; return m },
+	Version:    func(m Maintenance) int { return m.Version },
+	SetVersion: func(m Maintenance, version int) Maintenance { m.Version = version; return m },
+	Schema:     schema,
+	Encode: func(m Maintenance) Storage.Row {
+		return Storage.Row{
+			"id":              m.ID,
+			"trail_id":        m.TrailID,
+			"trail_name":      m.TrailName,
+			"date":            m.Date,
+			"type":            m.Type,
+			"priority":        string(m.Priority),
+			"description":     m.Description,
+			"crew":            m.Crew,
+			"estimated_hours": strconv.FormatFloat(m.EstimatedHours, 'f', -1, 64),
+			"actual_hours":    strconv.FormatFloat(m.ActualHours, 'f', -1, 64),
+			"status":          string(m.Status),
+			"notes":           m.Notes,
+			"created_at":      m.CreatedAt,
+			"started_at":      m.StartedAt,
+			"completed_at":    m.CompletedAt,
+			"cancelled_at":    m.CancelledAt,
+			"plan_id":         m.PlanID,
+			"version":         strconv.Itoa(m.Version),
+		}
+	},
+	Decode: func(row Storage.Row) (Maintenance, error) {
+		version, err := strconv.Atoi(row["version"])
+		if err != nil {
+			return Maintenance{}, fmt.Errorf("invalid version: %w", err)
+		}
+		var hours [2]float64
+		for i, column := range []string{"estimated_hours", "actual_hours"} {
+			if hours[i], err = strconv.ParseFloat(row[column], 64); err != nil {
+				return Maintenance{}, fmt.Errorf("invalid %s %q", strings.ReplaceAll(column, "_", " "), row[column])
+			}
+		}
+		return Maintenance{
+			ID:             row["id"],
+			TrailID:        row["trail_id"],
+			TrailName:      row["trail_name"],
+			Date:           row["date"],
+			Type:           row["type"],
+			Priority:       Priority(row["priority"]),
+			Description:    row["description"],
+			Crew:           row["crew"],
+			EstimatedHours: hours[0],
+			ActualHours:    hours[1],
+			Status:         WorkStatus(row["status"]),
+			Notes:          row["notes"],
+			CreatedAt:      row["created_at"],
+			StartedAt:      row["started_at"],
+			CompletedAt:    row["completed_at"],
+			CancelledAt:    row["cancelled_at"],
+			PlanID:         row["plan_id"],
+			Version:        version,
+		}, nil
+	},
+}
+
+// NewMemoryRepository creates an in-memory repository holding maintenance records
+func NewMemoryRepository(records ...Maintenance) *MemoryRepository {
+	return Storage.NewMemory(codec, records...)
+}
+
+// NewCSVRepository creates a repository backed by the CSV file at filePath
+func NewCSVRepository(filePath string) *CSVRepository {
+	return Storage.NewCSV(filePath, codec)
+}
+
+// NewSQLRepository creates a repository backed by a table in db
+func NewSQLRepository(db *Storage.Database) *SQLRepository {
+	return Storage.NewSQL(db, codec)
+}
+
+// PlanRepository stores maintenance plans, keyed by ID
+type PlanRepository = Storage.Repository[Plan]
+
+// MemoryPlanRepository, CSVPlanRepository and SQLPlanRepository are the
+// available PlanRepository implementations
+type (
+	MemoryPlanRepository = Storage.Memory[Plan]
+	CSVPlanRepository    = Storage.CSV[Plan]
+	SQLPlanRepository    = Storage.SQL[Plan]
+)
+
+const planIDPrefix = "pln"
+
+var planSchema = Storage.Schema{
+	Name:    "maintenance_plans",
+	Version: 1,
+	Columns: []string{"id", "trail_id", "type", "every", "period", "start", "end", "season_start", "season_end", "priority", "description", "crew",
+		"estimated_hours", "generated_through", "version"},
+	Types:   map[string]string{"every": "INTEGER", "estimated_hours": "REAL", "version": "INTEGER"},
+	Indexes: []string{"trail_id"},
+	// Plans were added with the schema version marker, so there are no
+	// files without one
+	Legacy: func(int) (int, []string, bool) { return 0, nil, false },
+}
+
+var planCodec = Storage.Codec[Plan]{
+	Entity:     "maintenance plan",
+	Prefix:     planIDPrefix,
+	Key:        func(p Plan) string { return p.ID },
+	SetKey:     func(p Plan, id string) Plan { p.ID = id; return p },
+	Version:    func(p Plan) int { return p.Version },
+	SetVersion: func(p Plan, version int) Plan { p.Version = version; return p },
+	// A trail has one plan for each type of maintenance
+	Same:       func(a, b Plan) bool { return a.TrailID == b.TrailID && strings.EqualFold(a.Type, b.Type) },
+	SameColumn: "trail_id",
+	Schema:     planSchema,
+	Encode: func(p Plan) Storage.Row {
+		return Storage.Row{
+			"id":                p.ID,
+			"trail_id":          p.TrailID,
+			"type":              p.Type,
+			"every":             strconv.Itoa(p.Every),
+			"period":            string(p.Period),
+			"start":             p.Start,
+			"end":               p.End,
+			"season_start":      p.SeasonStart,
+			"season_end":        p.SeasonEnd,
+			"priority":          string(p.Priority),
+			"description":       p.Description,
+			"crew":              p.Crew,
+			"estimated_hours":   strconv.FormatFloat(p.EstimatedHours, 'f', -1, 64),
+			"generated_through": p.GeneratedThrough,
+			"version":           strconv.Itoa(p.Version),
+		}
+	},
+	Decode: func(row Storage.Row) (Plan, error) {
+		every, err := strconv.Atoi(row["every"])
+		if err != nil {
+			return Plan{}, fmt.Errorf("invalid interval %q", row["every"])
+		}
+		hours, err := strconv.ParseFloat(row["estimated_hours"], 64)
+		if err != nil {
+			return Plan{}, fmt.Errorf("invalid estimated hours %q", row["estimated_hours"])
+		}
+		version, err := strconv.Atoi(row["version"])
+		if err != nil {
+			return Plan{}, fmt.Errorf("invalid version: %w", err)
+		}
+		return Plan{
+			ID:               row["id"],
+			TrailID:          row["trail_id"],
+			Type:             row["type"],
+			Every:            every,
+			Period:           Period(row["period"]),
+			Start:            row["start"],
+			End:              row["end"],
+			SeasonStart:      row["season_start"],
+			SeasonEnd:        row["season_end"],
+			Priority:         Priority(row["priority"]),
+			Description:      row["description"],
+			Crew:             row["crew"],
+			EstimatedHours:   hours,
+			GeneratedThrough: row["generated_through"],
+			Version:          version,
+		}, nil
+	},
+}
+
+// NewMemoryPlanRepository creates an in-memory repository holding maintenance plans
+func NewMemoryPlanRepository(plans ...Plan) *MemoryPlanRepository {
+	return Storage.NewMemory(planCodec, plans...)
+}
+
+// NewCSVPlanRepository creates a maintenance plan repository backed by the CSV file at filePath
+func NewCSVPlanRepository(filePath string) *CSVPlanRepository {
+	return Storage.NewCSV(filePath, planCodec)
+}
+
+// NewSQLPlanRepository creates a maintenance plan repository backed by a table in db
+func NewSQLPlanRepository(db *Storage.Database) *SQLPlanRepository {
+	return Storage.NewSQL(db, planCodec)
+}
@@ -0,0 +1,192 @@
+package Maintenance
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Priority is how urgent a work order is
+type Priority string
+
+// Work order priorities, least urgent first
+const (
+	Low    Priority = "low"
+	Normal Priority = "normal"
+	High   Priority = "high"
+	Urgent Priority = "urgent"
+)
+
+// Priorities lists every priority, least urgent first
+var Priorities = []Priority{Low, Normal, High, Urgent}
+
+// ParsePriority returns the priority named s, ignoring case
+func ParsePriority(s string) (Priority, error) {
+	priority := Priority(strings.ToLower(strings.TrimSpace(s)))
+	if !priority.Valid() {
+		return "", fmt.Errorf("unknown priority %q, use one of: %s", s, JoinPriorities())
+	}
+	return priority, nil
+}
+
+// JoinPriorities lists the priorities separated by commas
+func JoinPriorities() string {
+	names := make([]string, len(Priorities))
+	for i, priority := range Priorities {
+		names[i] = string(priority)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Valid reports whether p is one of the priorities
+func (p Priority) Valid() bool {
+	return slices.Contains(Priorities, p)
+}
+
+// Rank orders priorities, from 1 for low to 4 for urgent
+func (p Priority) Rank() int {
+	return slices.Index(Priorities, p) + 1
+}
+
+func (p Priority) String() string {
+	return string(p)
+}
+
+// Set parses a priority, so that a *Priority can be used as a flag
+func (p *Priority) Set(value string) error {
+	priority, err := ParsePriority(value)
+	if err != nil {
+		return err
+	}
+	*p = priority
+	return nil
+}
+
+// WorkStatus is where a work order is in its lifecycle
+type WorkStatus string
+
+// Work order statuses
+const (
+	Requested  WorkStatus = "requested"
+	Scheduled  WorkStatus = "scheduled"
+	InProgress WorkStatus = "in progress"
+	Done       WorkStatus = "done"
+	Cancelled  WorkStatus = "cancelled"
+)
+
+// WorkStatuses lists every work order status, in lifecycle order
+var WorkStatuses = []WorkStatus{Requested, Scheduled, InProgress, Done, Cancelled}
+
+// workTransitions lists the statuses each status can change to. Done and
+// cancelled work orders are closed for good.
+var workTransitions = map[WorkStatus][]WorkStatus{
+	Requested:  {Scheduled, InProgress, Done, Cancelled},
+	Scheduled:  {Requested, InProgress, Done, Cancelled},
+	InProgress: {Scheduled, Done, Cancelled},
+}
+
+// ErrTransition is returned for a status change a work order's current
+// status does not allow
+var ErrTransition = errors.New("work order status change not allowed")
+
+// ParseWorkStatus returns the work order status named s, ignoring case
+// and accepting dashes or underscores for spaces
+func ParseWorkStatus(s string) (WorkStatus, error) {
+	status := WorkStatus(strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_'
+	}), " "))
+	if !status.Valid() {
+		return "", fmt.Errorf("unknown work order status %q, use one of: %s", s, JoinWorkStatuses(WorkStatuses))
+	}
+	return status, nil
+}
+
+// JoinWorkStatuses lists statuses separated by commas
+func JoinWorkStatuses(statuses []WorkStatus) string {
+	names := make([]string, len(statuses))
+	for i, status := range statuses {
+		names[i] = string(status)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Valid reports whether s is one of the work order statuses
+func (s WorkStatus) Valid() bool {
+	return slices.Contains(WorkStatuses, s)
+}
+
+// CanChangeTo reports whether a work order with status s can change to
+// status to
+func (s WorkStatus) CanChangeTo(to WorkStatus) bool {
+	return slices.Contains(workTransitions[s], to)
+}
+
+// Next lists the statuses s can change to
+func (s WorkStatus) Next() []WorkStatus {
+	return workTransitions[s]
+}
+
+// Closed reports whether a work order with status s is finished with,
+// done or cancelled
+func (s WorkStatus) Closed() bool {
+	return s == Done || s == Cancelled
+}
+
+// Booked reports whether the work of an order with status s takes place on
+// its date: it has been scheduled, started or done
+func (s WorkStatus) Booked() bool {
+	return s == Scheduled || s == InProgress || s == Done
+}
+
+func (s WorkStatus) String() string {
+	return string(s)
+}
+
+// Set parses a work order status, so that a *WorkStatus can be used as a
+// flag
+func (s *WorkStatus) Set(value string) error {
+	status, err := ParseWorkStatus(value)
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
+
+// Timestamp writes a time the way work orders record it
+func Timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// validTimestamp reports whether s is empty or a time as Timestamp writes
+// it
+func validTimestamp(s string) bool {
+	if s == "" {
+		return true
+	}
+	_, err := time.Parse(time.RFC3339, s)
+	return err == nil
+}
+
+// Stamp fills in the time m's status calls for if it does not have it yet,
+// taking at for it: when the work was started, completed or cancelled
+func (m Maintenance) Stamp(at time.Time) Maintenance {
+	now := Timestamp(at)
+	switch m.Status {
+	case InProgress:
+		if m.StartedAt == "" {
+			m.StartedAt = now
+		}
+	case Done:
+		if m.CompletedAt == "" {
+			m.CompletedAt = now
+		}
+	case Cancelled:
+		if m.CancelledAt == "" {
+			m.CancelledAt = now
+		}
+	}
+	return m
+}
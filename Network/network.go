@@ -0,0 +1,390 @@
+package Network
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"project/Maintenance"
+	"project/Storage"
+	Trail "project/Trail"
+	"project/utils"
+	"slices"
+	"strings"
+)
+
+// Junction is a point where trail segments meet: a trailhead, a fork or
+// a summit
+type Junction struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Coordinates is nil when the position is not known
+	Coordinates *Trail.Coordinates `json:"coordinates,omitempty"`
+	Version     int                `json:"version"`
+}
+
+// Validate checks the fields of a junction
+func (j Junction) Validate() error {
+	switch {
+	case strings.TrimSpace(j.Name) == "":
+		return errors.New("junction name cannot be empty")
+	case j.Coordinates != nil && j.Coordinates.Validate() != nil:
+		return fmt.Errorf("invalid coordinates: %w", j.Coordinates.Validate())
+	}
+	return nil
+}
+
+func (j Junction) String() string {
+	return j.Name
+}
+
+// Segment is a stretch of a trail between two junctions. A trail is made
+// up of its segments; it can be walked along a segment either way.
+type Segment struct {
+	ID      string `json:"id"`
+	TrailID string `json:"trail_id"`
+	// From and To are the IDs of the junctions at either end. They are the
+	// same for a segment that loops back to where it starts.
+	From    string         `json:"from"`
+	To      string         `json:"to"`
+	Length  Trail.Distance `json:"length"`
+	Version int            `json:"version"`
+}
+
+// Validate checks the fields of a segment. Whether the trail and junctions
+// exist is checked where the segment is stored.
+func (s Segment) Validate() error {
+	switch {
+	case s.TrailID == "":
+		return errors.New("a segment needs the trail ID")
+	case s.From == "" || s.To == "":
+		return errors.New("a segment needs the junctions at both ends")
+	case !s.Length.Valid():
+		return fmt.Errorf("unknown length unit %q, use mi or km", s.Length.Unit)
+	case s.Length.Value <= 0:
+		return errors.New("segment length must be a positive number")
+	}
+	return nil
+}
+
+// other returns the junction at the other end of s from junction at
+func (s Segment) other(at string) string {
+	if s.From == at {
+		return s.To
+	}
+	return s.From
+}
+
+// Network menu for managing junctions and segments and planning routes.
+// Lengths are shown in units, and read in them when no unit is given.
+func NetworkMenu(junctions JunctionRepository, segments SegmentRepository, trails Trail.Repository, maintenance Maintenance.Repository, history Trail.HistoryRepository, schedule Trail.ScheduleRepository, units Trail.Units) {
+	for {
+		fmt.Println("\nTrail Network")
+		fmt.Println("1. Add Junction")
+		fmt.Println("2. Add Segment")
+		fmt.Println("3. Delete Junction")
+		fmt.Println("4. Delete Segment")
+		fmt.Println("5. View Network")
+		fmt.Println("6. Plan Route")
+		fmt.Println("7. Back to Main Menu")
+
+		var choice int
+		fmt.Scanln(&choice)
+
+		switch choice {
+		case 1:
+			addJunction(junctions)
+		case 2:
+			addSegment(segments, junctions, trails, units)
+		case 3:
+			deleteJunction(junctions)
+		case 4:
+			deleteSegment(segments, junctions, trails, units)
+		case 5:
+			viewNetwork(junctions, segments, trails, units)
+		case 6:
+			planRoute(junctions, segments, trails, maintenance, history, schedule, units)
+		case 7:
+			return
+		default:
+			fmt.Println("Invalid option.")
+		}
+	}
+}
+
+// Add a new junction
+func addJunction(repo JunctionRepository) {
+	reader := bufio.NewReader(os.Stdin)
+	var junction Junction
+
+	fmt.Print("Enter junction name: ")
+	junction.Name, _ = reader.ReadString('\n')
+	junction.Name = strings.TrimSpace(junction.Name)
+	if junction.Name == "" {
+		fmt.Println("Junction name cannot be empty.")
+		return
+	}
+
+	fmt.Print("Enter coordinates (latitude,longitude, blank if not known): ")
+	text, _ := reader.ReadString('\n')
+	if text = strings.TrimSpace(text); text != "" {
+		coordinates, err := Trail.ParseCoordinates(text)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		junction.Coordinates = &coordinates
+	}
+
+	if _, err := repo.Create(junction); err != nil {
+		fmt.Println("Error adding junction:", err)
+		return
+	}
+	fmt.Println("Junction added successfully.")
+}
+
+// readJunction asks for a junction by name and looks it up
+func readJunction(repo JunctionRepository, reader *bufio.Reader, prompt string) (Junction, bool) {
+	fmt.Print(prompt)
+	name, _ := reader.ReadString('\n')
+	junction, err := FindJunction(repo, strings.TrimSpace(name))
+	if errors.Is(err, Storage.ErrNotFound) {
+		fmt.Println("Junction not found.")
+		return junction, false
+	}
+	if err != nil {
+		fmt.Println("Error reading junctions:", err)
+		return junction, false
+	}
+	return junction, true
+}
+
+// Add a segment of a trail between two junctions
+func addSegment(repo SegmentRepository, junctions JunctionRepository, trails Trail.Repository, units Trail.Units) {
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Print("Enter trail name: ")
+	name, _ := reader.ReadString('\n')
+	name = strings.TrimSpace(name)
+	trail, ok := Trail.ChooseByName(trails, name)
+	if !ok {
+		return
+	}
+	from, ok := readJunction(junctions, reader, "Enter the junction the segment starts at: ")
+	if !ok {
+		return
+	}
+	to, ok := readJunction(junctions, reader, "Enter the junction the segment ends at: ")
+	if !ok {
+		return
+	}
+
+	// A trail made of one segment is as long as the segment
+	fmt.Printf("Enter segment length (%s if no unit, blank for the trail's %s): ", units.Distance(), trail.Length.Format(units))
+	text, _ := reader.ReadString('\n')
+	length := trail.Length
+	if text = strings.TrimSpace(text); text != "" {
+		var err error
+		if length, err = Trail.ParseDistance(text, units.Distance()); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	segment := Segment{TrailID: trail.ID, From: from.ID, To: to.ID, Length: length}
+	if _, err := repo.Create(segment); err != nil {
+		fmt.Println("Error adding segment:", err)
+		return
+	}
+	fmt.Println("Segment added successfully.")
+}
+
+// Delete a junction no segment uses
+func deleteJunction(repo JunctionRepository) {
+	reader := bufio.NewReader(os.Stdin)
+	junction, ok := readJunction(repo, reader, "Enter the name of the junction to delete: ")
+	if !ok {
+		return
+	}
+
+	fmt.Printf("Are you sure you want to delete the junction '%s'? (y/n): ", junction.Name)
+	var confirmation string
+	fmt.Scanln(&confirmation)
+	if confirmation != "y" {
+		fmt.Println("Delete operation cancelled.")
+		return
+	}
+	if err := repo.Delete(junction.ID); err != nil {
+		fmt.Println("Error deleting junction:", err)
+		return
+	}
+	fmt.Println("Junction deleted successfully.")
+}
+
+// Delete one of the segments of a trail
+func deleteSegment(repo SegmentRepository, junctions JunctionRepository, trails Trail.Repository, units Trail.Units) {
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Print("Enter the name of the trail: ")
+	name, _ := reader.ReadString('\n')
+	name = strings.TrimSpace(name)
+	trail, ok := Trail.ChooseByName(trails, name)
+	if !ok {
+		return
+	}
+	trailSegments, err := Segments(repo, trail.ID)
+	if err != nil {
+		fmt.Println("Error reading segments:", err)
+		return
+	}
+	if len(trailSegments) == 0 {
+		fmt.Println("The trail has no segments.")
+		return
+	}
+
+	names := junctionNames(junctions)
+	options := make([]string, len(trailSegments))
+	for i, segment := range trailSegments {
+		options[i] = fmt.Sprintf("%s to %s, %s", names(segment.From), names(segment.To), segment.Length.Format(units))
+	}
+	i := utils.Choose("Select the segment to delete", options)
+	if i < 0 {
+		fmt.Println("Delete operation cancelled.")
+		return
+	}
+	if err := repo.Delete(trailSegments[i].ID); err != nil {
+		fmt.Println("Error deleting segment:", err)
+		return
+	}
+	fmt.Println("Segment deleted successfully.")
+}
+
+// junctionNames returns a function giving the name of a junction, or its
+// ID if it cannot be read
+func junctionNames(repo JunctionRepository) func(id string) string {
+	junctions, _ := repo.List()
+	names := make(map[string]string, len(junctions))
+	for _, junction := range junctions {
+		names[junction.ID] = junction.Name
+	}
+	return func(id string) string {
+		if name, ok := names[id]; ok {
+			return name
+		}
+		return id
+	}
+}
+
+// View the junctions and the segments meeting at each
+func viewNetwork(junctions JunctionRepository, segments SegmentRepository, trails Trail.Repository, units Trail.Units) {
+	junctionRecords, err := junctions.List()
+	if err != nil {
+		fmt.Println("Error reading junctions:", err)
+		return
+	}
+	if len(junctionRecords) == 0 {
+		fmt.Println("No junctions to display.")
+		return
+	}
+	names := junctionNames(junctions)
+
+	fmt.Println("Trail Network:")
+	for _, junction := range junctionRecords {
+		if junction.Coordinates != nil {
+			fmt.Printf("%s (%s)\n", junction.Name, junction.Coordinates)
+		} else {
+			fmt.Println(junction.Name)
+		}
+		at, err := AtJunction(segments, junction.ID)
+		if err != nil {
+			fmt.Println("Error reading segments:", err)
+			return
+		}
+		for _, segment := range at {
+			trailName := segment.TrailID
+			if trail, err := trails.Get(segment.TrailID); err == nil {
+				trailName = trail.Name
+			}
+			fmt.Printf("  %s to %s, %s\n", trailName, names(segment.other(junction.ID)), segment.Length.Format(units))
+		}
+	}
+}
+
+// Plan routes from a junction, avoiding the trails closed or under
+// maintenance on the day
+func planRoute(junctions JunctionRepository, segments SegmentRepository, trails Trail.Repository, maintenance Maintenance.Repository, history Trail.HistoryRepository, schedule Trail.ScheduleRepository, units Trail.Units) {
+	reader := bufio.NewReader(os.Stdin)
+	var request RouteRequest
+
+	from, ok := readJunction(junctions, reader, "Enter the junction to start at: ")
+	if !ok {
+		return
+	}
+	request.From = from.ID
+
+	fmt.Print("Enter the junction to finish at (blank for a loop): ")
+	text, _ := reader.ReadString('\n')
+	if text = strings.TrimSpace(text); text != "" {
+		to, err := FindJunction(junctions, text)
+		if err != nil {
+			fmt.Println("Junction not found.")
+			return
+		}
+		request.To = to.ID
+	}
+
+	fmt.Printf("Enter the distance to aim for (%s if no unit, blank for the shortest routes): ", units.Distance())
+	text, _ = reader.ReadString('\n')
+	if text = strings.TrimSpace(text); text != "" {
+		var err error
+		if request.Distance, err = Trail.ParseDistance(text, units.Distance()); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	fmt.Print("Enter the hardest difficulty to include (blank for any): ")
+	text, _ = reader.ReadString('\n')
+	if text = strings.TrimSpace(text); text != "" {
+		var err error
+		if request.MaxDifficulty, err = Trail.ParseDifficulty(text); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	fmt.Print("Enter the date of the hike (YYYY-MM-DD, blank for today): ")
+	date, _ := reader.ReadString('\n')
+	if date = strings.TrimSpace(date); date == "" {
+		date = Trail.Today()
+	} else if !Trail.ValidDate(date) {
+		fmt.Println("Invalid date format. Please use YYYY-MM-DD.")
+		return
+	}
+
+	graph, err := BuildGraph(junctions, segments, trails, maintenance, history, schedule, date)
+	if err != nil {
+		fmt.Println("Error reading network:", err)
+		return
+	}
+	routes, err := graph.Plan(request)
+	if err != nil {
+		fmt.Println("Error planning route:", err)
+		return
+	}
+	if len(routes) == 0 {
+		fmt.Println("No route found.")
+	}
+	for i, route := range routes {
+		fmt.Printf("\nRoute %d: %s, %s\n", i+1, route.Length.Format(units), route.Difficulty)
+		for _, step := range route.Steps {
+			fmt.Printf("  %s from %s to %s, %s\n", step.Trail, graph.Junctions[step.From].Name, graph.Junctions[step.To].Name, step.Segment.Length.Format(units))
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(graph.Closed)) {
+		if trail, ok := graph.Trails[id]; ok {
+			fmt.Printf("Avoiding %s (%s)\n", trail.Name, graph.Closed[id])
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package Network
+
+import (
+	"fmt"
+	"project/Storage"
+	Trail "project/Trail"
+	"strconv"
+	"strings"
+)
+
+// JunctionRepository stores the junctions of the network, keyed by ID
+type JunctionRepository = Storage.Repository[Junction]
+
+// MemoryJunctionRepository, CSVJunctionRepository and SQLJunctionRepository
+// are the available JunctionRepository implementations
+type (
+	MemoryJunctionRepository = Storage.Memory[Junction]
+	CSVJunctionRepository    = Storage.CSV[Junction]
+	SQLJunctionRepository    = Storage.SQL[Junction]
+)
+
+const junctionIDPrefix = "jct"
+
+var junctionSchema = Storage.Schema{
+	Name:    "junctions",
+	Version: 1,
+	Columns: []string{"id", "name", "latitude", "longitude", "version"},
+	Types:   map[string]string{"version": "INTEGER"},
+	Indexes: []string{"name"},
+	// Junctions were added with the schema version marker, so there are no
+	// files without one
+	Legacy: func(int) (int, []string, bool) { return 0, nil, false },
+}
+
+var junctionCodec = Storage.Codec[Junction]{
+	Entity:     "junction",
+	Prefix:     junctionIDPrefix,
+	Key:        func(j Junction) string { return j.ID },
+	SetKey:     func(j Junction, id string) Junction { j.ID = id; return j },
+	Version:    func(j Junction) int { return j.Version },
+	SetVersion: func(j Junction, version int) Junction { j.Version = version; return j },
+	// Junctions are picked by name, so no two share one
+	Same:   func(a, b Junction) bool { return strings.EqualFold(a.Name, b.Name) },
+	Schema: junctionSchema,
+	Encode: func(j Junction) Storage.Row {
+		// Junctions without known coordinates leave them blank
+		var latitude, longitude string
+		if j.Coordinates != nil {
+			latitude = strconv.FormatFloat(j.Coordinates.Latitude, 'f', -1, 64)
+			longitude = strconv.FormatFloat(j.Coordinates.Longitude, 'f', -1, 64)
+		}
+		return Storage.Row{
+			"id":        j.ID,
+			"name":      j.Name,
+			"latitude":  latitude,
+			"longitude": longitude,
+			"version":   strconv.Itoa(j.Version),
+		}
+	},
+	Decode: func(row Storage.Row) (Junction, error) {
+		junction := Junction{ID: row["id"], Name: row["name"]}
+		if row["latitude"] != "" || row["longitude"] != "" {
+			coordinates, err := Trail.ParseCoordinates(row["latitude"] + "," + row["longitude"])
+			if err != nil {
+				return Junction{}, fmt.Errorf("invalid coordinates: %w", err)
+			}
+			junction.Coordinates = &coordinates
+		}
+		version, err := strconv.Atoi(row["version"])
+		if err != nil {
+			return Junction{}, fmt.Errorf("invalid version: %w", err)
+		}
+		junction.Version = version
+		return junction, nil
+	},
+}
+
+// NewMemoryJunctionRepository creates an in-memory repository holding junctions
+func NewMemoryJunctionRepository(junctions ...Junction) *MemoryJunctionRepository {
+	return Storage.NewMemory(junctionCodec, junctions...)
+}
+
+// NewCSVJunctionRepository creates a junction repository backed by the CSV file at filePath
+func NewCSVJunctionRepository(filePath string) *CSVJunctionRepository {
+	return Storage.NewCSV(filePath, junctionCodec)
+}
+
+// NewSQLJunctionRepository creates a junction repository backed by a table in db
+func NewSQLJunctionRepository(db *Storage.Database) *SQLJunctionRepository {
+	return Storage.NewSQL(db, junctionCodec)
+}
+
+// SegmentRepository stores the segments of the network, keyed by ID
+type SegmentRepository = Storage.Repository[Segment]
+
+// MemorySegmentRepository, CSVSegmentRepository and SQLSegmentRepository
+// are the available SegmentRepository implementations
+type (
+	MemorySegmentRepository = Storage.Memory[Segment]
+	CSVSegmentRepository    = Storage.CSV[Segment]
+	SQLSegmentRepository    = Storage.SQL[Segment]
+)
+
+const segmentIDPrefix = "seg"
+
+var segmentSchema = Storage.Schema{
+	Name:    "segments",
+	Version: 1,
+	Columns: []string{"id", "trail_id", "from_junction", "to_junction", "length", "length_unit", "version"},
+	Types:   map[string]string{"length": "REAL", "version": "INTEGER"},
+	Indexes: []string{"trail_id", "from_junction", "to_junction"},
+	Legacy:  func(int) (int, []string, bool) { return 0, nil, false },
+}
+
+var segmentCodec = Storage.Codec[Segment]{
+	Entity:     "segment",
+	Prefix:     segmentIDPrefix,
+	Key:        func(s Segment) string { return s.ID },
+	SetKey:     func(s Segment, id string) Segment { s.ID = id; return s },
+	Version:    func(s Segment) int { return s.Version },
+	SetVersion: func(s Segment, version int) Segment { s.Version = version; return s },
+	// A trail runs between two junctions once, whichever way it is entered
+	Same: func(a, b Segment) bool {
+		return a.TrailID == b.TrailID && (a.From == b.From && a.To == b.To || a.From == b.To && a.To == b.From)
+	},
+	SameColumn: "trail_id",
+	Schema:     segmentSchema,
+	Encode: func(s Segment) Storage.Row {
+		unit := s.Length.Unit
+		if unit == "" {
+			unit = Trail.Mile
+		}
+		return Storage.Row{
+			"id":            s.ID,
+			"trail_id":      s.TrailID,
+			"from_junction": s.From,
+			"to_junction":   s.To,
+			"length":        strconv.FormatFloat(s.Length.Value, 'f', -1, 64),
+			"length_unit":   string(unit),
+			"version":       strconv.Itoa(s.Version),
+		}
+	},
+	Decode: func(row Storage.Row) (Segment, error) {
+		length, err := Trail.ParseDistance(row["length"]+" "+row["length_unit"], Trail.Mile)
+		if err != nil {
+			return Segment{}, fmt.Errorf("invalid segment length: %w", err)
+		}
+		version, err := strconv.Atoi(row["version"])
+		if err != nil {
+			return Segment{}, fmt.Errorf("invalid version: %w", err)
+		}
+		return Segment{
+			ID:      row["id"],
+			TrailID: row["trail_id"],
+			From:    row["from_junction"],
+			To:      row["to_junction"],
+			Length:  length,
+			Version: version,
+		}, nil
+	},
+}
+
+// NewMemorySegmentRepository creates an in-memory repository holding segments
+func NewMemorySegmentRepository(segments ...Segment) *MemorySegmentRepository {
+	return Storage.NewMemory(segmentCodec, segments...)
+}
+
+// NewCSVSegmentRepository creates a segment repository backed by the CSV file at filePath
+func NewCSVSegmentRepository(filePath string) *CSVSegmentRepository {
+	return Storage.NewCSV(filePath, segmentCodec)
+}
+
+// NewSQLSegmentRepository creates a segment repository backed by a table in db
+func NewSQLSegmentRepository(db *Storage.Database) *SQLSegmentRepository {
+	return Storage.NewSQL(db, segmentCodec)
+}
+
+// FindJunction returns the junction with the given ID or name, the name
+// compared ignoring case
+func FindJunction(repo JunctionRepository, idOrName string) (Junction, error) {
+	junctions, err := repo.List()
+	if err != nil {
+		return Junction{}, err
+	}
+	for _, junction := range junctions {
+		if junction.ID == idOrName || strings.EqualFold(junction.Name, strings.TrimSpace(idOrName)) {
+			return junction, nil
+		}
+	}
+	return Junction{}, &Storage.RecordError{Entity: junctionCodec.Entity, Key: idOrName, Err: Storage.ErrNotFound}
+}
+
+// Segments returns the segments of a trail
+func Segments(repo SegmentRepository, trailID string) ([]Segment, error) {
+	segments, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	var trailSegments []Segment
+	for _, segment := range segments {
+		if segment.TrailID == trailID {
+			trailSegments = append(trailSegments, segment)
+		}
+	}
+	return trailSegments, nil
+}
+
+// AtJunction returns the segments that start or end at a junction
+func AtJunction(repo SegmentRepository, junctionID string) ([]Segment, error) {
+	segments, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	var at []Segment
+	for _, segment := range segments {
+		if segment.From == junctionID || segment.To == junctionID {
+			at = append(at, segment)
+		}
+	}
+	return at, nil
+}
@@ -0,0 +1,356 @@
+package Network
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"project/Maintenance"
+	"project/Status"
+	Trail "project/Trail"
+	"slices"
+	"strings"
+)
+
+// Graph is the trail network as the route planner sees it on one date:
+// the junctions, and the segments of the trails that can be walked
+type Graph struct {
+	Junctions map[string]Junction
+	Trails    map[string]Trail.Trail
+	// Closed maps the trails that are left out on the date to the reason
+	Closed map[string]string
+	// links lists the segments at each junction
+	links map[string][]Segment
+}
+
+// Closures returns the trails that cannot be used on date, YYYY-MM-DD,
+// with the reason: those whose status that day is not passable, those with
+// maintenance scheduled or done on the day, and those with maintenance in
+// progress since the day or before
+func Closures(trails Trail.Repository, maintenance Maintenance.Repository, history Trail.HistoryRepository, schedule Trail.ScheduleRepository, date string) (map[string]string, error) {
+	summaries, err := Status.Summaries(trails, maintenance, history, schedule, date)
+	if err != nil {
+		return nil, err
+	}
+	records, err := maintenance.List()
+	if err != nil {
+		return nil, fmt.Errorf("reading maintenance records: %w", err)
+	}
+
+	closed := make(map[string]string)
+	for _, summary := range summaries {
+		if !summary.Status.Passable() {
+			closed[summary.TrailID] = string(summary.Status)
+			if summary.Reason != "" {
+				closed[summary.TrailID] += ": " + summary.Reason
+			}
+		}
+	}
+	for _, record := range records {
+		if _, ok := closed[record.TrailID]; ok || record.TrailID == "" {
+			continue
+		}
+		// Work that has started goes on until it is done, whatever date it
+		// was planned for
+		underway := record.Status == Maintenance.InProgress && record.Date <= date
+		if underway || record.Date == date && record.Status.Booked() {
+			closed[record.TrailID] = "under maintenance: " + record.Type
+		}
+	}
+	return closed, nil
+}
+
+// BuildGraph reads the network and leaves out the trails Closures returns
+// for date
+func BuildGraph(junctions JunctionRepository, segments SegmentRepository, trails Trail.Repository, maintenance Maintenance.Repository, history Trail.HistoryRepository, schedule Trail.ScheduleRepository, date string) (*Graph, error) {
+	junctionRecords, err := junctions.List()
+	if err != nil {
+		return nil, fmt.Errorf("reading junctions: %w", err)
+	}
+	segmentRecords, err := segments.List()
+	if err != nil {
+		return nil, fmt.Errorf("reading segments: %w", err)
+	}
+	trailRecords, err := trails.List()
+	if err != nil {
+		return nil, fmt.Errorf("reading trails: %w", err)
+	}
+	closed, err := Closures(trails, maintenance, history, schedule, date)
+	if err != nil {
+		return nil, err
+	}
+	return NewGraph(junctionRecords, segmentRecords, trailRecords, closed), nil
+}
+
+// NewGraph builds the graph of the segments whose trail and junctions are
+// known and whose trail is not closed
+func NewGraph(junctions []Junction, segments []Segment, trails []Trail.Trail, closed map[string]string) *Graph {
+	g := &Graph{
+		Junctions: make(map[string]Junction, len(junctions)),
+		Trails:    make(map[string]Trail.Trail, len(trails)),
+		Closed:    closed,
+		links:     make(map[string][]Segment),
+	}
+	for _, junction := range junctions {
+		g.Junctions[junction.ID] = junction
+	}
+	for _, trail := range trails {
+		g.Trails[trail.ID] = trail
+	}
+	for _, segment := range segments {
+		_, from := g.Junctions[segment.From]
+		_, to := g.Junctions[segment.To]
+		_, known := g.Trails[segment.TrailID]
+		if _, isClosed := closed[segment.TrailID]; !from || !to || !known || isClosed {
+			continue
+		}
+		g.links[segment.From] = append(g.links[segment.From], segment)
+		if segment.To != segment.From {
+			g.links[segment.To] = append(g.links[segment.To], segment)
+		}
+	}
+	return g
+}
+
+// RouteRequest describes the routes to plan
+type RouteRequest struct {
+	// From is the junction the route starts at and To the one it ends at.
+	// Without To the route is a loop back to From.
+	From, To string
+	// Distance is the length to aim for. Without it a point-to-point route
+	// is the shortest one, and loops are listed shortest first.
+	Distance Trail.Distance
+	// Tolerance is how far a route may be from Distance, as a fraction of
+	// it, 0.2 when not given
+	Tolerance float64
+	// MaxDifficulty leaves out the trails rated harder, on the standard
+	// scale. Without it every trail can be used.
+	MaxDifficulty Trail.Difficulty
+	// Limit is the most routes returned, 5 when not given
+	Limit int
+}
+
+// Step is one segment of a route, walked from one junction to the next
+type Step struct {
+	Segment Segment `json:"segment"`
+	Trail   string  `json:"trail"`
+	From    string  `json:"from"`
+	To      string  `json:"to"`
+}
+
+// Route is a way through the network
+type Route struct {
+	From   string         `json:"from"`
+	To     string         `json:"to"`
+	Loop   bool           `json:"loop"`
+	Steps  []Step         `json:"steps"`
+	Length Trail.Distance `json:"length"`
+	// Difficulty is that of the hardest trail on the route, on the
+	// standard scale
+	Difficulty Trail.Difficulty `json:"difficulty"`
+}
+
+// Trails lists the names of the trails a route follows, in order, each
+// once for every stretch along it
+func (r Route) Trails() []string {
+	var names []string
+	for _, step := range r.Steps {
+		if len(names) == 0 || names[len(names)-1] != step.Trail {
+			names = append(names, step.Trail)
+		}
+	}
+	return names
+}
+
+// ErrNoJunction is returned for a route from or to a junction not in the
+// network
+var ErrNoJunction = errors.New("junction not in the network")
+
+// maxExpansions bounds the search for routes of a target distance, which
+// grows quickly with the size of the network
+const maxExpansions = 200000
+
+// Plan finds routes through g. Segments of closed trails are never used,
+// and a route never walks a segment twice or passes a junction twice,
+// except that a loop ends where it starts. Routes are returned closest to
+// the requested distance first.
+func (g *Graph) Plan(request RouteRequest) ([]Route, error) {
+	if _, ok := g.Junctions[request.From]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoJunction, request.From)
+	}
+	if _, ok := g.Junctions[request.To]; request.To != "" && !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoJunction, request.To)
+	}
+	if request.MaxDifficulty != "" && !request.MaxDifficulty.Valid() {
+		return nil, fmt.Errorf("unknown difficulty %q, use %s", request.MaxDifficulty, Trail.DifficultyHelp)
+	}
+	if !request.Distance.Valid() || request.Distance.Value < 0 {
+		return nil, errors.New("the distance must be a positive number of mi or km")
+	}
+	if request.Tolerance <= 0 {
+		request.Tolerance = 0.2
+	}
+	if request.Limit <= 0 {
+		request.Limit = 5
+	}
+	if request.To == request.From {
+		request.To = ""
+	}
+
+	allowed := func(segment Segment) bool {
+		return request.MaxDifficulty == "" || g.Trails[segment.TrailID].Difficulty.Rank() <= request.MaxDifficulty.Rank()
+	}
+	if request.To != "" && request.Distance.Value == 0 {
+		route, ok := g.shortest(request.From, request.To, allowed)
+		if !ok {
+			return nil, nil
+		}
+		return []Route{route}, nil
+	}
+	return g.search(request, allowed), nil
+}
+
+// shortest finds the shortest route between two junctions with Dijkstra's
+// algorithm
+func (g *Graph) shortest(from, to string, allowed func(Segment) bool) (Route, bool) {
+	distance := map[string]float64{from: 0}
+	previous := make(map[string]Step)
+	done := make(map[string]bool)
+	for {
+		// Networks are small, so the nearest junction is found by a scan
+		current, best := "", math.Inf(1)
+		for id, d := range distance {
+			if !done[id] && (d < best || d == best && id < current) {
+				current, best = id, d
+			}
+		}
+		if current == "" {
+			return Route{}, false
+		}
+		if current == to {
+			break
+		}
+		done[current] = true
+		for _, segment := range g.links[current] {
+			if !allowed(segment) {
+				continue
+			}
+			next := segment.other(current)
+			d := best + segment.Length.Miles()
+			if old, ok := distance[next]; !done[next] && (!ok || d < old) {
+				distance[next] = d
+				previous[next] = g.step(segment, current)
+			}
+		}
+	}
+
+	var steps []Step
+	for at := to; at != from; at = previous[at].From {
+		steps = append(steps, previous[at])
+	}
+	slices.Reverse(steps)
+	return g.route(from, to, steps), true
+}
+
+// search walks every route from request.From, depth first, keeping those
+// within the tolerance of the distance
+func (g *Graph) search(request RouteRequest, allowed func(Segment) bool) []Route {
+	target := request.Distance.Miles()
+	upper := math.Inf(1)
+	if target > 0 {
+		upper = target * (1 + request.Tolerance)
+	}
+	lower := target * (1 - request.Tolerance)
+
+	var routes []Route
+	seen := make(map[string]bool)
+	visited := map[string]bool{request.From: true}
+	used := make(map[string]bool)
+	var steps []Step
+	expansions := 0
+
+	var walk func(at string, length float64)
+	walk = func(at string, length float64) {
+		for _, segment := range g.links[at] {
+			if expansions >= maxExpansions {
+				return
+			}
+			next := segment.other(at)
+			total := length + segment.Length.Miles()
+			if used[segment.ID] || !allowed(segment) || total > upper {
+				continue
+			}
+			expansions++
+			steps = append(steps, g.step(segment, at))
+			used[segment.ID] = true
+
+			arrived := request.To == "" && next == request.From || next == request.To
+			switch {
+			case arrived:
+				if total >= lower {
+					route := g.route(request.From, next, slices.Clone(steps))
+					// A loop walked the other way round is the same loop
+					if key := route.key(); !seen[key] {
+						seen[key] = true
+						routes = append(routes, route)
+					}
+				}
+			case !visited[next]:
+				visited[next] = true
+				walk(next, total)
+				visited[next] = false
+			}
+
+			used[segment.ID] = false
+			steps = steps[:len(steps)-1]
+		}
+	}
+	walk(request.From, 0)
+
+	slices.SortStableFunc(routes, func(a, b Route) int {
+		da, db := math.Abs(a.Length.Miles()-target), math.Abs(b.Length.Miles()-target)
+		switch {
+		case da != db:
+			return cmp.Compare(da, db)
+		case a.Difficulty.Rank() != b.Difficulty.Rank():
+			return a.Difficulty.Rank() - b.Difficulty.Rank()
+		}
+		return len(a.Steps) - len(b.Steps)
+	})
+	if len(routes) > request.Limit {
+		routes = routes[:request.Limit]
+	}
+	return routes
+}
+
+// step walks segment away from junction at
+func (g *Graph) step(segment Segment, at string) Step {
+	return Step{Segment: segment, Trail: g.Trails[segment.TrailID].Name, From: at, To: segment.other(at)}
+}
+
+// route sums up steps
+func (g *Graph) route(from, to string, steps []Step) Route {
+	route := Route{From: from, To: to, Loop: from == to, Steps: steps}
+	var miles float64
+	rank := 0
+	for _, step := range steps {
+		miles += step.Segment.Length.Miles()
+		rank = max(rank, g.Trails[step.Segment.TrailID].Difficulty.Rank())
+	}
+	// Round away the error of adding up converted lengths
+	route.Length = Trail.Miles(math.Round(miles*1000) / 1000)
+	if rank > 0 {
+		route.Difficulty = Trail.Standard[rank-1]
+	}
+	return route
+}
+
+// key identifies the segments of a route, whichever way they are walked
+func (r Route) key() string {
+	ids := make([]string, len(r.Steps))
+	for i, step := range r.Steps {
+		ids[i] = step.Segment.ID
+	}
+	slices.Sort(ids)
+	return strings.Join(ids, ",")
+}
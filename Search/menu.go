@@ -0,0 +1,149 @@
+package Search
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"project/Maintenance"
+	Trail "project/Trail"
+	Visitor "project/Visitor"
+	"strconv"
+	"strings"
+)
+
+// SearchMenu asks for the filters, order and page size of a search and
+// pages through the trails found
+func SearchMenu(trails Trail.Repository, visitors Visitor.Repository, maintenance Maintenance.Repository, history Trail.HistoryRepository, schedule Trail.ScheduleRepository, units Trail.Units) {
+	reader := bufio.NewReader(os.Stdin)
+	query, ok := readQuery(reader, units)
+	if !ok {
+		return
+	}
+
+	for query.Page = 1; ; {
+		page, err := Trails(trails, visitors, maintenance, history, schedule, query)
+		if err != nil {
+			fmt.Println("Error searching trails:", err)
+			return
+		}
+		if page.Total == 0 {
+			fmt.Println("No trails match the search.")
+			return
+		}
+
+		fmt.Printf("\nTrails %d to %d of %d:\n", (page.Page-1)*page.PageSize+1, (page.Page-1)*page.PageSize+len(page.Results), page.Total)
+		for _, result := range page.Results {
+			trail := result.Trail
+			fmt.Printf("ID: %s, Name: %s, Location: %s, Difficulty: %s, Length: %s, Status: %s\n",
+				trail.ID, trail.Name, trail.Location, trail.Difficulty, trail.Length.Format(units), result.Status)
+			lastMaintained := "never"
+			if result.LastMaintained != "" {
+				lastMaintained = result.LastMaintained
+			}
+			satisfaction := "no visits"
+			if result.Visits > 0 {
+				satisfaction = fmt.Sprintf("%.2f from %d visits", result.Satisfaction, result.Visits)
+			}
+			fmt.Printf("  Last maintained: %s, Satisfaction: %s\n", lastMaintained, satisfaction)
+		}
+		if page.Pages == 1 {
+			return
+		}
+
+		fmt.Printf("Page %d of %d. Enter n for the next page, p for the previous one or anything else to stop: ", page.Page, page.Pages)
+		text, _ := reader.ReadString('\n')
+		switch strings.TrimSpace(strings.ToLower(text)) {
+		case "n":
+			query.Page = min(page.Page+1, page.Pages)
+		case "p":
+			query.Page = max(page.Page-1, 1)
+		default:
+			return
+		}
+	}
+}
+
+// readQuery asks for each part of a query, any of which can be left blank
+func readQuery(reader *bufio.Reader, units Trail.Units) (Query, bool) {
+	var query Query
+	read := func(prompt string) string {
+		fmt.Print(prompt)
+		text, _ := reader.ReadString('\n')
+		return strings.TrimSpace(text)
+	}
+
+	fmt.Println("\nSearch Trails (leave any answer blank to skip it)")
+	query.Text = read("Enter words to find in the name or location: ")
+	if text := read("Enter difficulties, separated by commas (" + Trail.DifficultyHelp + "): "); text != "" {
+		for _, part := range strings.Split(text, ",") {
+			difficulty, err := Trail.ParseDifficulty(part)
+			if err != nil {
+				fmt.Println(err)
+				return query, false
+			}
+			query.Difficulties = append(query.Difficulties, difficulty)
+		}
+	}
+	for _, bound := range []struct {
+		prompt string
+		length *Trail.Distance
+	}{{"Enter the minimum length", &query.MinLength}, {"Enter the maximum length", &query.MaxLength}} {
+		if text := read(fmt.Sprintf("%s (%s without a unit): ", bound.prompt, units.Distance())); text != "" {
+			length, err := Trail.ParseDistance(text, units.Distance())
+			if err != nil {
+				fmt.Println(err)
+				return query, false
+			}
+			*bound.length = length
+		}
+	}
+	if text := read("Enter statuses, separated by commas (" + Trail.JoinStatuses(Trail.Statuses) + "): "); text != "" {
+		for _, part := range strings.Split(text, ",") {
+			status, err := Trail.ParseStatus(part)
+			if err != nil {
+				fmt.Println(err)
+				return query, false
+			}
+			query.Statuses = append(query.Statuses, status)
+		}
+	}
+	query.Region = read("Enter a state or region: ")
+	query.LandUnit = read("Enter a land unit: ")
+	query.MaintainedBefore = read("Last maintained on or before (YYYY-MM-DD): ")
+	query.MaintainedAfter = read("Last maintained on or after (YYYY-MM-DD): ")
+	for _, bound := range []struct {
+		prompt string
+		value  *float64
+	}{{"Enter the minimum average satisfaction (1-5): ", &query.MinSatisfaction}, {"Enter the maximum average satisfaction (1-5): ", &query.MaxSatisfaction}} {
+		if text := read(bound.prompt); text != "" {
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				fmt.Println("Invalid satisfaction:", text)
+				return query, false
+			}
+			*bound.value = value
+		}
+	}
+	if text := read("Sort by (" + JoinSortKeys() + "): "); text != "" {
+		key, err := ParseSortKey(text)
+		if err != nil {
+			fmt.Println(err)
+			return query, false
+		}
+		query.Sort = key
+		query.Descending = read("Descending order? (y/n): ") == "y"
+	}
+	if text := read(fmt.Sprintf("Trails per page (default %d): ", DefaultPageSize)); text != "" {
+		n, err := strconv.Atoi(text)
+		if err != nil || n < 1 {
+			fmt.Println("Invalid number of trails per page:", text)
+			return query, false
+		}
+		query.PageSize = n
+	}
+	if err := query.Validate(); err != nil {
+		fmt.Println(err)
+		return query, false
+	}
+	return query, true
+}
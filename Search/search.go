@@ -0,0 +1,358 @@
+package Search
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"project/Maintenance"
+	"project/Status"
+	Trail "project/Trail"
+	Visitor "project/Visitor"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SortKey is the order search results are listed in
+type SortKey string
+
+// Sort keys
+const (
+	// ByRelevance lists the best matches of the text first, then by name
+	ByRelevance      SortKey = "relevance"
+	ByName           SortKey = "name"
+	ByLength         SortKey = "length"
+	ByDifficulty     SortKey = "difficulty"
+	ByStatus         SortKey = "status"
+	ByLastMaintained SortKey = "last_maintained"
+	BySatisfaction   SortKey = "satisfaction"
+)
+
+// SortKeys lists every sort key
+var SortKeys = []SortKey{ByRelevance, ByName, ByLength, ByDifficulty, ByStatus, ByLastMaintained, BySatisfaction}
+
+// DefaultPageSize is the number of results on a page when the query does
+// not say, and MaxPageSize the most a query can ask for
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 1000
+)
+
+// ParseSortKey returns the sort key named s, ignoring case and accepting
+// dashes or spaces for underscores
+func ParseSortKey(s string) (SortKey, error) {
+	key := SortKey(strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_'
+	}), "_"))
+	if !slices.Contains(SortKeys, key) {
+		return "", fmt.Errorf("unknown sort order %q, use one of: %s", s, JoinSortKeys())
+	}
+	return key, nil
+}
+
+// JoinSortKeys lists the sort keys separated by commas
+func JoinSortKeys() string {
+	names := make([]string, len(SortKeys))
+	for i, key := range SortKeys {
+		names[i] = string(key)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (k SortKey) String() string {
+	return string(k)
+}
+
+// Set parses a sort key, so that a *SortKey can be used as a flag
+func (k *SortKey) Set(value string) error {
+	key, err := ParseSortKey(value)
+	if err != nil {
+		return err
+	}
+	*k = key
+	return nil
+}
+
+// Query selects, orders and pages trails. The zero value of a filter
+// leaves it out, so the zero Query lists every trail by name.
+type Query struct {
+	// Text is searched for in the names and locations of the trails. Every
+	// word of it has to be found.
+	Text string
+	// Difficulties keeps the trails whose difficulty is on the same step
+	// of the standard scale as one of them, so Moderate finds 3 and
+	// Class 2 as well
+	Difficulties []Trail.Difficulty
+	// MinLength and MaxLength bound the length of the trails
+	MinLength, MaxLength Trail.Distance
+	// Statuses keeps the trails with one of them on Date
+	Statuses []Trail.Status
+	// Region and LandUnit keep the trails in the state or region, given
+	// by name or code, and in the land unit, which may be given in part
+	Region, LandUnit string
+	// MaintainedBefore and MaintainedAfter, YYYY-MM-DD, bound the date of
+	// the last maintenance of the trails. Both dates are included. A
+	// trail never maintained counts as maintained before any date.
+	MaintainedBefore, MaintainedAfter string
+	// MinSatisfaction and MaxSatisfaction bound the average satisfaction
+	// of the visits to the trails, from 1 to 5. Trails without a visit
+	// with a valid score are left out when either is set.
+	MinSatisfaction, MaxSatisfaction float64
+	// Sort is the order of the results, by relevance when there is text
+	// and by name otherwise if it is empty
+	Sort       SortKey
+	Descending bool
+	// Page counts from 1, and PageSize defaults to DefaultPageSize and is at
+	// most MaxPageSize
+	Page, PageSize int
+	// Date is the date the statuses are taken on, today if it is empty
+	Date string
+}
+
+// Validate checks the filters and paging of q
+func (q Query) Validate() error {
+	for _, d := range q.Difficulties {
+		if !d.Valid() {
+			return fmt.Errorf("unknown difficulty %q, use %s", d, Trail.DifficultyHelp)
+		}
+	}
+	for _, s := range q.Statuses {
+		if !s.Valid() {
+			return fmt.Errorf("unknown status %q, use one of: %s", s, Trail.JoinStatuses(Trail.Statuses))
+		}
+	}
+	for _, date := range []string{q.MaintainedBefore, q.MaintainedAfter, q.Date} {
+		if date != "" && !Trail.ValidDate(date) {
+			return fmt.Errorf("invalid date %q, please use YYYY-MM-DD", date)
+		}
+	}
+	switch {
+	case q.MinLength.Value < 0 || q.MaxLength.Value < 0:
+		return errors.New("lengths cannot be negative")
+	case q.MaxLength.Value > 0 && q.MinLength.Miles() > q.MaxLength.Miles():
+		return errors.New("the minimum length is above the maximum")
+	case q.MinSatisfaction < 0 || q.MinSatisfaction > 5 || q.MaxSatisfaction < 0 || q.MaxSatisfaction > 5:
+		return errors.New("satisfaction is from 1 to 5")
+	case q.MaxSatisfaction > 0 && q.MinSatisfaction > q.MaxSatisfaction:
+		return errors.New("the minimum satisfaction is above the maximum")
+	case q.MaintainedBefore != "" && q.MaintainedAfter > q.MaintainedBefore:
+		return errors.New("the maintained after date is later than the maintained before date")
+	case q.Page < 0:
+		return errors.New("pages count from 1")
+	case q.PageSize < 0:
+		return errors.New("the page size cannot be negative")
+	case q.PageSize > MaxPageSize:
+		return fmt.Errorf("the page size is at most %d", MaxPageSize)
+	case q.Sort != "" && !slices.Contains(SortKeys, q.Sort):
+		return fmt.Errorf("unknown sort order %q, use one of: %s", q.Sort, JoinSortKeys())
+	}
+	return nil
+}
+
+// Result is a trail found by a search, with what it was matched on
+type Result struct {
+	Trail Trail.Trail `json:"trail"`
+	// Status is the effective status on the date of the query
+	Status         Trail.Status `json:"status"`
+	LastMaintained string       `json:"last_maintained,omitempty"`
+	// Visits counts the visits with a valid satisfaction score, and
+	// Satisfaction is their average, 0 without any
+	Visits       int     `json:"visits"`
+	Satisfaction float64 `json:"satisfaction,omitempty"`
+	// Score is how well the trail matches the text, 0 without text
+	Score int `json:"score,omitempty"`
+}
+
+// Page is one page of the results of a search
+type Page struct {
+	Results []Result `json:"results"`
+	// Total counts the results on every page
+	Total    int `json:"total"`
+	Page     int `json:"page"`
+	PageSize int `json:"page_size"`
+	Pages    int `json:"pages"`
+}
+
+// Trails runs query against the trails, taking their status, last
+// maintenance and average satisfaction from the other datasets
+func Trails(trails Trail.Repository, visitors Visitor.Repository, maintenance Maintenance.Repository, history Trail.HistoryRepository, schedule Trail.ScheduleRepository, query Query) (Page, error) {
+	if err := query.Validate(); err != nil {
+		return Page{}, err
+	}
+	if query.Date == "" {
+		query.Date = Trail.Today()
+	}
+	if query.Sort == "" {
+		query.Sort = ByName
+		if strings.TrimSpace(query.Text) != "" {
+			query.Sort = ByRelevance
+		}
+	}
+	query.Page = max(query.Page, 1)
+	if query.PageSize == 0 {
+		query.PageSize = DefaultPageSize
+	}
+
+	trailRecords, err := trails.List()
+	if err != nil {
+		return Page{}, fmt.Errorf("reading trails: %w", err)
+	}
+	summaries, err := Status.Summaries(trails, maintenance, history, schedule, query.Date)
+	if err != nil {
+		return Page{}, err
+	}
+	statuses := make(map[string]Status.TrailStatus, len(summaries))
+	for _, summary := range summaries {
+		statuses[summary.TrailID] = summary
+	}
+	visitorRecords, err := visitors.List()
+	if err != nil {
+		return Page{}, fmt.Errorf("reading visitors: %w", err)
+	}
+	scores := make(map[string][]int)
+	for _, visitor := range visitorRecords {
+		if score, err := strconv.Atoi(visitor.Satisfaction); err == nil && score >= 1 && score <= 5 && visitor.TrailID != "" {
+			scores[visitor.TrailID] = append(scores[visitor.TrailID], score)
+		}
+	}
+
+	terms := strings.Fields(strings.ToLower(query.Text))
+	var results []Result
+	for _, trail := range trailRecords {
+		summary, ok := statuses[trail.ID]
+		if !ok {
+			continue
+		}
+		result := Result{Trail: trail, Status: summary.Status, LastMaintained: summary.LastMaintained, Visits: len(scores[trail.ID])}
+		for _, score := range scores[trail.ID] {
+			result.Satisfaction += float64(score)
+		}
+		if result.Visits > 0 {
+			result.Satisfaction /= float64(result.Visits)
+		}
+		if result.Score, ok = match(trail, terms); ok && query.keeps(result) {
+			results = append(results, result)
+		}
+	}
+
+	sortResults(results, query.Sort, query.Descending)
+	page := Page{Total: len(results), Page: query.Page, PageSize: query.PageSize}
+	page.Pages = (page.Total + page.PageSize - 1) / page.PageSize
+	// A page past the last is empty. The bounds are worked out without
+	// going past Total, so that no page number overflows them.
+	page.Results = []Result{}
+	if page.Total > 0 && page.Page-1 <= (page.Total-1)/page.PageSize {
+		start := (page.Page - 1) * page.PageSize
+		page.Results = results[start : start+min(page.PageSize, page.Total-start)]
+	}
+	return page, nil
+}
+
+// keeps reports whether a result passes the filters of q other than the
+// text
+func (q Query) keeps(r Result) bool {
+	if len(q.Difficulties) > 0 && !slices.ContainsFunc(q.Difficulties, func(d Trail.Difficulty) bool {
+		return d.Standard() == r.Trail.Difficulty.Standard()
+	}) {
+		return false
+	}
+	if q.MinLength.Value > 0 && r.Trail.Length.Miles() < q.MinLength.Miles() {
+		return false
+	}
+	if q.MaxLength.Value > 0 && r.Trail.Length.Miles() > q.MaxLength.Miles() {
+		return false
+	}
+	if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, r.Status) {
+		return false
+	}
+	if q.Region != "" && !r.Trail.Location.InRegion(q.Region) {
+		return false
+	}
+	if q.LandUnit != "" && !strings.Contains(strings.ToLower(r.Trail.Location.LandUnit), strings.ToLower(q.LandUnit)) {
+		return false
+	}
+	if q.MaintainedBefore != "" && r.LastMaintained > q.MaintainedBefore {
+		return false
+	}
+	if q.MaintainedAfter != "" && (r.LastMaintained == "" || r.LastMaintained < q.MaintainedAfter) {
+		return false
+	}
+	if q.MinSatisfaction > 0 || q.MaxSatisfaction > 0 {
+		if r.Visits == 0 || r.Satisfaction < q.MinSatisfaction || (q.MaxSatisfaction > 0 && r.Satisfaction > q.MaxSatisfaction) {
+			return false
+		}
+	}
+	return true
+}
+
+// match scores how well a trail matches the search terms, all of which
+// have to be found in its name or location. A term counts the most when
+// the name is made of it, then when a word of the name starts with it,
+// then when the name contains it, and the least when only the location
+// does.
+func match(trail Trail.Trail, terms []string) (int, bool) {
+	name := strings.ToLower(trail.Name)
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == ' ' || r == '-' || r == '\'' || r == ','
+	})
+	location := strings.ToLower(trail.Location.String())
+	score := 0
+	for _, term := range terms {
+		switch {
+		case name == term:
+			score += 8
+		case slices.ContainsFunc(words, func(w string) bool { return strings.HasPrefix(w, term) }):
+			score += 4
+		case strings.Contains(name, term):
+			score += 2
+		case strings.Contains(location, term), trail.Location.InRegion(term):
+			score++
+		default:
+			return 0, false
+		}
+	}
+	return score, true
+}
+
+// sortResults orders results by key, breaking ties by name
+func sortResults(results []Result, key SortKey, descending bool) {
+	slices.SortStableFunc(results, func(a, b Result) int {
+		var c int
+		switch key {
+		case ByRelevance:
+			// The best match first unless descending is asked for
+			c = cmp.Compare(b.Score, a.Score)
+		case ByLength:
+			c = cmp.Compare(a.Trail.Length.Miles(), b.Trail.Length.Miles())
+		case ByDifficulty:
+			c = cmp.Compare(a.Trail.Difficulty.Rank(), b.Trail.Difficulty.Rank())
+		case ByStatus:
+			c = cmp.Compare(slices.Index(Trail.Statuses, a.Status), slices.Index(Trail.Statuses, b.Status))
+		case ByLastMaintained:
+			c = strings.Compare(a.LastMaintained, b.LastMaintained)
+		case BySatisfaction:
+			// Trails without visits come before the worst rated ones
+			c = cmp.Compare(satisfaction(a), satisfaction(b))
+		}
+		if descending {
+			c = -c
+		}
+		if c == 0 {
+			c = strings.Compare(strings.ToLower(a.Trail.Name), strings.ToLower(b.Trail.Name))
+			if descending && key == ByName {
+				c = -c
+			}
+		}
+		return c
+	})
+}
+
+// satisfaction is the average satisfaction of a result, below any score
+// without visits
+func satisfaction(r Result) float64 {
+	if r.Visits == 0 {
+		return math.Inf(-1)
+	}
+	return r.Satisfaction
+}
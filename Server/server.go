@@ -0,0 +1,645 @@
+package Server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	DataStore "project/DataStore"
+	Feedback "project/Feedback"
+	Maintenance "project/Maintenance"
+	Network "project/Network"
+	Search "project/Search"
+	Status "project/Status"
+	Storage "project/Storage"
+	Trail "project/Trail"
+	Visitor "project/Visitor"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBody limits the size of request bodies
+const maxBody = 1 << 20
+
+// Server serves the data store as a JSON REST API. Requests are handled
+// concurrently, the data store keeps each change whole.
+type Server struct {
+	store *DataStore.DataStore
+	mux   *http.ServeMux
+}
+
+// New creates a server for a loaded data store
+func New(store *DataStore.DataStore) *Server {
+	s := &Server{store: store, mux: http.NewServeMux()}
+	register(s, "/trails", store.Trails, func(t *Trail.Trail) *string { return &t.ID }, nil)
+	register(s, "/visitors", store.Visitors, func(v *Visitor.Visitor) *string { return &v.ID }, func(v *Visitor.Visitor) (*string, *string) {
+		return &v.TrailID, &v.Trail
+	})
+	register(s, "/maintenance", store.Maintenance, func(m *Maintenance.Maintenance) *string { return &m.ID }, func(m *Maintenance.Maintenance) (*string, *string) {
+		return &m.TrailID, &m.TrailName
+	})
+	register(s, "/maintenance-plans", store.Plans, func(p *Maintenance.Plan) *string { return &p.ID }, nil)
+	s.mux.HandleFunc("POST /maintenance-plans/generate", s.write(s.generate))
+	s.mux.HandleFunc("GET /maintenance/due", s.read(s.due))
+	register(s, "/status-schedule", store.StatusSchedule, func(c *Trail.ScheduledChange) *string { return &c.ID }, nil)
+	register(s, "/junctions", store.Junctions, func(j *Network.Junction) *string { return &j.ID }, nil)
+	register(s, "/segments", store.Segments, func(seg *Network.Segment) *string { return &seg.ID }, nil)
+	s.mux.HandleFunc("GET /routes", s.read(s.routes))
+	s.mux.HandleFunc("GET /status", s.read(func(r *http.Request) (any, error) {
+		date := r.URL.Query().Get("date")
+		if date == "" {
+			date = Trail.Today()
+		} else if !Trail.ValidDate(date) {
+			return nil, badRequest{fmt.Errorf("invalid date %q, please use YYYY-MM-DD", date)}
+		}
+		// A snapshot, so a trail deleted meanwhile cannot mix in with the
+		// maintenance records read before it
+		snapshot, err := store.Snapshot()
+		if err != nil {
+			return nil, err
+		}
+		summaries, err := Status.Summaries(snapshot.Trails, snapshot.Maintenance, snapshot.StatusHistory, snapshot.StatusSchedule, date)
+		if region := r.URL.Query().Get("region"); region != "" && err == nil {
+			summaries = Status.InRegion(summaries, region)
+			if summaries == nil {
+				summaries = []Status.TrailStatus{}
+			}
+		}
+		return summaries, err
+	}))
+	s.mux.HandleFunc("GET /trails.geojson", s.trailMap(Status.GeoJSON, "application/geo+json"))
+	s.mux.HandleFunc("GET /trails.kml", s.trailMap(Status.KML, "application/vnd.google-earth.kml+xml"))
+	s.mux.HandleFunc("GET /trails/search", s.read(s.search))
+	s.mux.HandleFunc("GET /trails/{id}/nearby", s.read(s.nearby))
+	s.mux.HandleFunc("GET /trails/{id}/status", s.read(func(r *http.Request) (any, error) {
+		trail, err := store.Trails.Get(r.PathValue("id"))
+		if err != nil {
+			return nil, err
+		}
+		history, err := Trail.History(store.StatusHistory, trail.ID)
+		if history == nil {
+			history = []Trail.StatusChange{}
+		}
+		return history, err
+	}))
+	s.mux.HandleFunc("POST /trails/{id}/status", s.write(s.changeStatus))
+	s.mux.HandleFunc("GET /trails/{id}/track", s.track)
+	s.mux.HandleFunc("PUT /trails/{id}/track", s.write(s.importTrack))
+	s.mux.HandleFunc("GET /feedback", s.read(func(*http.Request) (any, error) {
+		return Feedback.Summarize(store.Visitors)
+	}))
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// register adds the CRUD endpoints of a dataset under path. id returns the
+// ID field of a record and trail the fields referencing its trail, if any.
+func register[T interface{ Validate() error }](s *Server, path string, repo Storage.Repository[T], id func(*T) *string, trail func(*T) (id, name *string)) {
+	// prepare checks a record sent by a client the way the menus check
+	// the records they are given. current is the stored version of an
+	// updated record: one that was already dangling can still be edited
+	// if its trail is left as it is, the integrity check reports it.
+	prepare := func(record, current *T) error {
+		if trail != nil {
+			trailID, trailName := trail(record)
+			unchanged := false
+			if current != nil {
+				currentID, currentName := trail(current)
+				unchanged = *trailID == *currentID && *trailName == *currentName
+			}
+			if !unchanged {
+				if err := s.linkTrail(trailID, trailName); err != nil {
+					return badRequest{err}
+				}
+			}
+		}
+		if err := (*record).Validate(); err != nil {
+			return badRequest{err}
+		}
+		return nil
+	}
+
+	s.mux.HandleFunc("GET "+path, s.read(func(*http.Request) (any, error) {
+		records, err := repo.List()
+		if records == nil {
+			records = []T{}
+		}
+		return records, err
+	}))
+	s.mux.HandleFunc("GET "+path+"/{id}", s.read(func(r *http.Request) (any, error) {
+		return repo.Get(r.PathValue("id"))
+	}))
+
+	s.mux.HandleFunc("POST "+path, s.write(func(w http.ResponseWriter, r *http.Request) (int, any, error) {
+		var record T
+		if err := decode(r, &record); err != nil {
+			return 0, nil, err
+		}
+		// IDs are always assigned by the store
+		*id(&record) = ""
+		if err := prepare(&record, nil); err != nil {
+			return 0, nil, err
+		}
+		record, err := repo.Create(record)
+		if err != nil {
+			return 0, nil, err
+		}
+		w.Header().Set("Location", path+"/"+*id(&record))
+		return http.StatusCreated, record, nil
+	}))
+	s.mux.HandleFunc("PUT "+path+"/{id}", s.write(func(w http.ResponseWriter, r *http.Request) (int, any, error) {
+		var record T
+		if err := decode(r, &record); err != nil {
+			return 0, nil, err
+		}
+		key := r.PathValue("id")
+		if *id(&record) != "" && *id(&record) != key {
+			return 0, nil, badRequest{fmt.Errorf("record ID %s does not match the URL", *id(&record))}
+		}
+		*id(&record) = key
+		current, err := repo.Get(key)
+		if err != nil {
+			return 0, nil, err
+		}
+		if err := prepare(&record, &current); err != nil {
+			return 0, nil, err
+		}
+		if err := repo.Update(key, record); err != nil {
+			return 0, nil, err
+		}
+		record, err = repo.Get(key)
+		return http.StatusOK, record, err
+	}))
+	s.mux.HandleFunc("DELETE "+path+"/{id}", s.write(func(w http.ResponseWriter, r *http.Request) (int, any, error) {
+		if err := repo.Delete(r.PathValue("id")); err != nil {
+			return 0, nil, err
+		}
+		return http.StatusNoContent, nil, nil
+	}))
+}
+
+// changeStatus records a status change of a trail, which changes the
+// trail's status. The trail and the status it changes from are taken from
+// the URL and the trail, the effective date defaults to today.
+func (s *Server) changeStatus(w http.ResponseWriter, r *http.Request) (int, any, error) {
+	var change Trail.StatusChange
+	if err := decode(r, &change); err != nil {
+		return 0, nil, err
+	}
+	trail, err := s.store.Trails.Get(r.PathValue("id"))
+	if err != nil {
+		return 0, nil, err
+	}
+	change.ID, change.TrailID, change.From = "", trail.ID, ""
+	if change.EffectiveDate == "" {
+		change.EffectiveDate = Trail.Today()
+	}
+	if err := change.Validate(); err != nil {
+		return 0, nil, badRequest{err}
+	}
+	if change, err = s.store.StatusHistory.Create(change); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, change, nil
+}
+
+// lookAhead reads the last day a request looks ahead to: the through
+// parameter, or the days parameter counted from today, 30 days by default
+func lookAhead(r *http.Request) (string, error) {
+	params := r.URL.Query()
+	if through := params.Get("through"); through != "" {
+		if !Trail.ValidDate(through) {
+			return "", badRequest{fmt.Errorf("invalid through date %q, please use YYYY-MM-DD", through)}
+		}
+		return through, nil
+	}
+	days := Maintenance.DefaultLead
+	if text := params.Get("days"); text != "" {
+		var err error
+		if days, err = strconv.Atoi(text); err != nil || days < 0 {
+			return "", badRequest{fmt.Errorf("invalid number of days %q", text)}
+		}
+	}
+	return time.Now().AddDate(0, 0, days).Format("2006-01-02"), nil
+}
+
+// generate creates the work orders the maintenance plans call for through
+// the day given by the through or days parameter, and sends them back
+func (s *Server) generate(w http.ResponseWriter, r *http.Request) (int, any, error) {
+	through, err := lookAhead(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	created, err := s.store.GenerateWorkOrders(through)
+	if err != nil {
+		return 0, nil, err
+	}
+	if created == nil {
+		created = []Maintenance.Maintenance{}
+	}
+	return http.StatusOK, created, nil
+}
+
+// due lists the work due today or through the day given by the through or
+// days parameter, and the work overdue; only that with overdue=true
+func (s *Server) due(r *http.Request) (any, error) {
+	through, err := lookAhead(r)
+	if err != nil {
+		return nil, err
+	}
+	today := Trail.Today()
+	if through < today {
+		return nil, badRequest{errors.New("through cannot be before today")}
+	}
+	snapshot, err := s.store.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	plans, err := snapshot.Plans.List()
+	if err != nil {
+		return nil, err
+	}
+	records, err := snapshot.Maintenance.List()
+	if err != nil {
+		return nil, err
+	}
+	trails, err := snapshot.Trails.List()
+	if err != nil {
+		return nil, err
+	}
+	work, err := Maintenance.Due(plans, records, trails, today, through)
+	if err != nil {
+		return nil, err
+	}
+	if r.URL.Query().Get("overdue") == "true" {
+		work = slices.DeleteFunc(work, func(w Maintenance.DueWork) bool { return !w.Overdue() })
+	}
+	if work == nil {
+		work = []Maintenance.DueWork{}
+	}
+	return work, nil
+}
+
+// nearby lists the trails by distance from the trailhead of a trail, within
+// the distance given by the within parameter if there is one. A distance
+// without a unit is in the configured units.
+func (s *Server) nearby(r *http.Request) (any, error) {
+	var within Trail.Distance
+	if text := r.URL.Query().Get("within"); text != "" {
+		var err error
+		if within, err = Trail.ParseDistance(text, s.store.Config().Units.Distance()); err != nil {
+			return nil, badRequest{err}
+		}
+	}
+	trail, err := s.store.Trails.Get(r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+	if trail.Location.Trailhead == nil {
+		return nil, badRequest{fmt.Errorf("the trailhead of trail %s is not known", trail.ID)}
+	}
+	trails, err := s.store.Trails.List()
+	if err != nil {
+		return nil, err
+	}
+	nearby := slices.DeleteFunc(Trail.Nearby(trails, *trail.Location.Trailhead, within), func(t Trail.NearbyTrail) bool {
+		return t.ID == trail.ID
+	})
+	if nearby == nil {
+		nearby = []Trail.NearbyTrail{}
+	}
+	return nearby, nil
+}
+
+// search finds trails. The q parameter is the text to search for, and
+// difficulty and status take lists separated by commas. min_length,
+// max_length, region, land_unit, maintained_before, maintained_after,
+// min_satisfaction, max_satisfaction, date, sort, order (asc or desc),
+// page and page_size are optional. A length without a unit is in the
+// configured units.
+func (s *Server) search(r *http.Request) (any, error) {
+	params := r.URL.Query()
+	query := Search.Query{
+		Text:             params.Get("q"),
+		Region:           params.Get("region"),
+		LandUnit:         params.Get("land_unit"),
+		MaintainedBefore: params.Get("maintained_before"),
+		MaintainedAfter:  params.Get("maintained_after"),
+		Date:             params.Get("date"),
+	}
+	if text := params.Get("difficulty"); text != "" {
+		for _, part := range strings.Split(text, ",") {
+			difficulty, err := Trail.ParseDifficulty(part)
+			if err != nil {
+				return nil, badRequest{err}
+			}
+			query.Difficulties = append(query.Difficulties, difficulty)
+		}
+	}
+	if text := params.Get("status"); text != "" {
+		for _, part := range strings.Split(text, ",") {
+			status, err := Trail.ParseStatus(part)
+			if err != nil {
+				return nil, badRequest{err}
+			}
+			query.Statuses = append(query.Statuses, status)
+		}
+	}
+	for _, bound := range []struct {
+		param  string
+		length *Trail.Distance
+	}{{"min_length", &query.MinLength}, {"max_length", &query.MaxLength}} {
+		if text := params.Get(bound.param); text != "" {
+			var err error
+			if *bound.length, err = Trail.ParseDistance(text, s.store.Config().Units.Distance()); err != nil {
+				return nil, badRequest{err}
+			}
+		}
+	}
+	for _, bound := range []struct {
+		param string
+		value *float64
+	}{{"min_satisfaction", &query.MinSatisfaction}, {"max_satisfaction", &query.MaxSatisfaction}} {
+		if text := params.Get(bound.param); text != "" {
+			var err error
+			if *bound.value, err = strconv.ParseFloat(text, 64); err != nil {
+				return nil, badRequest{fmt.Errorf("invalid %s %q", bound.param, text)}
+			}
+		}
+	}
+	for _, n := range []struct {
+		param string
+		value *int
+	}{{"page", &query.Page}, {"page_size", &query.PageSize}} {
+		if text := params.Get(n.param); text != "" {
+			var err error
+			if *n.value, err = strconv.Atoi(text); err != nil || *n.value < 1 {
+				return nil, badRequest{fmt.Errorf("invalid %s %q", n.param, text)}
+			}
+		}
+	}
+	if text := params.Get("sort"); text != "" {
+		var err error
+		if query.Sort, err = Search.ParseSortKey(text); err != nil {
+			return nil, badRequest{err}
+		}
+	}
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return nil, badRequest{fmt.Errorf("invalid order %q, use asc or desc", params.Get("order"))}
+	}
+	if err := query.Validate(); err != nil {
+		return nil, badRequest{err}
+	}
+
+	snapshot, err := s.store.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return Search.Trails(snapshot.Trails, snapshot.Visitors, snapshot.Maintenance, snapshot.StatusHistory, snapshot.StatusSchedule, query)
+}
+
+// routes plans routes through the trail network. The from and to
+// parameters give junctions by ID or name, to being left out for a loop;
+// distance, tolerance, max_difficulty, date and limit are optional. A
+// distance without a unit is in the configured units. The trails avoided
+// on the date are sent back with the reason.
+func (s *Server) routes(r *http.Request) (any, error) {
+	query := r.URL.Query()
+	request := Network.RouteRequest{MaxDifficulty: Trail.Difficulty(query.Get("max_difficulty"))}
+	var err error
+	if text := query.Get("distance"); text != "" {
+		if request.Distance, err = Trail.ParseDistance(text, s.store.Config().Units.Distance()); err != nil {
+			return nil, badRequest{err}
+		}
+	}
+	if text := query.Get("tolerance"); text != "" {
+		if request.Tolerance, err = strconv.ParseFloat(text, 64); err != nil {
+			return nil, badRequest{fmt.Errorf("invalid tolerance %q", text)}
+		}
+	}
+	if text := query.Get("limit"); text != "" {
+		if request.Limit, err = strconv.Atoi(text); err != nil {
+			return nil, badRequest{fmt.Errorf("invalid limit %q", text)}
+		}
+	}
+	if request.MaxDifficulty != "" {
+		if request.MaxDifficulty, err = Trail.ParseDifficulty(string(request.MaxDifficulty)); err != nil {
+			return nil, badRequest{err}
+		}
+	}
+	date := query.Get("date")
+	if date == "" {
+		date = Trail.Today()
+	} else if !Trail.ValidDate(date) {
+		return nil, badRequest{fmt.Errorf("invalid date %q, please use YYYY-MM-DD", date)}
+	}
+	if query.Get("from") == "" {
+		return nil, badRequest{errors.New("from is required")}
+	}
+
+	snapshot, err := s.store.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	for _, end := range []struct {
+		param string
+		id    *string
+	}{{"from", &request.From}, {"to", &request.To}} {
+		if name := query.Get(end.param); name != "" {
+			junction, err := Network.FindJunction(snapshot.Junctions, name)
+			if err != nil {
+				return nil, badRequest{err}
+			}
+			*end.id = junction.ID
+		}
+	}
+	graph, err := Network.BuildGraph(snapshot.Junctions, snapshot.Segments, snapshot.Trails, snapshot.Maintenance, snapshot.StatusHistory, snapshot.StatusSchedule, date)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := graph.Plan(request)
+	if err != nil {
+		return nil, badRequest{err}
+	}
+	if routes == nil {
+		routes = []Network.Route{}
+	}
+	return struct {
+		Routes  []Network.Route   `json:"routes"`
+		Avoided map[string]string `json:"avoided"`
+	}{routes, graph.Closed}, nil
+}
+
+// track sends the track of a trail as a GPX file
+func (s *Server) track(w http.ResponseWriter, r *http.Request) {
+	trail, err := s.store.Trails.Get(r.PathValue("id"))
+	if err != nil {
+		respond(w, 0, nil, err)
+		return
+	}
+	track, err := Trail.TrackOf(s.store.Tracks, trail.ID)
+	if err != nil {
+		respond(w, 0, nil, err)
+		return
+	}
+	var gpx bytes.Buffer
+	if err := Trail.WriteGPX(&gpx, trail, track); err != nil {
+		respond(w, 0, nil, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/gpx+xml")
+	w.Write(gpx.Bytes())
+}
+
+// trailMap returns a handler sending the trails with their status today as
+// a map in format, with lengths in the configured units
+func (s *Server) trailMap(format, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := s.store.Snapshot()
+		if err != nil {
+			respond(w, 0, nil, err)
+			return
+		}
+		trails, err := Status.MapTrails(snapshot.Trails, snapshot.Maintenance, snapshot.StatusHistory, snapshot.StatusSchedule, snapshot.Tracks, Trail.Today())
+		if err != nil {
+			respond(w, 0, nil, err)
+			return
+		}
+		var body bytes.Buffer
+		if err := Status.WriteMap(&body, format, trails, s.store.Config().Units); err != nil {
+			respond(w, 0, nil, err)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(body.Bytes())
+	}
+}
+
+// importTrack sets the track of a trail from the GPX file sent, which also
+// sets the trail's length, elevation gain and trailhead. The trail and the
+// stats of the track are sent back.
+func (s *Server) importTrack(w http.ResponseWriter, r *http.Request) (int, any, error) {
+	track, err := Trail.ReadGPX(http.MaxBytesReader(nil, r.Body, maxBody))
+	if err != nil {
+		return 0, nil, badRequest{err}
+	}
+	trail, err := s.store.Trails.Get(r.PathValue("id"))
+	if err != nil {
+		return 0, nil, err
+	}
+	track.TrailID = trail.ID
+	if err := track.Validate(); err != nil {
+		return 0, nil, badRequest{err}
+	}
+	trail, stats, err := s.store.ImportGPX(trail, track)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, struct {
+		Trail Trail.Trail      `json:"trail"`
+		Stats Trail.TrackStats `json:"stats"`
+	}{trail, stats}, nil
+}
+
+// linkTrail fills in the trail ID of a record that only names its trail,
+// and the trail name of a record that only gives the ID
+func (s *Server) linkTrail(trailID, trailName *string) error {
+	if *trailID != "" {
+		trail, err := s.store.Trails.Get(*trailID)
+		if errors.Is(err, Storage.ErrNotFound) {
+			return &Storage.RecordError{Entity: "trail", Key: *trailID, Err: Storage.ErrInvalidReference}
+		}
+		*trailName = trail.Name
+		return err
+	}
+	if *trailName == "" {
+		return errors.New("trail_id or trail name is required")
+	}
+	trail, err := Trail.Unique(s.store.Trails, *trailName)
+	if errors.Is(err, Trail.ErrAmbiguous) {
+		return fmt.Errorf("%w, give a trail_id", err)
+	}
+	*trailID, *trailName = trail.ID, trail.Name
+	return err
+}
+
+// read returns a handler for a request that does not change anything
+func (s *Server) read(handle func(r *http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value, err := handle(r)
+		respond(w, http.StatusOK, value, err)
+	}
+}
+
+// write returns a handler for a request that changes the data. handle may
+// set response headers, and returns the status code and the value to send
+// back, if any.
+func (s *Server) write(handle func(w http.ResponseWriter, r *http.Request) (int, any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, value, err := handle(w, r)
+		respond(w, status, value, err)
+	}
+}
+
+// badRequest marks errors in what the client sent
+type badRequest struct {
+	err error
+}
+
+func (e badRequest) Error() string {
+	return e.err.Error()
+}
+
+func (e badRequest) Unwrap() error {
+	return e.err
+}
+
+// decode reads the JSON body of a request into value
+func decode(r *http.Request, value any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		return badRequest{fmt.Errorf("invalid JSON: %w", err)}
+	}
+	return nil
+}
+
+// respond writes value as JSON, or err as a JSON error object with a
+// matching status code
+func respond(w http.ResponseWriter, status int, value any, err error) {
+	if err != nil {
+		status, value = statusCode(err), map[string]string{"error": err.Error()}
+	}
+	if value == nil {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func statusCode(err error) int {
+	var bad badRequest
+	switch {
+	case errors.As(err, &bad), errors.Is(err, Storage.ErrInvalidReference), errors.Is(err, Trail.ErrStatusChange):
+		return http.StatusBadRequest
+	case errors.Is(err, Storage.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, Storage.ErrDuplicate), errors.Is(err, Storage.ErrReferenced), errors.Is(err, Storage.ErrConflict),
+		errors.Is(err, Trail.ErrTransition), errors.Is(err, Maintenance.ErrTransition):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
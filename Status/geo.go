@@ -0,0 +1,259 @@
+package Status
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"project/Maintenance"
+	"project/Trail"
+	"strconv"
+	"strings"
+)
+
+// Map formats
+const (
+	GeoJSON = "geojson"
+	KML     = "kml"
+)
+
+// MapFormats lists the formats WriteMap writes
+var MapFormats = []string{GeoJSON, KML}
+
+// MapTrail is a trail as it is shown on a map: its status, the fields shown
+// with it and its line or point
+type MapTrail struct {
+	TrailStatus
+	Difficulty Trail.Difficulty
+	Length     Trail.Distance
+	// Line is the trail's track, or nil when none is stored
+	Line []Trail.TrackPoint
+	// Trailhead is the point shown for a trail without a track, nil when it
+	// is not known
+	Trailhead *Trail.Coordinates
+}
+
+// MapTrails returns every trail with its status on date, YYYY-MM-DD, and
+// its track or trailhead
+func MapTrails(trails Trail.Repository, maintenance Maintenance.Repository, history Trail.HistoryRepository, schedule Trail.ScheduleRepository, tracks Trail.TrackRepository, date string) ([]MapTrail, error) {
+	summaries, err := Summaries(trails, maintenance, history, schedule, date)
+	if err != nil {
+		return nil, err
+	}
+	trailRecords, err := trails.List()
+	if err != nil {
+		return nil, fmt.Errorf("reading trails: %w", err)
+	}
+	trackRecords, err := tracks.List()
+	if err != nil {
+		return nil, fmt.Errorf("reading tracks: %w", err)
+	}
+	byID := make(map[string]Trail.Trail, len(trailRecords))
+	for _, trail := range trailRecords {
+		byID[trail.ID] = trail
+	}
+	lines := make(map[string][]Trail.TrackPoint, len(trackRecords))
+	for _, track := range trackRecords {
+		lines[track.TrailID] = track.Points
+	}
+
+	mapped := make([]MapTrail, 0, len(summaries))
+	for _, summary := range summaries {
+		trail := byID[summary.TrailID]
+		mapped = append(mapped, MapTrail{
+			TrailStatus: summary,
+			Difficulty:  trail.Difficulty,
+			Length:      trail.Length,
+			Line:        lines[trail.ID],
+			Trailhead:   trail.Location.Trailhead,
+		})
+	}
+	return mapped, nil
+}
+
+// WriteMap writes trails in one of the MapFormats, with lengths in units
+func WriteMap(w io.Writer, format string, trails []MapTrail, units Trail.Units) error {
+	switch format {
+	case GeoJSON:
+		return WriteGeoJSON(w, trails, units)
+	case KML:
+		return WriteKML(w, trails, units)
+	}
+	return fmt.Errorf("unknown map format %q, use %s", format, strings.Join(MapFormats, " or "))
+}
+
+// mapProperties are the fields shown with a trail on a map
+type mapProperties struct {
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	Location        string  `json:"location,omitempty"`
+	LandUnit        string  `json:"land_unit,omitempty"`
+	Difficulty      string  `json:"difficulty"`
+	Length          float64 `json:"length"`
+	LengthUnit      string  `json:"length_unit"`
+	Status          string  `json:"status"`
+	StatusSince     string  `json:"status_since,omitempty"`
+	StatusReason    string  `json:"status_reason,omitempty"`
+	LastMaintained  string  `json:"last_maintained,omitempty"`
+	MaintenanceType string  `json:"maintenance_type,omitempty"`
+}
+
+func (t MapTrail) properties(units Trail.Units) mapProperties {
+	return mapProperties{
+		ID:              t.TrailID,
+		Name:            t.Name,
+		Location:        t.Location.String(),
+		LandUnit:        t.Location.LandUnit,
+		Difficulty:      string(t.Difficulty),
+		Length:          math.Round(t.Length.In(units.Distance())*100) / 100,
+		LengthUnit:      string(units.Distance()),
+		Status:          string(t.Status),
+		StatusSince:     t.Since,
+		StatusReason:    t.Reason,
+		LastMaintained:  t.LastMaintained,
+		MaintenanceType: t.MaintenanceType,
+	}
+}
+
+// WriteGeoJSON writes trails as a GeoJSON FeatureCollection. A trail with a
+// track is a LineString, one with only a trailhead a Point, and one with
+// neither a feature without geometry. Positions are longitude, latitude
+// and, where known, elevation in meters. Lengths are given in units.
+func WriteGeoJSON(w io.Writer, trails []MapTrail, units Trail.Units) error {
+	type geometry struct {
+		Type        string `json:"type"`
+		Coordinates any    `json:"coordinates"`
+	}
+	type feature struct {
+		Type       string        `json:"type"`
+		ID         string        `json:"id"`
+		Geometry   *geometry     `json:"geometry"`
+		Properties mapProperties `json:"properties"`
+	}
+	collection := struct {
+		Type     string    `json:"type"`
+		Features []feature `json:"features"`
+	}{Type: "FeatureCollection", Features: []feature{}}
+
+	for _, trail := range trails {
+		f := feature{Type: "Feature", ID: trail.TrailID, Properties: trail.properties(units)}
+		switch {
+		case len(trail.Line) > 0:
+			line := make([][]float64, len(trail.Line))
+			for i, point := range trail.Line {
+				line[i] = []float64{point.Longitude, point.Latitude}
+				if point.Elevation != nil {
+					line[i] = append(line[i], *point.Elevation)
+				}
+			}
+			f.Geometry = &geometry{Type: "LineString", Coordinates: line}
+		case trail.Trailhead != nil:
+			f.Geometry = &geometry{Type: "Point", Coordinates: []float64{trail.Trailhead.Longitude, trail.Trailhead.Latitude}}
+		}
+		collection.Features = append(collection.Features, f)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(collection)
+}
+
+// WriteKML writes trails as a KML document with a placemark for each
+// trail, drawn the same way as by WriteGeoJSON. The fields are both
+// described in words and listed as extended data, with lengths in units.
+func WriteKML(w io.Writer, trails []MapTrail, units Trail.Units) error {
+	type data struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value"`
+	}
+	type placemark struct {
+		ID          string  `xml:"id,attr"`
+		Name        string  `xml:"name"`
+		Description string  `xml:"description"`
+		Data        []data  `xml:"ExtendedData>Data"`
+		Point       *string `xml:"Point>coordinates"`
+		Line        *struct {
+			Tessellate  int    `xml:"tessellate"`
+			Coordinates string `xml:"coordinates"`
+		} `xml:"LineString"`
+	}
+	type document struct {
+		XMLName    xml.Name    `xml:"kml"`
+		XMLNS      string      `xml:"xmlns,attr"`
+		Name       string      `xml:"Document>name"`
+		Placemarks []placemark `xml:"Document>Placemark"`
+	}
+
+	doc := document{XMLNS: "http://www.opengis.net/kml/2.2", Name: "Trails"}
+	for _, trail := range trails {
+		p := placemark{ID: trail.TrailID, Name: trail.Name, Description: describe(trail, units)}
+		props := trail.properties(units)
+		for _, field := range []struct{ name, value string }{
+			{"id", props.ID},
+			{"location", props.Location},
+			{"land_unit", props.LandUnit},
+			{"difficulty", props.Difficulty},
+			{"length", strconv.FormatFloat(props.Length, 'f', -1, 64)},
+			{"length_unit", props.LengthUnit},
+			{"status", props.Status},
+			{"status_since", props.StatusSince},
+			{"status_reason", props.StatusReason},
+			{"last_maintained", props.LastMaintained},
+			{"maintenance_type", props.MaintenanceType},
+		} {
+			if field.value != "" {
+				p.Data = append(p.Data, data{Name: field.name, Value: field.value})
+			}
+		}
+		switch {
+		case len(trail.Line) > 0:
+			coordinates := make([]string, len(trail.Line))
+			for i, point := range trail.Line {
+				coordinates[i] = kmlCoordinates(point.Coordinates)
+				if point.Elevation != nil {
+					coordinates[i] += "," + strconv.FormatFloat(*point.Elevation, 'f', -1, 64)
+				}
+			}
+			p.Line = &struct {
+				Tessellate  int    `xml:"tessellate"`
+				Coordinates string `xml:"coordinates"`
+			}{1, strings.Join(coordinates, " ")}
+		case trail.Trailhead != nil:
+			point := kmlCoordinates(*trail.Trailhead)
+			p.Point = &point
+		}
+		doc.Placemarks = append(doc.Placemarks, p)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// kmlCoordinates writes c as KML does, longitude first
+func kmlCoordinates(c Trail.Coordinates) string {
+	return strconv.FormatFloat(c.Longitude, 'f', -1, 64) + "," + strconv.FormatFloat(c.Latitude, 'f', -1, 64)
+}
+
+// describe sums up a trail in a sentence or two for the popup of a map
+func describe(trail MapTrail, units Trail.Units) string {
+	text := fmt.Sprintf("Difficulty: %s. Length: %s. Status: %s", trail.Difficulty, trail.Length.Format(units), trail.Status)
+	if trail.Since != "" {
+		text += " since " + trail.Since
+	}
+	text += "."
+	if trail.LastMaintained != "" {
+		text += fmt.Sprintf(" Last maintained %s (%s).", trail.LastMaintained, trail.MaintenanceType)
+	} else {
+		text += " No maintenance recorded."
+	}
+	return text
+}
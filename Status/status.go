@@ -1,29 +1,141 @@
 package Status
 
 import (
+	"bufio"
 	"fmt"
+	"os"
 	"project/Maintenance"
 	"project/Trail"
+	"slices"
+	"strings"
 	"time"
 )
 
-// Load the data once at the beginning of the program or before displaying the status
-func LoadData() {
-	// Load trail and maintenance data before any interaction
-	Trail.LoadTrailData("data/trails.csv")
-	Maintenance.LoadMaintenanceData("data/maintenance.csv")
+// TrailStatus is the status of a trail together with its last maintenance
+type TrailStatus struct {
+	TrailID  string         `json:"trail_id"`
+	Name     string         `json:"name"`
+	Location Trail.Location `json:"location"`
+	// Status is the effective status on the date the summary is for
+	Status Trail.Status `json:"status"`
+	// Since and Reason come from the status change in effect, if there was
+	// one
+	Since   string               `json:"since,omitempty"`
+	Reason  string               `json:"reason,omitempty"`
+	History []Trail.StatusChange `json:"history,omitempty"`
+	// Scheduled is the scheduled change overriding the status, if any, and
+	// Upcoming the scheduled changes still to start
+	Scheduled       *Trail.ScheduledChange  `json:"scheduled,omitempty"`
+	Upcoming        []Trail.ScheduledChange `json:"upcoming,omitempty"`
+	LastMaintained  string                  `json:"last_maintained,omitempty"`
+	MaintenanceType string                  `json:"maintenance_type,omitempty"`
 }
 
-// ViewTrailStatus displays the status and maintenance information of all trails
-func ViewTrailStatus() {
-	// Check if the data has been loaded
-	if len(Trail.TrailRecords) == 0 {
-		fmt.Println("No trail data available.")
+// Summaries returns the status of every trail on date, YYYY-MM-DD
+func Summaries(trails Trail.Repository, maintenance Maintenance.Repository, history Trail.HistoryRepository, schedule Trail.ScheduleRepository, date string) ([]TrailStatus, error) {
+	trailRecords, err := trails.List()
+	if err != nil {
+		return nil, fmt.Errorf("reading trails: %w", err)
+	}
+	maintenanceRecords, err := maintenance.List()
+	if err != nil {
+		return nil, fmt.Errorf("reading maintenance records: %w", err)
+	}
+
+	summaries := make([]TrailStatus, 0, len(trailRecords))
+	for _, trail := range trailRecords {
+		summary := TrailStatus{TrailID: trail.ID, Name: trail.Name, Location: trail.Location}
+		if summary.History, err = Trail.History(history, trail.ID); err != nil {
+			return nil, fmt.Errorf("reading status history: %w", err)
+		}
+		trailSchedule, err := Trail.Schedule(schedule, trail.ID)
+		if err != nil {
+			return nil, fmt.Errorf("reading status schedule: %w", err)
+		}
+		summary.Status, summary.Scheduled = Trail.StatusOn(trail, summary.History, trailSchedule, date)
+		summary.Upcoming = Trail.Upcoming(trailSchedule, date)
+		if summary.Scheduled != nil {
+			summary.Since = summary.Scheduled.Start
+			summary.Reason = summary.Scheduled.Reason
+		} else {
+			for _, change := range summary.History {
+				if change.EffectiveDate <= date {
+					summary.Since = change.EffectiveDate
+					summary.Reason = change.Reason
+				}
+			}
+		}
+		if latest, found := getLastMaintenance(maintenanceRecords, trail.ID); found {
+			summary.LastMaintained = latest.Date
+			summary.MaintenanceType = latest.Type
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// InRegion returns the summaries of the trails in region, given by name or
+// code
+func InRegion(summaries []TrailStatus, region string) []TrailStatus {
+	var in []TrailStatus
+	for _, summary := range summaries {
+		if summary.Location.InRegion(region) {
+			in = append(in, summary)
+		}
+	}
+	return in
+}
+
+// LandUnitGroup is the summaries of the trails in one land unit
+type LandUnitGroup struct {
+	// LandUnit is empty for the trails not in any land unit
+	LandUnit string        `json:"land_unit"`
+	Trails   []TrailStatus `json:"trails"`
+}
+
+// GroupByLandUnit groups summaries by land unit, in order of land unit name
+// with the trails not in any land unit last. Trails keep their order
+// within a group.
+func GroupByLandUnit(summaries []TrailStatus) []LandUnitGroup {
+	var groups []LandUnitGroup
+	for _, summary := range summaries {
+		i := slices.IndexFunc(groups, func(g LandUnitGroup) bool {
+			return strings.EqualFold(g.LandUnit, summary.Location.LandUnit)
+		})
+		if i < 0 {
+			groups = append(groups, LandUnitGroup{LandUnit: summary.Location.LandUnit})
+			i = len(groups) - 1
+		}
+		groups[i].Trails = append(groups[i].Trails, summary)
+	}
+	slices.SortStableFunc(groups, func(a, b LandUnitGroup) int {
+		if a.LandUnit == "" || b.LandUnit == "" {
+			return strings.Compare(b.LandUnit, a.LandUnit)
+		}
+		return strings.Compare(strings.ToLower(a.LandUnit), strings.ToLower(b.LandUnit))
+	})
+	return groups
+}
+
+// ViewTrailStatus displays the status as of today, status history,
+// upcoming scheduled changes and maintenance information of the trails in
+// a region the user picks, or of all trails, grouped by land unit
+func ViewTrailStatus(trails Trail.Repository, maintenance Maintenance.Repository, history Trail.HistoryRepository, schedule Trail.ScheduleRepository) {
+	summaries, err := Summaries(trails, maintenance, history, schedule, Trail.Today())
+	if err != nil {
+		fmt.Println("Error", err)
 		return
 	}
 
-	if len(Maintenance.MaintenanceRecords) == 0 {
-		fmt.Println("No maintenance data available.")
+	fmt.Print("Enter a state or region to show (blank for all): ")
+	region, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if region = strings.TrimSpace(region); region != "" {
+		summaries = InRegion(summaries, region)
+	}
+
+	// Check if the data has been loaded
+	if len(summaries) == 0 {
+		fmt.Println("No trail data available.")
 		return
 	}
 
@@ -31,17 +143,46 @@ func ViewTrailStatus() {
 	fmt.Println("\nTrail Status Summary:")
 
 	// Loop through trails and display their status and maintenance info
-	for _, trail := range Trail.TrailRecords {
-		latestMaintenance, found := getLastMaintenance(trail.Name)
+	for _, group := range GroupByLandUnit(summaries) {
+		if group.LandUnit != "" {
+			fmt.Printf("\n== %s ==\n", group.LandUnit)
+		} else {
+			fmt.Println("\n== Not in a land unit ==")
+		}
+		viewGroup(group.Trails)
+	}
+}
+
+// viewGroup displays the status of the trails in a land unit
+func viewGroup(summaries []TrailStatus) {
+	for _, summary := range summaries {
 		// Display trail info only once
-		fmt.Printf("Trail Name: %s\n", trail.Name)
-		fmt.Printf("Location: %s\n", trail.Location)
-		fmt.Printf("Status: %s\n", trail.Status)
+		fmt.Printf("Trail Name: %s\n", summary.Name)
+		fmt.Printf("Location: %s\n", summary.Location)
+		if summary.Scheduled != nil {
+			fmt.Printf("Status: %s (scheduled)\n", summary.Scheduled)
+		} else if summary.Since != "" {
+			fmt.Printf("Status: %s since %s: %s\n", summary.Status, summary.Since, summary.Reason)
+		} else {
+			fmt.Printf("Status: %s\n", summary.Status)
+		}
+		if len(summary.History) > 1 {
+			fmt.Println("Status History:")
+			for _, change := range summary.History {
+				fmt.Printf("  %s: %s to %s, %s\n", change.EffectiveDate, change.From, change.To, change.Reason)
+			}
+		}
+		if len(summary.Upcoming) > 0 {
+			fmt.Println("Upcoming Changes:")
+			for _, change := range summary.Upcoming {
+				fmt.Printf("  %s\n", change)
+			}
+		}
 
 		// Display maintenance info if found
-		if found {
-			fmt.Printf("Last Maintained: %s\n", latestMaintenance.Date)
-			fmt.Printf("Maintenance Type: %s\n", latestMaintenance.Type)
+		if summary.LastMaintained != "" {
+			fmt.Printf("Last Maintained: %s\n", summary.LastMaintained)
+			fmt.Printf("Maintenance Type: %s\n", summary.MaintenanceType)
 		} else {
 			fmt.Println("Maintenance Record: No Maintenance Found")
 		}
@@ -49,13 +190,14 @@ func ViewTrailStatus() {
 	}
 }
 
-func getLastMaintenance(trailName string) (Maintenance.Maintenance, bool) {
+func getLastMaintenance(records []Maintenance.Maintenance, trailID string) (Maintenance.Maintenance, bool) {
 	var latest Maintenance.Maintenance
 	found := false
 
-	// Iterate over maintenance records to find the most recent maintenance record for the trail
-	for _, record := range Maintenance.MaintenanceRecords {
-		if record.TrailName == trailName {
+	// Iterate over maintenance records to find the most recent maintenance record for the trail,
+	// counting only completed work
+	for _, record := range records {
+		if record.TrailID == trailID && record.Status == Maintenance.Done {
 			recordDate, _ := time.Parse("2006-01-02", record.Date)
 			latestDate, _ := time.Parse("2006-01-02", latest.Date)
 
@@ -0,0 +1,225 @@
+package Storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// note is a small versioned record for exercising the repositories
+type note struct {
+	ID      string
+	Text    string
+	Version int
+}
+
+var noteCodec = Codec[note]{
+	Entity:     "note",
+	Prefix:     "nte",
+	Key:        func(n note) string { return n.ID },
+	SetKey:     func(n note, id string) note { n.ID = id; return n },
+	Version:    func(n note) int { return n.Version },
+	SetVersion: func(n note, v int) note { n.Version = v; return n },
+	Same:       func(a, b note) bool { return a.Text == b.Text },
+	Schema: Schema{
+		Name:    "notes",
+		Version: 1,
+		Columns: []string{"id", "text", "version"},
+		Types:   map[string]string{"version": "INTEGER"},
+	},
+	Encode: func(n note) Row {
+		return Row{"id": n.ID, "text": n.Text, "version": strconv.Itoa(n.Version)}
+	},
+	Decode: func(row Row) (note, error) {
+		version, err := strconv.Atoi(row["version"])
+		return note{ID: row["id"], Text: row["text"], Version: version}, err
+	},
+}
+
+const (
+	workers = 8
+	rounds  = 25
+)
+
+// stores returns a fresh store of each backend, loaded and empty
+func stores(t *testing.T) map[string]Store[note] {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := OpenDatabase("sqlite", filepath.Join(dir, "notes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	stores := map[string]Store[note]{
+		"csv": NewCSV(filepath.Join(dir, "notes.csv"), noteCodec),
+		"sql": NewSQL(db, noteCodec),
+	}
+	for name, store := range stores {
+		if _, err := store.Load(); err != nil {
+			t.Fatalf("%s: loading: %v", name, err)
+		}
+	}
+	return stores
+}
+
+// exercise runs workers that each create, update, list and delete records
+// of their own while readers list them, and checks what is left
+func exercise(t *testing.T, repo Repository[note]) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				records, err := repo.List()
+				if err != nil {
+					t.Errorf("listing: %v", err)
+					return
+				}
+				for _, record := range records {
+					if record.ID == "" || record.Version < 1 {
+						t.Errorf("listed a half written record %+v", record)
+						return
+					}
+				}
+			}
+		}()
+	}
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range rounds {
+				record, err := repo.Create(note{Text: fmt.Sprintf("note %d.%d", w, i)})
+				if err != nil {
+					t.Errorf("creating: %v", err)
+					return
+				}
+				record.Text += " updated"
+				if err := repo.Update(record.ID, record); err != nil {
+					t.Errorf("updating %s: %v", record.ID, err)
+					return
+				}
+				if _, err := repo.List(); err != nil {
+					t.Errorf("listing: %v", err)
+					return
+				}
+				if i%2 == 1 {
+					if err := repo.Delete(record.ID); err != nil {
+						t.Errorf("deleting %s: %v", record.ID, err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	records, err := repo.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := workers * ((rounds + 1) / 2); len(records) != want {
+		t.Errorf("got %d records, want %d", len(records), want)
+	}
+	for _, record := range records {
+		if record.Version != 2 {
+			t.Errorf("%s is at version %d, want 2", record.ID, record.Version)
+		}
+	}
+}
+
+// contend has every worker update the same record, retrying on conflicts,
+// and checks that no update was lost
+func contend(t *testing.T, repo Repository[note]) {
+	t.Helper()
+	record, err := repo.Create(note{Text: "shared"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				for {
+					current, err := repo.Get(record.ID)
+					if err != nil {
+						t.Errorf("getting %s: %v", record.ID, err)
+						return
+					}
+					err = repo.Update(current.ID, current)
+					if err == nil {
+						break
+					}
+					if !errors.Is(err, ErrConflict) {
+						t.Errorf("updating %s: %v", record.ID, err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := repo.Get(record.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 1 + workers*rounds; got.Version != want {
+		t.Errorf("%s is at version %d, want %d", record.ID, got.Version, want)
+	}
+}
+
+func TestMemoryConcurrentChanges(t *testing.T) {
+	exercise(t, NewMemory(noteCodec))
+	contend(t, NewMemory(noteCodec))
+}
+
+func TestStoreConcurrentChanges(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			exercise(t, store)
+			contend(t, store)
+			if err := store.Save(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCSVConcurrentChangesSurviveReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.csv")
+	store := NewCSV(path, noteCodec)
+	if _, err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	// Enough changes to compact the journal several times while they run
+	exercise(t, store)
+	want, _ := store.List()
+
+	reloaded := NewCSV(path, noteCodec)
+	if _, err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := reloaded.List()
+	if len(got) != len(want) {
+		t.Fatalf("reloaded %d records, want %d", len(got), len(want))
+	}
+	stored := make(map[string]note)
+	for _, record := range want {
+		stored[record.ID] = record
+	}
+	for _, record := range got {
+		if stored[record.ID] != record {
+			t.Errorf("reloaded %+v, want %+v", record, stored[record.ID])
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package Storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"project/utils"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// CompactEvery is the number of journal entries after which a CSV
+// repository rewrites its snapshot
+const CompactEvery = 50
+
+// CSV is a Repository backed by a CSV snapshot file and an append-only
+// journal next to it. Every change is synced to the journal before it
+// returns, and the journal is compacted into the snapshot by Save or once
+// it holds CompactEvery entries. It is safe for concurrent use: changes are
+// applied one at a time, reads see the records before or after a change.
+type CSV[T any] struct {
+	*Memory[T]
+	// mu serializes changes, so that the journal holds them in the order
+	// they were applied
+	mu      sync.Mutex
+	path    string
+	base    string // hash of the snapshot the journal applies to
+	pending int    // entries in the journal
+}
+
+// NewCSV creates a repository for the file at path. Call Load to read it.
+func NewCSV[T any](path string, codec Codec[T]) *CSV[T] {
+	return &CSV[T]{Memory: NewMemory(codec), path: path}
+}
+
+// Path returns the snapshot file the repository reads and writes
+func (c *CSV[T]) Path() string {
+	return c.path
+}
+
+// JournalPath returns the journal file next to the snapshot
+func (c *CSV[T]) JournalPath() string {
+	return strings.TrimSuffix(c.path, filepath.Ext(c.path)) + ".journal"
+}
+
+// Pending returns the number of changes in the journal that are not in the
+// snapshot yet
+func (c *CSV[T]) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pending
+}
+
+// Load replaces the records in memory with the snapshot and replays the
+// journal on top of it. A missing snapshot is an empty one. Rows and journal
+// entries that cannot be applied are skipped and listed in the result.
+// Files written at an older schema version are migrated and saved straight
+// away, so the file and any IDs assigned by a migration stay stable.
+func (c *CSV[T]) Load() (LoadResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result LoadResult
+	data, err := os.ReadFile(c.path)
+	if err != nil && !os.IsNotExist(err) {
+		return result, err
+	}
+	schema := c.codec.Schema
+	table, err := schema.Read(data)
+	if err != nil {
+		return result, err
+	}
+	c.base = snapshotHash(data)
+	entries, err := readJournal(c.JournalPath(), c.base)
+	if err != nil {
+		return result, err
+	}
+
+	// Journal rows have the layout of the snapshot they were written on
+	// top of, so they are migrated along with it
+	journal := &Table{Version: table.Version, Columns: slices.Clone(table.Columns)}
+	for _, entry := range entries {
+		if entry.Row != nil {
+			journal.Rows = append(journal.Rows, entry.Row)
+		}
+	}
+	result.Migrations = schema.Migrate(table)
+	schema.Migrate(journal)
+
+	var records []T
+	for _, row := range table.Records() {
+		record, err := c.codec.Decode(row)
+		if err != nil {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("record %v: %v", schema.row(row), err))
+			continue
+		}
+		records = append(records, record)
+	}
+	c.Memory.replace(records)
+
+	rows := journal.Records()
+	for _, entry := range entries {
+		var row Row
+		if entry.Row != nil {
+			row, rows = rows[0], rows[1:]
+		}
+		if err := c.replay(entry, row); err != nil {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("journal %s %q: %v", entry.Op, entry.Key, err))
+			continue
+		}
+		result.Recovered++
+	}
+	c.pending = len(entries)
+
+	records, _ = c.Memory.List()
+	result.Loaded = len(records)
+	if len(result.Migrations) > 0 {
+		return result, c.save()
+	}
+	return result, nil
+}
+
+// PlanMigration reports what Load would change when migrating the snapshot,
+// without changing anything
+func (c *CSV[T]) PlanMigration() (MigrationPlan, error) {
+	plan := MigrationPlan{Path: c.path}
+	data, err := os.ReadFile(c.path)
+	if err != nil && !os.IsNotExist(err) {
+		return plan, err
+	}
+	schema := c.codec.Schema
+	table, err := schema.Read(data)
+	if err != nil {
+		return plan, err
+	}
+
+	before := table.Records()
+	columns := slices.Clone(table.Columns)
+	plan.From, plan.Rows = table.Version, len(table.Rows)
+	plan.Steps = schema.Migrate(table)
+	plan.To = table.Version
+	plan.compare(schema, columns, before, table.Records())
+	return plan, nil
+}
+
+// Save backs up the current snapshot, atomically replaces it with all
+// records and clears the journal
+func (c *CSV[T]) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.save()
+}
+
+func (c *CSV[T]) save() error {
+	var buf bytes.Buffer
+	records, _ := c.Memory.List()
+	rows := make([]Row, len(records))
+	for i, record := range records {
+		rows[i] = c.codec.Encode(record)
+	}
+	if err := c.codec.Schema.Write(&buf, rows); err != nil {
+		return err
+	}
+
+	if err := utils.BackupFile(c.path); err != nil {
+		return fmt.Errorf("backing up %s: %w", c.path, err)
+	}
+	err := utils.WriteFileAtomic(c.path, func(w io.Writer) error {
+		_, err := w.Write(buf.Bytes())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	c.base = snapshotHash(buf.Bytes())
+
+	// The journal now names a stale snapshot, so a crash before it is
+	// removed cannot cause it to be replayed
+	c.pending = 0
+	if err := os.Remove(c.JournalPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Create adds a record and journals the change
+func (c *CSV[T]) Create(record T) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record = c.codec.assign(record)
+	entry := journalEntry{Op: opCreate, Key: c.codec.Key(record), Row: c.row(record)}
+	err := c.change(entry, func() error {
+		_, err := c.Memory.Create(record)
+		return err
+	})
+	return record, err
+}
+
+// Update replaces a record and journals the change. The record must have
+// been read at the version stored.
+func (c *CSV[T]) Update(key string, record T) error {
+	return c.update(key, record, false)
+}
+
+// Overwrite replaces a record whatever version it was read at and journals
+// the change
+func (c *CSV[T]) Overwrite(key string, record T) error {
+	return c.update(key, record, true)
+}
+
+func (c *CSV[T]) update(key string, record T, overwrite bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current, err := c.Memory.Get(key)
+	if err != nil {
+		return err
+	}
+	// The journal holds the record at its new version, so that replaying
+	// it restores the same version
+	if record, err = c.codec.next(current, record, overwrite); err != nil {
+		return err
+	}
+	entry := journalEntry{Op: opUpdate, Key: key, Row: c.row(record)}
+	return c.change(entry, func() error { return c.Memory.set(key, record) })
+}
+
+// Delete removes a record and journals the change
+func (c *CSV[T]) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := journalEntry{Op: opDelete, Key: key}
+	return c.change(entry, func() error { return c.Memory.Delete(key) })
+}
+
+// change applies a change in memory and appends it to the journal, undoing
+// it if the journal cannot be written, and compacts the journal once it is
+// full. The caller holds the lock.
+func (c *CSV[T]) change(entry journalEntry, apply func() error) error {
+	previous, _ := c.Memory.List()
+	if err := apply(); err != nil {
+		return err
+	}
+
+	// The first change after a compaction starts a new journal
+	fresh := c.pending == 0
+	entries := []journalEntry{entry}
+	if fresh {
+		entries = append([]journalEntry{{Op: opBase, Key: c.base}}, entries...)
+	}
+	if err := appendJournal(c.JournalPath(), fresh, entries...); err != nil {
+		c.Memory.replace(previous)
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	c.pending++
+
+	// The change is in memory and in the journal whatever happens to the
+	// compaction, so a failed one is only logged. The journal stays over
+	// CompactEvery entries, so the next change or Save tries again.
+	if c.pending >= CompactEvery {
+		if err := c.save(); err != nil {
+			log.Printf("compacting %s: %v", c.path, err)
+		}
+	}
+	return nil
+}
+
+// row encodes a record in the column order of the current schema
+func (c *CSV[T]) row(record T) []string {
+	return c.codec.Schema.row(c.codec.Encode(record))
+}
+
+// replay applies a journal entry in memory, row is the entry's migrated row
+func (c *CSV[T]) replay(entry journalEntry, row Row) error {
+	switch entry.Op {
+	case opCreate, opUpdate:
+		record, err := c.codec.Decode(row)
+		if err != nil {
+			return err
+		}
+		if entry.Op == opCreate {
+			_, err := c.Memory.Create(record)
+			return err
+		}
+		return c.Memory.set(entry.Key, record)
+	case opDelete:
+		return c.Memory.Delete(entry.Key)
+	default:
+		return fmt.Errorf("unknown journal operation %q", entry.Op)
+	}
+}
@@ -0,0 +1,29 @@
+package Storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCSVLoadCountsOnlyReplayedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.csv")
+	store := NewCSV(path, noteCodec)
+	if _, err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create(note{Text: "kept"}); err != nil {
+		t.Fatal(err)
+	}
+	// An entry that cannot be applied, as left by a damaged journal
+	if err := appendJournal(store.JournalPath(), false, journalEntry{Op: opDelete, Key: "nte-missing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewCSV(path, noteCodec).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Recovered != 1 || len(result.Skipped) != 1 || result.Loaded != 1 {
+		t.Errorf("got %d recovered, %d skipped and %d loaded, want 1 of each", result.Recovered, len(result.Skipped), result.Loaded)
+	}
+}
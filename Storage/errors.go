@@ -0,0 +1,31 @@
+package Storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by repositories. Use errors.Is to test for them, the
+// concrete error is a *RecordError carrying the entity and key involved.
+var (
+	ErrNotFound         = errors.New("record not found")
+	ErrDuplicate        = errors.New("record already exists")
+	ErrReferenced       = errors.New("record is still referenced")
+	ErrInvalidReference = errors.New("referenced record does not exist")
+	ErrConflict         = errors.New("record was changed by someone else")
+)
+
+// RecordError describes a repository failure for a single record
+type RecordError struct {
+	Entity string
+	Key    string
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("%s %q: %v", e.Entity, e.Key, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
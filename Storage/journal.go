@@ -0,0 +1,97 @@
+package Storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+)
+
+// Journal operations. The first entry of every journal is a base entry
+// naming the hash of the snapshot the remaining entries apply to, so a
+// journal left behind by an interrupted compaction is never replayed twice.
+const (
+	opBase   = "base"
+	opCreate = "create"
+	opUpdate = "update"
+	opDelete = "delete"
+)
+
+type journalEntry struct {
+	Op  string   `json:"op"`
+	Key string   `json:"key,omitempty"`
+	Row []string `json:"row,omitempty"`
+}
+
+// snapshotHash identifies the contents of a snapshot file
+func snapshotHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// readJournal returns the entries of the journal at path if it was written
+// on top of the snapshot with hash base. A journal for another snapshot is
+// stale and removed. A partly written last line, left by a crash during an
+// append, is truncated away.
+func readJournal(path, base string) ([]journalEntry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []journalEntry
+	good := 0
+	for good < len(data) {
+		end := bytes.IndexByte(data[good:], '\n')
+		if end < 0 {
+			break
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(data[good:good+end], &entry); err != nil {
+			break
+		}
+		entries = append(entries, entry)
+		good += end + 1
+	}
+
+	if len(entries) == 0 || entries[0].Op != opBase || entries[0].Key != base {
+		return nil, os.Remove(path)
+	}
+	if good < len(data) {
+		if err := os.Truncate(path, int64(good)); err != nil {
+			return nil, err
+		}
+	}
+	return entries[1:], nil
+}
+
+// appendJournal appends entries to the journal at path and syncs it. When
+// fresh is set any existing journal is replaced.
+func appendJournal(path string, fresh bool, entries ...journalEntry) error {
+	flag := os.O_APPEND | os.O_CREATE | os.O_WRONLY
+	if fresh {
+		flag |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
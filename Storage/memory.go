@@ -0,0 +1,146 @@
+package Storage
+
+import "sync"
+
+// Memory is a Repository that keeps its records in a slice. It is safe for
+// concurrent use, and List returns a snapshot that later changes do not
+// affect.
+type Memory[T any] struct {
+	codec   Codec[T]
+	mu      sync.RWMutex
+	records []T
+}
+
+// NewMemory creates an in-memory repository holding the given records
+func NewMemory[T any](codec Codec[T], records ...T) *Memory[T] {
+	return &Memory[T]{codec: codec, records: append([]T(nil), records...)}
+}
+
+// Key returns the ID of record
+func (m *Memory[T]) Key(record T) string {
+	return m.codec.Key(record)
+}
+
+// Get returns the record with the given ID
+func (m *Memory[T]) Get(key string) (T, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := m.index(key)
+	if i < 0 {
+		var zero T
+		return zero, m.codec.error(key, ErrNotFound)
+	}
+	return m.records[i], nil
+}
+
+// List returns a copy of all records in insertion order
+func (m *Memory[T]) List() ([]T, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]T(nil), m.records...), nil
+}
+
+// replace swaps all records at once
+func (m *Memory[T]) replace(records []T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = records
+}
+
+// Create appends a new record, assigning it an ID if it has none, and
+// returns the record as stored
+func (m *Memory[T]) Create(record T) (T, error) {
+	record = m.codec.assign(record)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.index(m.codec.Key(record)) >= 0 {
+		return record, m.codec.error(m.codec.Key(record), ErrDuplicate)
+	}
+	if existing, ok := m.duplicate(record, -1); ok {
+		return record, m.codec.error(existing, ErrDuplicate)
+	}
+	m.records = append(m.records, record)
+	return record, nil
+}
+
+// Update replaces the record with the given ID. The record must have been
+// read at the version stored.
+func (m *Memory[T]) Update(key string, record T) error {
+	return m.update(key, record, false)
+}
+
+// Overwrite replaces the record with the given ID whatever version it was
+// read at
+func (m *Memory[T]) Overwrite(key string, record T) error {
+	return m.update(key, record, true)
+}
+
+func (m *Memory[T]) update(key string, record T, overwrite bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.index(key)
+	if i < 0 {
+		return m.codec.error(key, ErrNotFound)
+	}
+	record, err := m.codec.next(m.records[i], record, overwrite)
+	if err != nil {
+		return err
+	}
+	return m.put(i, record)
+}
+
+// put stores record at position i as it is
+func (m *Memory[T]) put(i int, record T) error {
+	if existing, ok := m.duplicate(record, i); ok {
+		return m.codec.error(existing, ErrDuplicate)
+	}
+	m.records[i] = record
+	return nil
+}
+
+// set replaces the record with the given ID as it is, for records whose
+// version is already settled
+func (m *Memory[T]) set(key string, record T) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.index(key)
+	if i < 0 {
+		return m.codec.error(key, ErrNotFound)
+	}
+	return m.put(i, record)
+}
+
+// Delete removes the record with the given ID
+func (m *Memory[T]) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.index(key)
+	if i < 0 {
+		return m.codec.error(key, ErrNotFound)
+	}
+	m.records = append(m.records[:i], m.records[i+1:]...)
+	return nil
+}
+
+func (m *Memory[T]) index(key string) int {
+	for i, record := range m.records {
+		if m.codec.Key(record) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// duplicate returns the ID of a record other than skip that record clashes
+// with, if there is one
+func (m *Memory[T]) duplicate(record T, skip int) (string, bool) {
+	if m.codec.Same == nil {
+		return "", false
+	}
+	for i, existing := range m.records {
+		if i != skip && m.codec.Same(existing, record) {
+			return m.codec.Key(existing), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,125 @@
+package Storage
+
+import (
+	"project/utils"
+	"slices"
+)
+
+// Repository is the storage interface shared by every entity. Each entity
+// package exposes it under its own name, e.g. Trail.Repository. Records
+// are identified by a stable ID that Create assigns when it is empty.
+type Repository[T any] interface {
+	Get(key string) (T, error)
+	List() ([]T, error)
+	Create(record T) (T, error)
+	Update(key string, record T) error
+	Delete(key string) error
+}
+
+// Store is a Repository persisted by one of the storage backends
+type Store[T any] interface {
+	Repository[T]
+	// Key returns the ID of a record
+	Key(record T) string
+	// Path names where the records are stored
+	Path() string
+	// Load reads the stored records, migrating them to the current schema
+	Load() (LoadResult, error)
+	// PlanMigration reports what Load would migrate without changing anything
+	PlanMigration() (MigrationPlan, error)
+	// Save makes sure every change is written out
+	Save() error
+	// Overwrite replaces a record whatever version it was read at
+	Overwrite(key string, record T) error
+}
+
+// Codec describes how an entity is identified and how it maps to a row of
+// its data file
+type Codec[T any] struct {
+	// Entity is the human readable name used in errors, e.g. "trail"
+	Entity string
+	// Prefix starts every ID generated for the entity, e.g. "trl"
+	Prefix string
+	// Key returns the ID of a record and SetKey assigns one
+	Key    func(T) string
+	SetKey func(T, string) T
+	// Version returns the version of a record and SetVersion assigns one.
+	// When nil, records are not versioned and the last update wins.
+	Version    func(T) int
+	SetVersion func(T, int) T
+	// Same reports whether two records are duplicates of each other.
+	// When nil, duplicate records are allowed.
+	Same func(a, b T) bool
+	// Carry returns record with the fields that updates cannot change
+	// taken from current, so that saving a record over someone else's
+	// update keeps them. When nil, the record is saved over as it is.
+	Carry func(record, current T) T
+	// SameColumn names an indexed column duplicates always share the value
+	// of. SQL repositories then only compare a record with the rows that
+	// hold its value there, rather than with the whole table.
+	SameColumn string
+	// Schema describes the data file, Encode and Decode convert a record
+	// to and from one of its rows
+	Schema Schema
+	Encode func(T) Row
+	Decode func(Row) (T, error)
+}
+
+// assign gives a record without an ID a new one, and a new record its
+// first version
+func (c Codec[T]) assign(record T) T {
+	if c.Key(record) == "" {
+		record = c.SetKey(record, utils.NewID(c.Prefix))
+	}
+	if c.Version != nil && c.Version(record) == 0 {
+		record = c.SetVersion(record, 1)
+	}
+	return record
+}
+
+func (c Codec[T]) error(key string, err error) error {
+	return &RecordError{Entity: c.Entity, Key: key, Err: err}
+}
+
+// LoadResult reports what a Store read when it was loaded
+type LoadResult struct {
+	Loaded     int      // records available after loading
+	Recovered  int      // journal entries replayed on top of the snapshot
+	Migrations []string // schema migrations applied to the stored records
+	Skipped    []string // rows and journal entries that could not be applied
+}
+
+// MigrationPlan reports what migrating stored records to the current
+// schema would change
+type MigrationPlan struct {
+	Path           string
+	From, To       int
+	Steps          []string
+	Rows           int
+	ChangedRows    int
+	AddedColumns   []string
+	RemovedColumns []string
+}
+
+// compare fills in the columns and rows a migration from columns and
+// before to after changes
+func (p *MigrationPlan) compare(schema Schema, columns []string, before, after []Row) {
+	for _, column := range schema.Columns {
+		if !slices.Contains(columns, column) {
+			p.AddedColumns = append(p.AddedColumns, column)
+		}
+	}
+	for _, column := range columns {
+		if !slices.Contains(schema.Columns, column) {
+			p.RemovedColumns = append(p.RemovedColumns, column)
+		}
+	}
+	for i := range after {
+		for _, column := range schema.Columns {
+			if before[i][column] != after[i][column] {
+				p.ChangedRows++
+				break
+			}
+		}
+	}
+}
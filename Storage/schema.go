@@ -0,0 +1,171 @@
+package Storage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// markerColumn starts the first row of a versioned data file, e.g.
+// "#schema,trails,3". The header row with the column names follows it.
+const markerColumn = "#schema"
+
+// Row is a record as column name to value
+type Row map[string]string
+
+// Schema describes the columns of a data file and how to upgrade files
+// written by older versions of the program
+type Schema struct {
+	Name    string
+	Version int
+	// Columns lists the columns in file order, the first one holds the ID
+	Columns []string
+	// Types gives the SQL type of columns that are not TEXT
+	Types map[string]string
+	// Indexes lists the columns SQL tables are indexed on
+	Indexes []string
+	// Legacy identifies files written before header rows existed from the
+	// width of their rows, returning their version and columns
+	Legacy func(width int) (version int, columns []string, ok bool)
+	// Migrations upgrade a table one version at a time, in order
+	Migrations []Migration
+}
+
+// Migration upgrades a table to version To
+type Migration struct {
+	To          int
+	Description string
+	// Apply changes the table, it is nil when only the file layout changes
+	Apply func(t *Table)
+}
+
+// Table is the content of a data file at some schema version
+type Table struct {
+	Version int
+	Columns []string
+	Rows    [][]string
+}
+
+// Index returns the position of a column, or -1
+func (t *Table) Index(column string) int {
+	return slices.Index(t.Columns, column)
+}
+
+// AddColumn appends a column filled in by value for each row
+func (t *Table) AddColumn(column string, value func(row []string) string) {
+	for i, row := range t.Rows {
+		t.Rows[i] = append(row, value(row))
+	}
+	t.Columns = append(t.Columns, column)
+}
+
+// RemoveColumn drops a column from every row
+func (t *Table) RemoveColumn(column string) {
+	i := t.Index(column)
+	if i < 0 {
+		return
+	}
+	for j, row := range t.Rows {
+		if i < len(row) {
+			t.Rows[j] = slices.Delete(row, i, i+1)
+		}
+	}
+	t.Columns = slices.Delete(t.Columns, i, i+1)
+}
+
+// Records returns the rows as column name to value
+func (t *Table) Records() []Row {
+	records := make([]Row, len(t.Rows))
+	for i, row := range t.Rows {
+		records[i] = t.record(row)
+	}
+	return records
+}
+
+func (t *Table) record(row []string) Row {
+	record := make(Row, len(t.Columns))
+	for i, column := range t.Columns {
+		if i < len(row) {
+			record[column] = row[i]
+		}
+	}
+	return record
+}
+
+// Read parses a data file. An empty file is an empty table at the current
+// version.
+func (s Schema) Read(data []byte) (*Table, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return &Table{Version: s.Version, Columns: slices.Clone(s.Columns)}, nil
+	}
+
+	if rows[0][0] == markerColumn {
+		if len(rows[0]) < 3 || rows[0][1] != s.Name {
+			return nil, fmt.Errorf("not a %s file: %v", s.Name, rows[0])
+		}
+		version, err := strconv.Atoi(rows[0][2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema version %q", rows[0][2])
+		}
+		if version > s.Version {
+			return nil, fmt.Errorf("schema version %d is newer than this program supports (%d)", version, s.Version)
+		}
+		if len(rows) < 2 {
+			return nil, fmt.Errorf("missing header row")
+		}
+		return &Table{Version: version, Columns: rows[1], Rows: rows[2:]}, nil
+	}
+
+	version, columns, ok := s.Legacy(len(rows[0]))
+	if !ok {
+		return nil, fmt.Errorf("unrecognised %s file with %d columns", s.Name, len(rows[0]))
+	}
+	return &Table{Version: version, Columns: slices.Clone(columns), Rows: rows}, nil
+}
+
+// Migrate upgrades the table to the current version and returns the
+// descriptions of the migrations it applied
+func (s Schema) Migrate(t *Table) []string {
+	var applied []string
+	for _, migration := range s.Migrations {
+		if migration.To <= t.Version {
+			continue
+		}
+		if migration.Apply != nil {
+			migration.Apply(t)
+		}
+		t.Version = migration.To
+		applied = append(applied, fmt.Sprintf("version %d: %s", migration.To, migration.Description))
+	}
+	return applied
+}
+
+// Write writes the version marker, the header row and the records
+func (s Schema) Write(w io.Writer, records []Row) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{markerColumn, s.Name, strconv.Itoa(s.Version)})
+	writer.Write(s.Columns)
+	for _, record := range records {
+		writer.Write(s.row(record))
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// row returns a record in column order
+func (s Schema) row(record Row) []string {
+	row := make([]string, len(s.Columns))
+	for i, column := range s.Columns {
+		row[i] = record[column]
+	}
+	return row
+}
@@ -0,0 +1,446 @@
+package Storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Database is an SQL database shared by the SQL repositories of several
+// entities. It can be reopened, for example after restoring a backup.
+type Database struct {
+	Driver string
+	Path   string
+	DB     *sql.DB
+}
+
+// OpenDatabase opens the database file at path with the given driver
+func OpenDatabase(driver, path string) (*Database, error) {
+	d := &Database{Driver: driver, Path: path}
+	return d, d.Reopen()
+}
+
+// Reopen closes the database if it is open and opens it again. It must not
+// run while the database is in use.
+func (d *Database) Reopen() error {
+	if d.DB != nil {
+		d.DB.Close()
+	}
+	db, err := sql.Open(d.Driver, d.Path)
+	if err != nil {
+		return err
+	}
+	// An embedded database file takes one writer at a time, so rather
+	// than failing concurrent transactions as busy, queue them for the one
+	// connection
+	db.SetMaxOpenConns(1)
+	d.DB = db
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS schema_versions (name TEXT PRIMARY KEY, version INTEGER NOT NULL)`)
+	return err
+}
+
+// Close closes the database
+func (d *Database) Close() error {
+	return d.DB.Close()
+}
+
+// SQL is a Repository backed by a table in an SQL database. The table is
+// named after the schema and has a column per schema column. Every change
+// runs in its own transaction, so it is safe for concurrent use.
+type SQL[T any] struct {
+	db    *Database
+	codec Codec[T]
+	tx    *sql.Tx // set for a repository returned by In
+}
+
+// NewSQL creates a repository for the entity's table in db. Call Load to
+// create or migrate the table.
+func NewSQL[T any](db *Database, codec Codec[T]) *SQL[T] {
+	return &SQL[T]{db: db, codec: codec}
+}
+
+// In returns a repository for the same table that reads and changes it
+// within tx, so that changes to several tables are committed or rolled back
+// together. It must only be used until tx ends.
+func (r *SQL[T]) In(tx *sql.Tx) *SQL[T] {
+	return &SQL[T]{db: r.db, codec: r.codec, tx: tx}
+}
+
+// Key returns the ID of record
+func (r *SQL[T]) Key(record T) string {
+	return r.codec.Key(record)
+}
+
+// Path names the database file and table
+func (r *SQL[T]) Path() string {
+	return r.db.Path + ":" + r.codec.Schema.Name
+}
+
+// Get returns the record with the given ID
+func (r *SQL[T]) Get(key string) (T, error) {
+	records, err := r.query(r.conn(), "WHERE "+quote(r.keyColumn())+" = ?", key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if len(records) == 0 {
+		var zero T
+		return zero, r.codec.error(key, ErrNotFound)
+	}
+	return records[0], nil
+}
+
+// List returns all records in insertion order
+func (r *SQL[T]) List() ([]T, error) {
+	return r.query(r.conn(), "")
+}
+
+// Create inserts a new record, assigning it an ID if it has none, and
+// returns the record as stored
+func (r *SQL[T]) Create(record T) (T, error) {
+	record = r.codec.assign(record)
+	err := r.transaction(func(tx *sql.Tx) error {
+		if err := r.checkDuplicate(tx, record); err != nil {
+			return err
+		}
+		return r.insert(tx, r.codec.Encode(record))
+	})
+	return record, err
+}
+
+// Update replaces the record with the given ID. The record must have been
+// read at the version stored.
+func (r *SQL[T]) Update(key string, record T) error {
+	return r.update(key, record, false)
+}
+
+// Overwrite replaces the record with the given ID whatever version it was
+// read at
+func (r *SQL[T]) Overwrite(key string, record T) error {
+	return r.update(key, record, true)
+}
+
+func (r *SQL[T]) update(key string, record T, overwrite bool) error {
+	return r.transaction(func(tx *sql.Tx) error {
+		current, err := r.query(tx, "WHERE "+quote(r.keyColumn())+" = ?", key)
+		if err != nil {
+			return err
+		}
+		if len(current) == 0 {
+			return r.codec.error(key, ErrNotFound)
+		}
+		if record, err = r.codec.next(current[0], record, overwrite); err != nil {
+			return err
+		}
+		if err := r.checkDuplicate(tx, record, key); err != nil {
+			return err
+		}
+		row := r.codec.Encode(record)
+		columns := r.codec.Schema.Columns
+		assignments := make([]string, len(columns))
+		args := make([]any, len(columns), len(columns)+1)
+		for i, column := range columns {
+			assignments[i] = quote(column) + " = ?"
+			args[i] = row[column]
+		}
+		_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET %s WHERE %s = ?",
+			r.table(), strings.Join(assignments, ", "), quote(r.keyColumn())), append(args, key)...)
+		return err
+	})
+}
+
+// Delete removes the record with the given ID
+func (r *SQL[T]) Delete(key string) error {
+	result, err := r.conn().Exec(fmt.Sprintf("DELETE FROM %s WHERE %s = ?", r.table(), quote(r.keyColumn())), key)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return r.codec.error(key, ErrNotFound)
+	}
+	return nil
+}
+
+// Save has nothing to do, every change is committed as it is made
+func (r *SQL[T]) Save() error {
+	return nil
+}
+
+// Load creates the table if it does not exist yet and migrates it if it was
+// created by an older version of the program
+func (r *SQL[T]) Load() (LoadResult, error) {
+	var result LoadResult
+	err := r.transaction(func(tx *sql.Tx) error {
+		table, err := r.read(tx)
+		if err != nil {
+			return err
+		}
+		if table == nil {
+			return r.create(tx, &Table{Version: r.codec.Schema.Version, Columns: r.codec.Schema.Columns})
+		}
+		if table.Version == r.codec.Schema.Version {
+			return nil
+		}
+		result.Migrations = r.codec.Schema.Migrate(table)
+		if _, err := tx.Exec("DROP TABLE " + r.table()); err != nil {
+			return err
+		}
+		return r.create(tx, table)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	records, err := r.List()
+	result.Loaded = len(records)
+	return result, err
+}
+
+// PlanMigration reports what Load would change, without changing anything
+func (r *SQL[T]) PlanMigration() (MigrationPlan, error) {
+	plan := MigrationPlan{Path: r.Path(), To: r.codec.Schema.Version}
+	err := r.transaction(func(tx *sql.Tx) error {
+		table, err := r.read(tx)
+		if err != nil || table == nil {
+			plan.Steps = []string{"create the table"}
+			plan.AddedColumns = r.codec.Schema.Columns
+			return err
+		}
+		before := table.Records()
+		columns := slices.Clone(table.Columns)
+		plan.From, plan.Rows = table.Version, len(table.Rows)
+		plan.Steps = r.codec.Schema.Migrate(table)
+		plan.compare(r.codec.Schema, columns, before, table.Records())
+		return nil
+	})
+	return plan, err
+}
+
+// Import inserts records in a single transaction of tx, failing if any of
+// them already exists
+func (r *SQL[T]) Import(tx *sql.Tx, records []T) error {
+	// The stored records are read once, and each imported one is checked
+	// against them and the ones imported before it in memory
+	stored, err := r.query(tx, "")
+	if err != nil {
+		return err
+	}
+	imported := NewMemory(r.codec, stored...)
+	for _, record := range records {
+		record, err := imported.Create(record)
+		if err != nil {
+			return err
+		}
+		if err := r.insert(tx, r.codec.Encode(record)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read returns the table and its schema version, or nil if it does not
+// exist yet
+func (r *SQL[T]) read(tx *sql.Tx) (*Table, error) {
+	table := &Table{}
+	err := tx.QueryRow("SELECT version FROM schema_versions WHERE name = ?", r.codec.Schema.Name).Scan(&table.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query("SELECT * FROM " + r.table() + " ORDER BY rowid")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if table.Columns, err = rows.Columns(); err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		row, err := scan(rows, len(table.Columns))
+		if err != nil {
+			return nil, err
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table, rows.Err()
+}
+
+// create creates the table with its indexes and fills it with the rows of t
+func (r *SQL[T]) create(tx *sql.Tx, t *Table) error {
+	schema := r.codec.Schema
+	definitions := make([]string, len(schema.Columns))
+	for i, column := range schema.Columns {
+		definitions[i] = quote(column) + " " + schema.sqlType(column)
+		if column == r.keyColumn() {
+			definitions[i] += " PRIMARY KEY"
+		}
+	}
+	statements := []string{fmt.Sprintf("CREATE TABLE %s (%s)", r.table(), strings.Join(definitions, ", "))}
+	for _, column := range schema.Indexes {
+		statements = append(statements, fmt.Sprintf("CREATE INDEX %s ON %s (%s)",
+			quote(schema.Name+"_"+column), r.table(), quote(column)))
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range t.Records() {
+		if err := r.insert(tx, row); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec("INSERT OR REPLACE INTO schema_versions (name, version) VALUES (?, ?)", schema.Name, schema.Version)
+	return err
+}
+
+func (r *SQL[T]) insert(tx *sql.Tx, row Row) error {
+	columns := r.codec.Schema.Columns
+	names := make([]string, len(columns))
+	args := make([]any, len(columns))
+	for i, column := range columns {
+		names[i] = quote(column)
+		args[i] = row[column]
+	}
+	_, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", r.table(),
+		strings.Join(names, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")), args...)
+	return err
+}
+
+// query returns the records matching a WHERE clause in insertion order
+func (r *SQL[T]) query(q interface {
+	Query(string, ...any) (*sql.Rows, error)
+}, where string, args ...any) ([]T, error) {
+	columns := r.codec.Schema.Columns
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = quote(column)
+	}
+	rows, err := q.Query(fmt.Sprintf("SELECT %s FROM %s %s ORDER BY rowid", strings.Join(names, ", "), r.table(), where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []T
+	for rows.Next() {
+		values, err := scan(rows, len(columns))
+		if err != nil {
+			return nil, err
+		}
+		table := Table{Columns: columns, Rows: [][]string{values}}
+		record, err := r.codec.Decode(table.Records()[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s %q: %w", r.codec.Entity, values[0], err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// checkDuplicate fails if another record has the ID of record or, when the
+// codec defines duplicates, is a duplicate of it. The record being updated
+// is identified by skip. Only the rows sharing the record's value of the
+// codec's SameColumn are compared, when it names one.
+func (r *SQL[T]) checkDuplicate(tx *sql.Tx, record T, skip ...string) error {
+	key := r.codec.Key(record)
+	if !slices.Contains(skip, key) {
+		var n int
+		err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = ?", r.table(), quote(r.keyColumn())), key).Scan(&n)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return r.codec.error(key, ErrDuplicate)
+		}
+	}
+	if r.codec.Same == nil {
+		return nil
+	}
+
+	where, args := "", []any(nil)
+	if column := r.codec.SameColumn; column != "" {
+		where, args = "WHERE "+quote(column)+" = ?", []any{r.codec.Encode(record)[column]}
+	}
+	records, err := r.query(tx, where, args...)
+	if err != nil {
+		return err
+	}
+	for _, existing := range records {
+		if !slices.Contains(skip, r.codec.Key(existing)) && r.codec.Same(existing, record) {
+			return r.codec.error(r.codec.Key(existing), ErrDuplicate)
+		}
+	}
+	return nil
+}
+
+// conn is what the repository runs statements on: its transaction if it
+// has one, the database otherwise
+func (r *SQL[T]) conn() interface {
+	Exec(string, ...any) (sql.Result, error)
+	Query(string, ...any) (*sql.Rows, error)
+} {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db.DB
+}
+
+// transaction runs do in a transaction of its own, or in the repository's
+// transaction if it has one
+func (r *SQL[T]) transaction(do func(tx *sql.Tx) error) error {
+	if r.tx != nil {
+		return do(r.tx)
+	}
+	tx, err := r.db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	if err := do(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQL[T]) table() string {
+	return quote(r.codec.Schema.Name)
+}
+
+func (r *SQL[T]) keyColumn() string {
+	return r.codec.Schema.Columns[0]
+}
+
+func (s Schema) sqlType(column string) string {
+	if t, ok := s.Types[column]; ok {
+		return t
+	}
+	return "TEXT"
+}
+
+// scan reads a row of n columns as strings, NULL reads as ""
+func scan(rows *sql.Rows, n int) ([]string, error) {
+	values := make([]sql.NullString, n)
+	pointers := make([]any, n)
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+	row := make([]string, n)
+	for i, value := range values {
+		row[i] = value.String
+	}
+	return row, nil
+}
+
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,62 @@
+package Storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLDuplicates(t *testing.T) {
+	db, err := OpenDatabase("sqlite", filepath.Join(t.TempDir(), "notes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// Notes with the same text are duplicates, and the text column narrows
+	// the rows compared
+	codec := noteCodec
+	codec.Schema.Indexes = []string{"text"}
+	codec.SameColumn = "text"
+	store := NewSQL(db, codec)
+	if _, err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := store.Create(note{Text: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Create(note{Text: "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create(note{Text: "first"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("creating a duplicate: got %v, want %v", err, ErrDuplicate)
+	}
+	second.Text = "first"
+	if err := store.Update(second.ID, second); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("updating into a duplicate: got %v, want %v", err, ErrDuplicate)
+	}
+	if err := store.Update(first.ID, first); err != nil {
+		t.Errorf("updating a record unchanged: %v", err)
+	}
+
+	for _, records := range [][]note{
+		{{Text: "third"}, {Text: "second"}},
+		{{Text: "third"}, {Text: "third"}},
+		{{Text: "third"}, {ID: first.ID, Text: "fourth"}},
+	} {
+		tx, err := db.DB.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Import(tx, records)
+		tx.Rollback()
+		if !errors.Is(err, ErrDuplicate) {
+			t.Errorf("importing %+v: got %v, want %v", records, err, ErrDuplicate)
+		}
+	}
+	if records, _ := store.List(); len(records) != 2 {
+		t.Errorf("got %d records after the failed imports, want 2", len(records))
+	}
+}
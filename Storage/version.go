@@ -0,0 +1,64 @@
+package Storage
+
+import (
+	"errors"
+	"fmt"
+	"project/utils"
+)
+
+// next returns record as the version that follows current. Unless
+// overwrite is set, record must have been read at the current version:
+// otherwise someone else updated it meanwhile and next returns an
+// ErrConflict error.
+func (c Codec[T]) next(current, record T, overwrite bool) (T, error) {
+	if c.Version == nil {
+		return record, nil
+	}
+	version := c.Version(current)
+	if !overwrite && c.Version(record) != version {
+		return record, c.error(c.Key(current), fmt.Errorf("%w (read at version %d, now at version %d)", ErrConflict, c.Version(record), version))
+	}
+	return c.SetVersion(record, version+1), nil
+}
+
+// Diff lists the fields in which mine differs from theirs, other than the
+// version
+func (c Codec[T]) Diff(mine, theirs T) []utils.Difference {
+	if c.Version != nil {
+		mine, theirs = c.SetVersion(mine, 0), c.SetVersion(theirs, 0)
+	}
+	a, b := c.Encode(mine), c.Encode(theirs)
+	var diff []utils.Difference
+	for _, column := range c.Schema.Columns {
+		if a[column] != b[column] {
+			diff = append(diff, utils.Difference{Field: column, Mine: a[column], Theirs: b[column]})
+		}
+	}
+	return diff
+}
+
+// UpdateResolving updates record in repo. If someone else updated it since
+// it was read, confirm is shown how their version differs and decides
+// whether to save record over it, keeping the fields the codec carries
+// from their version. Updates that change nothing else are saved over
+// without asking.
+func (c Codec[T]) UpdateResolving(repo Repository[T], record T, confirm func(entity string, diff []utils.Difference) bool) error {
+	key := c.Key(record)
+	for {
+		err := repo.Update(key, record)
+		if !errors.Is(err, ErrConflict) {
+			return err
+		}
+		current, getErr := repo.Get(key)
+		if getErr != nil {
+			return getErr
+		}
+		if c.Carry != nil {
+			record = c.Carry(record, current)
+		}
+		if diff := c.Diff(record, current); len(diff) > 0 && !confirm(c.Entity, diff) {
+			return err
+		}
+		record = c.SetVersion(record, c.Version(current))
+	}
+}
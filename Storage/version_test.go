@@ -0,0 +1,42 @@
+package Storage
+
+import (
+	"project/utils"
+	"testing"
+)
+
+func TestUpdateResolvingCarriesFields(t *testing.T) {
+	// Notes keep their text when saved over, as a trail keeps its status
+	codec := noteCodec
+	codec.Carry = func(record, current note) note {
+		record.Text = current.Text
+		return record
+	}
+	repo := NewMemory(codec)
+	record, err := repo.Create(note{Text: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs := record
+	theirs.Text = "theirs"
+	if err := repo.Update(theirs.ID, theirs); err != nil {
+		t.Fatal(err)
+	}
+
+	record.Text = "mine"
+	asked := false
+	err = codec.UpdateResolving(repo, record, func(string, []utils.Difference) bool {
+		asked = true
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := repo.Get(record.ID)
+	if got.Text != "theirs" || got.Version != 3 {
+		t.Errorf("got %+v, want their text at version 3", got)
+	}
+	if asked {
+		t.Error("asked to save over an update that differs only in carried fields")
+	}
+}
@@ -0,0 +1,169 @@
+package Trail
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Difficulty is how hard a trail is, on one of the rating systems: the
+// standard Easy to Expert scale, a numeric 1 to 5 scale, or Yosemite
+// Decimal System classes (Class 1 to Class 5, and 5.0 to 5.15 for
+// technical climbing)
+type Difficulty string
+
+// Standard difficulty ratings, easiest first
+const (
+	Easy     Difficulty = "Easy"
+	Moderate Difficulty = "Moderate"
+	Hard     Difficulty = "Hard"
+	Expert   Difficulty = "Expert"
+)
+
+// Standard lists the standard difficulty ratings, easiest first
+var Standard = []Difficulty{Easy, Moderate, Hard, Expert}
+
+// RatingSystem is the scale a difficulty is rated on
+type RatingSystem string
+
+// Rating systems
+const (
+	StandardRating RatingSystem = "standard"
+	NumericRating  RatingSystem = "numeric"
+	YDSRating      RatingSystem = "yds"
+)
+
+// DifficultyHelp describes the difficulties ParseDifficulty accepts
+const DifficultyHelp = "Easy, Moderate, Hard or Expert, 1 to 5, or a class such as Class 3 or 5.9"
+
+var (
+	ydsClass   = regexp.MustCompile(`^class ?([1-5])$`)
+	ydsDecimal = regexp.MustCompile(`^5\.([0-9]|1[0-5])([a-d])?$`)
+)
+
+// ParseDifficulty returns the difficulty written as s, ignoring case. Medium
+// is taken as Moderate. Letter grades are only used from 5.10 up.
+func ParseDifficulty(s string) (Difficulty, error) {
+	text := strings.ToLower(strings.TrimSpace(s))
+	for _, d := range Standard {
+		if text == strings.ToLower(string(d)) {
+			return d, nil
+		}
+	}
+	if text == "medium" {
+		return Moderate, nil
+	}
+	if n, err := strconv.Atoi(text); err == nil && n >= 1 && n <= 5 {
+		return Difficulty(strconv.Itoa(n)), nil
+	}
+	if m := ydsClass.FindStringSubmatch(text); m != nil {
+		return Difficulty("Class " + m[1]), nil
+	}
+	if m := ydsDecimal.FindStringSubmatch(text); m != nil {
+		if n, _ := strconv.Atoi(m[1]); m[2] == "" || n >= 10 {
+			return Difficulty("5." + m[1] + m[2]), nil
+		}
+	}
+	return "", fmt.Errorf("unknown difficulty %q, use %s", s, DifficultyHelp)
+}
+
+// Valid reports whether d is a difficulty as ParseDifficulty writes it
+func (d Difficulty) Valid() bool {
+	parsed, err := ParseDifficulty(string(d))
+	return err == nil && parsed == d
+}
+
+// System returns the rating system d is on
+func (d Difficulty) System() RatingSystem {
+	switch {
+	case strings.HasPrefix(string(d), "Class "), strings.HasPrefix(string(d), "5."):
+		return YDSRating
+	case len(d) == 1:
+		return NumericRating
+	}
+	return StandardRating
+}
+
+// Rank places d on the standard scale, from 1 for Easy to 4 for Expert, so
+// that difficulties on different systems can be compared. It is 0 for an
+// invalid difficulty.
+func (d Difficulty) Rank() int {
+	if !d.Valid() {
+		return 0
+	}
+	switch d.System() {
+	case NumericRating:
+		return []int{1, 2, 2, 3, 4}[d[0]-'1']
+	case YDSRating:
+		if d[0] == '5' {
+			return 4
+		}
+		return min(int(d[len(d)-1]-'0'), 4)
+	}
+	for i, standard := range Standard {
+		if d == standard {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Standard returns the standard rating d corresponds to
+func (d Difficulty) Standard() Difficulty {
+	if rank := d.Rank(); rank > 0 {
+		return Standard[rank-1]
+	}
+	return ""
+}
+
+func (d Difficulty) String() string {
+	return string(d)
+}
+
+// Set parses a difficulty, so that a *Difficulty can be used as a flag
+func (d *Difficulty) Set(value string) error {
+	difficulty, err := ParseDifficulty(value)
+	if err != nil {
+		return err
+	}
+	*d = difficulty
+	return nil
+}
+
+// SuggestDifficulty rates a trail from its length and elevation gain, using
+// Naismith's rule: an hour for every 3 miles plus an hour for every 2000
+// feet of climbing
+func SuggestDifficulty(length Distance, elevationGain Elevation) Difficulty {
+	switch hours := length.Miles()/3 + elevationGain.Feet()/2000; {
+	case hours < 1.5:
+		return Easy
+	case hours < 3:
+		return Moderate
+	case hours < 5:
+		return Hard
+	}
+	return Expert
+}
+
+// legacyDifficulty maps the free text difficulties of trails written before
+// difficulties were typed to a rating. Common words for the standard
+// ratings are recognised, and anything else is given the rating suggested
+// by the trail's length in miles.
+func legacyDifficulty(text string, length float64) Difficulty {
+	if difficulty, err := ParseDifficulty(text); err == nil {
+		return difficulty
+	}
+	text = strings.ToLower(text)
+	switch {
+	case strings.Contains(text, "extreme"), strings.Contains(text, "very hard"), strings.Contains(text, "very difficult"):
+		return Expert
+	case strings.Contains(text, "difficult"), strings.Contains(text, "strenuous"), strings.Contains(text, "hard"):
+		return Hard
+	case strings.Contains(text, "intermediate"), strings.Contains(text, "medium"), strings.Contains(text, "moderate"):
+		return Moderate
+	case strings.Contains(text, "beginner"), strings.Contains(text, "easy"):
+		return Easy
+	}
+	return SuggestDifficulty(Miles(length), Elevation{})
+}
@@ -0,0 +1,132 @@
+package Trail
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// gpxFile is the part of a GPX 1.1 file that tracks are read from
+type gpxFile struct {
+	XMLName  xml.Name `xml:"gpx"`
+	Metadata struct {
+		Name string `xml:"name"`
+	} `xml:"metadata"`
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+	Routes []struct {
+		Name   string     `xml:"name"`
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+}
+
+type gpxPoint struct {
+	Lat float64  `xml:"lat,attr"`
+	Lon float64  `xml:"lon,attr"`
+	Ele *float64 `xml:"ele"`
+}
+
+// GPXImporter stores track as the geometry of trail, filling in the
+// trail's length, elevation gain and trailhead from it. A trail without an
+// ID is added. It returns the trail as stored and the stats of the track.
+type GPXImporter func(trail Trail, track Track) (Trail, TrackStats, error)
+
+// ErrNoTrack is returned for a GPX file without track or route points
+var ErrNoTrack = errors.New("the GPX file has no track or route points")
+
+// ReadGPX reads the track of a trail from a GPX file. The segments of every
+// track in the file are joined into one line; a file without tracks is read
+// from its routes instead. The track is named after the first named track
+// or route, or else the file.
+func ReadGPX(r io.Reader) (Track, error) {
+	var file gpxFile
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return Track{}, fmt.Errorf("reading GPX: %w", err)
+	}
+
+	var track Track
+	add := func(name string, points []gpxPoint) {
+		if track.Name == "" {
+			track.Name = name
+		}
+		for _, p := range points {
+			track.Points = append(track.Points, TrackPoint{Coordinates: Coordinates{Latitude: p.Lat, Longitude: p.Lon}, Elevation: p.Ele})
+		}
+	}
+	for _, trk := range file.Tracks {
+		for _, segment := range trk.Segments {
+			add(trk.Name, segment.Points)
+		}
+	}
+	if len(track.Points) == 0 {
+		for _, route := range file.Routes {
+			add(route.Name, route.Points)
+		}
+	}
+	if len(track.Points) == 0 {
+		return Track{}, ErrNoTrack
+	}
+	if track.Name == "" {
+		track.Name = file.Metadata.Name
+	}
+	for i, point := range track.Points {
+		if err := point.Validate(); err != nil {
+			return Track{}, fmt.Errorf("reading GPX: point %d: %w", i+1, err)
+		}
+	}
+	return track, nil
+}
+
+// WriteGPX writes the track of a trail as a GPX 1.1 file with one track
+// segment
+func WriteGPX(w io.Writer, trail Trail, track Track) error {
+	type point struct {
+		Lat string `xml:"lat,attr"`
+		Lon string `xml:"lon,attr"`
+		Ele string `xml:"ele,omitempty"`
+	}
+	type document struct {
+		XMLName xml.Name `xml:"gpx"`
+		Version string   `xml:"version,attr"`
+		Creator string   `xml:"creator,attr"`
+		XMLNS   string   `xml:"xmlns,attr"`
+		Name    string   `xml:"metadata>name"`
+		Track   struct {
+			Name   string  `xml:"name"`
+			Desc   string  `xml:"desc,omitempty"`
+			Points []point `xml:"trkseg>trkpt"`
+		} `xml:"trk"`
+	}
+
+	doc := document{Version: "1.1", Creator: "Trail Management System", XMLNS: "http://www.topografix.com/GPX/1/1", Name: trail.Name}
+	doc.Track.Name = trail.Name
+	doc.Track.Desc = trail.Location.String()
+	for _, p := range track.Points {
+		var ele string
+		if p.Elevation != nil {
+			ele = strconv.FormatFloat(*p.Elevation, 'f', -1, 64)
+		}
+		doc.Track.Points = append(doc.Track.Points, point{
+			Lat: strconv.FormatFloat(p.Latitude, 'f', -1, 64),
+			Lon: strconv.FormatFloat(p.Longitude, 'f', -1, 64),
+			Ele: ele,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,245 @@
+package Trail
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Location is where a trail is: the nearest city, the state or region, the
+// land unit managing it, such as a national forest or park, and the
+// coordinates of its trailhead
+type Location struct {
+	City     string `json:"city,omitempty"`
+	Region   string `json:"region,omitempty"`
+	LandUnit string `json:"land_unit,omitempty"`
+	// Trailhead is nil when the coordinates are not known
+	Trailhead *Coordinates `json:"trailhead,omitempty"`
+}
+
+// landUnitWords mark the part of a location naming a land unit
+var landUnitWords = []string{"national forest", "national park", "state park", "state forest", "wilderness", "recreation area", "monument", "preserve", "blm"}
+
+// ParseLocation reads a location written as text, e.g. "Tempe, AZ",
+// "Clarkston WA" or "Idaho". A land unit can follow in parentheses, e.g.
+// "Boise, ID (Boise National Forest)", or be one of the comma separated
+// parts. The trailhead is not part of the text.
+func ParseLocation(s string) Location {
+	var location Location
+	s = strings.TrimSpace(s)
+	if open := strings.Index(s, "("); open >= 0 && strings.HasSuffix(s, ")") {
+		location.LandUnit = strings.TrimSpace(s[open+1 : len(s)-1])
+		s = strings.TrimSpace(s[:open])
+	}
+
+	var parts []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "":
+		case location.LandUnit == "" && isLandUnit(part):
+			location.LandUnit = part
+		default:
+			parts = append(parts, part)
+		}
+	}
+	switch len(parts) {
+	case 0:
+		return location
+	case 1:
+		// "Idaho", or "Clarkston WA" with the region at the end
+		words := strings.Fields(parts[0])
+		for n := min(len(words), 3); n >= 1; n-- {
+			region := strings.Join(words[len(words)-n:], " ")
+			if isRegion(region) {
+				location.City = strings.Join(words[:len(words)-n], " ")
+				location.Region = region
+				return location
+			}
+		}
+		location.City = parts[0]
+	default:
+		location.City = strings.Join(parts[:len(parts)-1], ", ")
+		location.Region = parts[len(parts)-1]
+	}
+	return location
+}
+
+// isLandUnit reports whether text names a land unit
+func isLandUnit(text string) bool {
+	text = strings.ToLower(text)
+	return slices.ContainsFunc(landUnitWords, func(word string) bool { return strings.Contains(text, word) })
+}
+
+// isRegion reports whether text is a US state, by name or by postal code
+// in capitals
+func isRegion(text string) bool {
+	_, isCode := states[text]
+	_, isName := stateCodes[strings.ToLower(text)]
+	return isCode || isName
+}
+
+// RegionCode returns the key regions are compared by: the postal code of a
+// US state given by name, or else the region in upper case
+func RegionCode(region string) string {
+	region = strings.TrimSpace(region)
+	if code, ok := stateCodes[strings.ToLower(region)]; ok {
+		return code
+	}
+	return strings.ToUpper(region)
+}
+
+// Empty reports whether the location names no place
+func (l Location) Empty() bool {
+	return strings.TrimSpace(l.City) == "" && strings.TrimSpace(l.Region) == "" && strings.TrimSpace(l.LandUnit) == ""
+}
+
+// InRegion reports whether the location is in region, given by name or
+// code
+func (l Location) InRegion(region string) bool {
+	return l.Region != "" && RegionCode(l.Region) == RegionCode(region)
+}
+
+// Same reports whether two locations name the same place, ignoring case,
+// how the region is written and the trailhead
+func (l Location) Same(other Location) bool {
+	return strings.EqualFold(l.City, other.City) && RegionCode(l.Region) == RegionCode(other.Region) &&
+		strings.EqualFold(l.LandUnit, other.LandUnit)
+}
+
+// String writes the location the way ParseLocation reads it
+func (l Location) String() string {
+	var parts []string
+	for _, part := range []string{l.City, l.Region} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	text := strings.Join(parts, ", ")
+	switch {
+	case l.LandUnit == "":
+		return text
+	case text == "":
+		return l.LandUnit
+	}
+	return text + " (" + l.LandUnit + ")"
+}
+
+// UnmarshalJSON reads a location object, or a location written as text as
+// trails exported before locations were structured have it
+func (l *Location) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*l = ParseLocation(text)
+		return nil
+	}
+	type location Location
+	return json.Unmarshal(data, (*location)(l))
+}
+
+// Coordinates is a point in decimal degrees
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// earthRadius is the mean radius of the earth in miles
+const earthRadius = 3958.8
+
+// ParseCoordinates reads coordinates written as "latitude,longitude"
+func ParseCoordinates(s string) (Coordinates, error) {
+	lat, lon, ok := strings.Cut(s, ",")
+	if !ok {
+		return Coordinates{}, fmt.Errorf("invalid coordinates %q, please use latitude,longitude", s)
+	}
+	var c Coordinates
+	var err1, err2 error
+	c.Latitude, err1 = strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	c.Longitude, err2 = strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	if err1 != nil || err2 != nil {
+		return Coordinates{}, fmt.Errorf("invalid coordinates %q, please use latitude,longitude", s)
+	}
+	if err := c.Validate(); err != nil {
+		return Coordinates{}, err
+	}
+	return c, nil
+}
+
+// Validate checks that the coordinates are on the earth
+func (c Coordinates) Validate() error {
+	switch {
+	case math.IsNaN(c.Latitude) || c.Latitude < -90 || c.Latitude > 90:
+		return fmt.Errorf("latitude %v is not between -90 and 90", c.Latitude)
+	case math.IsNaN(c.Longitude) || c.Longitude < -180 || c.Longitude > 180:
+		return fmt.Errorf("longitude %v is not between -180 and 180", c.Longitude)
+	}
+	return nil
+}
+
+func (c Coordinates) String() string {
+	return strconv.FormatFloat(c.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(c.Longitude, 'f', -1, 64)
+}
+
+// greatCircle returns the great circle distance between two points in miles
+func greatCircle(a, b Coordinates) float64 {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := rad(b.Latitude - a.Latitude)
+	dLon := rad(b.Longitude - a.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(a.Latitude))*math.Cos(rad(b.Latitude))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// NearbyTrail is a trail and how far its trailhead is from a point
+type NearbyTrail struct {
+	Trail
+	Distance Distance `json:"distance"`
+}
+
+// Nearby returns the trails whose trailhead is within the given distance of
+// from, nearest first. Trails without a trailhead are left out; a within of
+// 0 or less puts no limit on the distance.
+func Nearby(trails []Trail, from Coordinates, within Distance) []NearbyTrail {
+	var nearby []NearbyTrail
+	for _, trail := range trails {
+		if trail.Location.Trailhead == nil {
+			continue
+		}
+		distance := greatCircle(from, *trail.Location.Trailhead)
+		if within.Value <= 0 || distance <= within.Miles() {
+			nearby = append(nearby, NearbyTrail{Trail: trail, Distance: Miles(distance)})
+		}
+	}
+	slices.SortStableFunc(nearby, func(a, b NearbyTrail) int {
+		return cmp.Compare(a.Distance.Value, b.Distance.Value)
+	})
+	return nearby
+}
+
+// states maps the postal codes of the US states and DC to their names
+var states = map[string]string{
+	"AL": "alabama", "AK": "alaska", "AZ": "arizona", "AR": "arkansas", "CA": "california",
+	"CO": "colorado", "CT": "connecticut", "DE": "delaware", "DC": "district of columbia", "FL": "florida",
+	"GA": "georgia", "HI": "hawaii", "ID": "idaho", "IL": "illinois", "IN": "indiana",
+	"IA": "iowa", "KS": "kansas", "KY": "kentucky", "LA": "louisiana", "ME": "maine",
+	"MD": "maryland", "MA": "massachusetts", "MI": "michigan", "MN": "minnesota", "MS": "mississippi",
+	"MO": "missouri", "MT": "montana", "NE": "nebraska", "NV": "nevada", "NH": "new hampshire",
+	"NJ": "new jersey", "NM": "new mexico", "NY": "new york", "NC": "north carolina", "ND": "north dakota",
+	"OH": "ohio", "OK": "oklahoma", "OR": "oregon", "PA": "pennsylvania", "RI": "rhode island",
+	"SC": "south carolina", "SD": "south dakota", "TN": "tennessee", "TX": "texas", "UT": "utah",
+	"VT": "vermont", "VA": "virginia", "WA": "washington", "WV": "west virginia", "WI": "wisconsin",
+	"WY": "wyoming",
+}
+
+// stateCodes maps the names in states to their postal codes
+var stateCodes = func() map[string]string {
+	codes := make(map[string]string, len(states))
+	for code, name := range states {
+		codes[name] = code
+	}
+	return codes
+}()
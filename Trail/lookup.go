@@ -0,0 +1,172 @@
+package Trail
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"project/Storage"
+	"project/utils"
+	"slices"
+	"strings"
+)
+
+// normalizeName lowers the case of a name and collapses its spacing, so
+// that names differing only in those compare equal
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// SameName reports whether two trail names are the same but for case and
+// spacing
+func SameName(a, b string) bool {
+	return normalizeName(a) == normalizeName(b)
+}
+
+// NameDistance counts the single letter insertions, deletions and
+// substitutions turning one trail name into the other, ignoring case and
+// spacing
+func NameDistance(a, b string) int {
+	s, t := []rune(normalizeName(a)), []rune(normalizeName(b))
+	previous := make([]int, len(t)+1)
+	current := make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := range s {
+		current[0] = i + 1
+		for j := range t {
+			cost := 1
+			if s[i] == t[j] {
+				cost = 0
+			}
+			current[j+1] = min(previous[j+1]+1, current[j]+1, previous[j]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(t)]
+}
+
+// Similar reports whether name is close enough to the name a user typed to
+// be what they meant: a letter off for every four letters typed, at least
+// one and at most three
+func Similar(name, typed string) bool {
+	tolerance := min(max(len([]rune(normalizeName(typed)))/4, 1), 3)
+	return NameDistance(name, typed) <= tolerance
+}
+
+// Find returns the trail with the given name, ignoring case and spacing,
+// and location, the location being compared as ParseLocation reads it
+func Find(repo Repository, name, location string) (Trail, error) {
+	trails, err := repo.List()
+	if err != nil {
+		return Trail{}, err
+	}
+	for _, trail := range trails {
+		if SameName(trail.Name, name) && trail.Location.Same(ParseLocation(location)) {
+			return trail, nil
+		}
+	}
+	return Trail{}, &Storage.RecordError{Entity: codec.Entity, Key: name + " at " + location, Err: Storage.ErrNotFound}
+}
+
+// Match returns the trails a name typed by a user refers to. exact is true
+// when trails have the name, ignoring case and spacing; otherwise the
+// trails with a name Similar to it are returned, the closest first.
+func Match(trails []Trail, name string) (matches []Trail, exact bool) {
+	for _, trail := range trails {
+		if SameName(trail.Name, name) {
+			matches = append(matches, trail)
+		}
+	}
+	if len(matches) > 0 || strings.TrimSpace(name) == "" {
+		return matches, len(matches) > 0
+	}
+	for _, trail := range trails {
+		if Similar(trail.Name, name) {
+			matches = append(matches, trail)
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b Trail) int {
+		return cmp.Or(cmp.Compare(NameDistance(a.Name, name), NameDistance(b.Name, name)), strings.Compare(a.Name, b.Name))
+	})
+	return matches, false
+}
+
+// Lookup is Match over the trails in repo
+func Lookup(repo Repository, name string) (matches []Trail, exact bool, err error) {
+	trails, err := repo.List()
+	if err != nil {
+		return nil, false, err
+	}
+	matches, exact = Match(trails, name)
+	return matches, exact, nil
+}
+
+// ErrAmbiguous is returned by Unique when several trails share a name
+var ErrAmbiguous = errors.New("several trails have this name")
+
+// Unique returns the only trail called name, ignoring case and spacing.
+// When no trail has the name, the error suggests the similar ones.
+func Unique(repo Repository, name string) (Trail, error) {
+	matches, exact, err := Lookup(repo, name)
+	if err != nil {
+		return Trail{}, err
+	}
+	switch {
+	case exact && len(matches) == 1:
+		return matches[0], nil
+	case exact:
+		return Trail{}, &Storage.RecordError{Entity: codec.Entity, Key: name, Err: ErrAmbiguous}
+	}
+	notFound := &Storage.RecordError{Entity: codec.Entity, Key: name, Err: Storage.ErrNotFound}
+	if len(matches) == 0 {
+		return Trail{}, notFound
+	}
+	names := make([]string, len(matches))
+	for i, trail := range matches {
+		names[i] = fmt.Sprintf("%q", trail.Name)
+	}
+	return Trail{}, fmt.Errorf("%w, did you mean %s?", notFound, strings.Join(names, " or "))
+}
+
+// ChooseByName returns the trail called name, ignoring case and spacing,
+// asking the user to pick one when several trails share the name. When no
+// trail has the name, the user is offered the trails with a similar one.
+// ok is false when no trail matches or the user cancels, and the user is
+// told which.
+func ChooseByName(repo Repository, name string) (trail Trail, ok bool) {
+	matches, exact, err := Lookup(repo, name)
+	if err != nil {
+		fmt.Println("Error reading trails:", err)
+		return Trail{}, false
+	}
+	switch {
+	case len(matches) == 0:
+		fmt.Printf("Trail '%s' not found.\n", name)
+		return Trail{}, false
+	case !exact && len(matches) == 1:
+		fmt.Printf("No trail is named '%s'. Did you mean '%s' at %s? (y/n): ", name, matches[0].Name, matches[0].Location)
+		var confirmation string
+		fmt.Scanln(&confirmation)
+		if confirmation != "y" {
+			fmt.Println("Operation cancelled.")
+			return Trail{}, false
+		}
+		return matches[0], true
+	}
+
+	options := make([]string, len(matches))
+	for i, trail := range matches {
+		options[i] = fmt.Sprintf("%s at %s", trail.Name, trail.Location)
+	}
+	prompt := "Several trails are named '" + name + "', select one"
+	if !exact {
+		prompt = "No trail is named '" + name + "', select the one you meant"
+	}
+	i := utils.Choose(prompt, options)
+	if i < 0 {
+		fmt.Println("Operation cancelled.")
+		return Trail{}, false
+	}
+	return matches[i], true
+}
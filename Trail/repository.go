@@ -0,0 +1,398 @@
+package Trail
+
+import (
+	"fmt"
+	"project/Storage"
+	"project/utils"
+	"strconv"
+)
+
+// Repository stores trails, keyed by ID
+type Repository = Storage.Repository[Trail]
+
+// MemoryRepository, CSVRepository and SQLRepository are the available
+// Repository implementations
+type (
+	MemoryRepository = Storage.Memory[Trail]
+	CSVRepository    = Storage.CSV[Trail]
+	SQLRepository    = Storage.SQL[Trail]
+)
+
+const idPrefix = "trl"
+
+var schema = Storage.Schema{
+	Name:    "trails",
+	Version: 8,
+	Columns: []string{"id", "name", "city", "region", "land_unit", "latitude", "longitude", "difficulty", "length", "length_unit", "elevation_gain", "elevation_gain_unit", "status", "version"},
+	Types:   map[string]string{"length": "REAL", "elevation_gain": "REAL", "version": "INTEGER"},
+	Indexes: []string{"name"},
+	Legacy: func(width int) (int, []string, bool) {
+		switch width {
+		case 5:
+			return 1, []string{"name", "location", "difficulty", "length", "status"}, true
+		case 6:
+			return 2, []string{"id", "name", "location", "difficulty", "length", "status"}, true
+		}
+		return 0, nil, false
+	},
+	Migrations: []Storage.Migration{
+		{To: 2, Description: "give every trail a stable id", Apply: func(t *Storage.Table) {
+			t.AddColumn("id", func([]string) string { return utils.NewID(idPrefix) })
+		}},
+		{To: 3, Description: "add a schema version marker and header row"},
+		{To: 4, Description: "give every trail a version number", Apply: func(t *Storage.Table) {
+			t.AddColumn("version", func([]string) string { return "1" })
+		}},
+		{To: 5, Description: "map free text statuses to the trail statuses, unrecognised ones to closed", Apply: func(t *Storage.Table) {
+			i := t.Index("status")
+			for _, row := range t.Rows {
+				row[i] = string(legacyStatus(row[i]))
+			}
+		}},
+		{To: 6, Description: "give every trail an elevation gain and map free text difficulties to ratings, unrecognised ones to the rating suggested by the length", Apply: func(t *Storage.Table) {
+			t.AddColumn("elevation_gain", func([]string) string { return "0" })
+			difficulty, length := t.Index("difficulty"), t.Index("length")
+			for _, row := range t.Rows {
+				miles, _ := strconv.ParseFloat(row[length], 64)
+				row[difficulty] = string(legacyDifficulty(row[difficulty], miles))
+			}
+		}},
+		{To: 7, Description: "split locations into city, region and land unit, and add trailhead coordinates", Apply: func(t *Storage.Table) {
+			i := t.Index("location")
+			t.AddColumn("city", func(row []string) string { return ParseLocation(row[i]).City })
+			t.AddColumn("region", func(row []string) string { return ParseLocation(row[i]).Region })
+			t.AddColumn("land_unit", func(row []string) string { return ParseLocation(row[i]).LandUnit })
+			t.AddColumn("latitude", func([]string) string { return "" })
+			t.AddColumn("longitude", func([]string) string { return "" })
+			t.RemoveColumn("location")
+		}},
+		{To: 8, Description: "record the units of lengths and elevation gains, miles and feet until now", Apply: func(t *Storage.Table) {
+			t.AddColumn("length_unit", func([]string) string { return string(Mile) })
+			t.AddColumn("elevation_gain_unit", func([]string) string { return string(Foot) })
+		}},
+	},
+}
+
+var codec = Storage.Codec[Trail]{
+	Entity:     "trail",
+	Prefix:     idPrefix,
+	Key:        func(t Trail) string { return t.ID },
+	SetKey:     func(t Trail, id string) Trail { t.ID = id; return t },
+	Version:    func(t Trail) int { return t.Version },
+	SetVersion: func(t Trail, version int) Trail { t.Version = version; return t },
+	Same: func(a, b Trail) bool {
+		return SameName(a.Name, b.Name) && a.Location.Same(b.Location)
+	},
+	// The status only changes along with the status history
+	Carry: func(t, current Trail) Trail {
+		t.Status = current.Status
+		return t
+	},
+	Schema: schema,
+	Encode: func(t Trail) Storage.Row {
+		// Trails without a known trailhead leave the coordinates blank
+		var latitude, longitude string
+		if t.Location.Trailhead != nil {
+			latitude = strconv.FormatFloat(t.Location.Trailhead.Latitude, 'f', -1, 64)
+			longitude = strconv.FormatFloat(t.Location.Trailhead.Longitude, 'f', -1, 64)
+		}
+		return Storage.Row{
+			"id":         t.ID,
+			"name":       t.Name,
+			"city":       t.Location.City,
+			"region":     t.Location.Region,
+			"land_unit":  t.Location.LandUnit,
+			"latitude":   latitude,
+			"longitude":  longitude,
+			"difficulty": string(t.Difficulty),
+			// Lengths and elevations are kept as entered, in their own
+			// unit and to full precision
+			"length":              strconv.FormatFloat(t.Length.Value, 'f', -1, 64),
+			"length_unit":         string(t.Length.unit()),
+			"elevation_gain":      strconv.FormatFloat(t.ElevationGain.Value, 'f', -1, 64),
+			"elevation_gain_unit": string(t.ElevationGain.unit()),
+			"status":              string(t.Status),
+			"version":             strconv.Itoa(t.Version),
+		}
+	},
+	Decode: func(row Storage.Row) (Trail, error) {
+		length, err := ParseDistance(row["length"]+" "+row["length_unit"], Mile)
+		if err != nil {
+			return Trail{}, fmt.Errorf("invalid trail length: %w", err)
+		}
+		location := Location{City: row["city"], Region: row["region"], LandUnit: row["land_unit"]}
+		if row["latitude"] != "" || row["longitude"] != "" {
+			trailhead, err := ParseCoordinates(row["latitude"] + "," + row["longitude"])
+			if err != nil {
+				return Trail{}, fmt.Errorf("invalid trailhead: %w", err)
+			}
+			location.Trailhead = &trailhead
+		}
+		elevationGain, err := ParseElevation(row["elevation_gain"]+" "+row["elevation_gain_unit"], Foot)
+		if err != nil {
+			return Trail{}, fmt.Errorf("invalid elevation gain: %w", err)
+		}
+		difficulty, err := ParseDifficulty(row["difficulty"])
+		if err != nil {
+			return Trail{}, err
+		}
+		status, err := ParseStatus(row["status"])
+		if err != nil {
+			return Trail{}, err
+		}
+		version, err := strconv.Atoi(row["version"])
+		if err != nil {
+			return Trail{}, fmt.Errorf("invalid version: %w", err)
+		}
+		return Trail{
+			ID:            row["id"],
+			Name:          row["name"],
+			Location:      location,
+			Difficulty:    difficulty,
+			Length:        length,
+			ElevationGain: elevationGain,
+			Status:        status,
+			Version:       version,
+		}, nil
+	},
+}
+
+// NewMemoryRepository creates an in-memory repository holding trails
+func NewMemoryRepository(trails ...Trail) *MemoryRepository {
+	return Storage.NewMemory(codec, trails...)
+}
+
+// NewCSVRepository creates a repository backed by the CSV file at filePath
+func NewCSVRepository(filePath string) *CSVRepository {
+	return Storage.NewCSV(filePath, codec)
+}
+
+// NewSQLRepository creates a repository backed by a table in db
+func NewSQLRepository(db *Storage.Database) *SQLRepository {
+	return Storage.NewSQL(db, codec)
+}
+
+// HistoryRepository stores the status changes of every trail, keyed by ID
+type HistoryRepository = Storage.Repository[StatusChange]
+
+// MemoryHistoryRepository, CSVHistoryRepository and SQLHistoryRepository
+// are the available HistoryRepository implementations
+type (
+	MemoryHistoryRepository = Storage.Memory[StatusChange]
+	CSVHistoryRepository    = Storage.CSV[StatusChange]
+	SQLHistoryRepository    = Storage.SQL[StatusChange]
+)
+
+const historyIDPrefix = "sts"
+
+var historySchema = Storage.Schema{
+	Name:    "status_history",
+	Version: 1,
+	Columns: []string{"id", "trail_id", "from", "to", "reason", "effective_date"},
+	Indexes: []string{"trail_id"},
+	// The history was added with the schema version marker, so there are
+	// no files without one
+	Legacy: func(int) (int, []string, bool) { return 0, nil, false },
+}
+
+var historyCodec = Storage.Codec[StatusChange]{
+	Entity: "status change",
+	Prefix: historyIDPrefix,
+	Key:    func(c StatusChange) string { return c.ID },
+	SetKey: func(c StatusChange, id string) StatusChange { c.ID = id; return c },
+	Schema: historySchema,
+	Encode: func(c StatusChange) Storage.Row {
+		return Storage.Row{
+			"id":             c.ID,
+			"trail_id":       c.TrailID,
+			"from":           string(c.From),
+			"to":             string(c.To),
+			"reason":         c.Reason,
+			"effective_date": c.EffectiveDate,
+		}
+	},
+	Decode: func(row Storage.Row) (StatusChange, error) {
+		return StatusChange{
+			ID:            row["id"],
+			TrailID:       row["trail_id"],
+			From:          Status(row["from"]),
+			To:            Status(row["to"]),
+			Reason:        row["reason"],
+			EffectiveDate: row["effective_date"],
+		}, nil
+	},
+}
+
+// NewMemoryHistoryRepository creates an in-memory repository holding status changes
+func NewMemoryHistoryRepository(changes ...StatusChange) *MemoryHistoryRepository {
+	return Storage.NewMemory(historyCodec, changes...)
+}
+
+// NewCSVHistoryRepository creates a status history backed by the CSV file at filePath
+func NewCSVHistoryRepository(filePath string) *CSVHistoryRepository {
+	return Storage.NewCSV(filePath, historyCodec)
+}
+
+// NewSQLHistoryRepository creates a status history backed by a table in db
+func NewSQLHistoryRepository(db *Storage.Database) *SQLHistoryRepository {
+	return Storage.NewSQL(db, historyCodec)
+}
+
+// ScheduleRepository stores the scheduled status changes of every trail,
+// keyed by ID
+type ScheduleRepository = Storage.Repository[ScheduledChange]
+
+// MemoryScheduleRepository, CSVScheduleRepository and SQLScheduleRepository
+// are the available ScheduleRepository implementations
+type (
+	MemoryScheduleRepository = Storage.Memory[ScheduledChange]
+	CSVScheduleRepository    = Storage.CSV[ScheduledChange]
+	SQLScheduleRepository    = Storage.SQL[ScheduledChange]
+)
+
+const scheduleIDPrefix = "sch"
+
+var scheduleSchema = Storage.Schema{
+	Name:    "status_schedule",
+	Version: 1,
+	Columns: []string{"id", "trail_id", "status", "reason", "start", "end", "version"},
+	Types:   map[string]string{"version": "INTEGER"},
+	Indexes: []string{"trail_id"},
+	Legacy:  func(int) (int, []string, bool) { return 0, nil, false },
+}
+
+var scheduleCodec = Storage.Codec[ScheduledChange]{
+	Entity:     "scheduled change",
+	Prefix:     scheduleIDPrefix,
+	Key:        func(c ScheduledChange) string { return c.ID },
+	SetKey:     func(c ScheduledChange, id string) ScheduledChange { c.ID = id; return c },
+	Version:    func(c ScheduledChange) int { return c.Version },
+	SetVersion: func(c ScheduledChange, version int) ScheduledChange { c.Version = version; return c },
+	Schema:     scheduleSchema,
+	Encode: func(c ScheduledChange) Storage.Row {
+		return Storage.Row{
+			"id":       c.ID,
+			"trail_id": c.TrailID,
+			"status":   string(c.Status),
+			"reason":   c.Reason,
+			"start":    c.Start,
+			"end":      c.End,
+			"version":  strconv.Itoa(c.Version),
+		}
+	},
+	Decode: func(row Storage.Row) (ScheduledChange, error) {
+		version, err := strconv.Atoi(row["version"])
+		if err != nil {
+			return ScheduledChange{}, fmt.Errorf("invalid version: %w", err)
+		}
+		return ScheduledChange{
+			ID:      row["id"],
+			TrailID: row["trail_id"],
+			Status:  Status(row["status"]),
+			Reason:  row["reason"],
+			Start:   row["start"],
+			End:     row["end"],
+			Version: version,
+		}, nil
+	},
+}
+
+// NewMemoryScheduleRepository creates an in-memory repository holding scheduled changes
+func NewMemoryScheduleRepository(changes ...ScheduledChange) *MemoryScheduleRepository {
+	return Storage.NewMemory(scheduleCodec, changes...)
+}
+
+// NewCSVScheduleRepository creates a schedule backed by the CSV file at filePath
+func NewCSVScheduleRepository(filePath string) *CSVScheduleRepository {
+	return Storage.NewCSV(filePath, scheduleCodec)
+}
+
+// NewSQLScheduleRepository creates a schedule backed by a table in db
+func NewSQLScheduleRepository(db *Storage.Database) *SQLScheduleRepository {
+	return Storage.NewSQL(db, scheduleCodec)
+}
+
+// TrackRepository stores the tracks of the trails, keyed by ID
+type TrackRepository = Storage.Repository[Track]
+
+// MemoryTrackRepository, CSVTrackRepository and SQLTrackRepository are the
+// available TrackRepository implementations
+type (
+	MemoryTrackRepository = Storage.Memory[Track]
+	CSVTrackRepository    = Storage.CSV[Track]
+	SQLTrackRepository    = Storage.SQL[Track]
+)
+
+const trackIDPrefix = "trk"
+
+var trackSchema = Storage.Schema{
+	Name:    "tracks",
+	Version: 1,
+	// points holds the whole line, see encodePoints
+	Columns: []string{"id", "trail_id", "name", "points"},
+	Indexes: []string{"trail_id"},
+	// Tracks were added with the schema version marker, so there are no
+	// files without one
+	Legacy: func(int) (int, []string, bool) { return 0, nil, false },
+}
+
+var trackCodec = Storage.Codec[Track]{
+	Entity: "track",
+	Prefix: trackIDPrefix,
+	Key:    func(t Track) string { return t.ID },
+	SetKey: func(t Track, id string) Track { t.ID = id; return t },
+	// A trail has one track
+	Same:       func(a, b Track) bool { return a.TrailID == b.TrailID },
+	SameColumn: "trail_id",
+	Schema:     trackSchema,
+	Encode: func(t Track) Storage.Row {
+		return Storage.Row{
+			"id":       t.ID,
+			"trail_id": t.TrailID,
+			"name":     t.Name,
+			"points":   encodePoints(t.Points),
+		}
+	},
+	Decode: func(row Storage.Row) (Track, error) {
+		points, err := decodePoints(row["points"])
+		if err != nil {
+			return Track{}, err
+		}
+		return Track{
+			ID:      row["id"],
+			TrailID: row["trail_id"],
+			Name:    row["name"],
+			Points:  points,
+		}, nil
+	},
+}
+
+// NewMemoryTrackRepository creates an in-memory repository holding tracks
+func NewMemoryTrackRepository(tracks ...Track) *MemoryTrackRepository {
+	return Storage.NewMemory(trackCodec, tracks...)
+}
+
+// NewCSVTrackRepository creates a track repository backed by the CSV file at filePath
+func NewCSVTrackRepository(filePath string) *CSVTrackRepository {
+	return Storage.NewCSV(filePath, trackCodec)
+}
+
+// NewSQLTrackRepository creates a track repository backed by a table in db
+func NewSQLTrackRepository(db *Storage.Database) *SQLTrackRepository {
+	return Storage.NewSQL(db, trackCodec)
+}
+
+// TrackOf returns the track of a trail, or a Storage.ErrNotFound error if
+// it has none
+func TrackOf(repo TrackRepository, trailID string) (Track, error) {
+	tracks, err := repo.List()
+	if err != nil {
+		return Track{}, err
+	}
+	for _, track := range tracks {
+		if track.TrailID == trailID {
+			return track, nil
+		}
+	}
+	return Track{}, &Storage.RecordError{Entity: "track of trail", Key: trailID, Err: Storage.ErrNotFound}
+}
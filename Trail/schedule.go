@@ -0,0 +1,108 @@
+package Trail
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ScheduledChange is a status planned for a trail over a period, such as a
+// closure for the hunting season. While the period lasts it overrides the
+// status the trail has been given.
+type ScheduledChange struct {
+	ID      string `json:"id"`
+	TrailID string `json:"trail_id"`
+	Status  Status `json:"status"`
+	Reason  string `json:"reason"`
+	// Start and End are the first and last day of the period, YYYY-MM-DD.
+	// Without an end the status lasts until the change is cancelled.
+	Start   string `json:"start"`
+	End     string `json:"end,omitempty"`
+	Version int    `json:"version"`
+}
+
+// Validate checks the fields of a scheduled change
+func (c ScheduledChange) Validate() error {
+	switch {
+	case c.TrailID == "":
+		return errors.New("a scheduled change needs the trail ID")
+	case !c.Status.Valid():
+		return fmt.Errorf("unknown status %q, use one of: %s", c.Status, JoinStatuses(Statuses))
+	case strings.TrimSpace(c.Reason) == "":
+		return errors.New("a scheduled change needs a reason")
+	case !ValidDate(c.Start):
+		return fmt.Errorf("invalid start date %q, please use YYYY-MM-DD", c.Start)
+	case c.End != "" && !ValidDate(c.End):
+		return fmt.Errorf("invalid end date %q, please use YYYY-MM-DD", c.End)
+	case c.End != "" && c.End < c.Start:
+		return fmt.Errorf("end date %s is before start date %s", c.End, c.Start)
+	}
+	return nil
+}
+
+// Active reports whether the change applies on date
+func (c ScheduledChange) Active(date string) bool {
+	return c.Start <= date && (c.End == "" || date <= c.End)
+}
+
+func (c ScheduledChange) String() string {
+	if c.End == "" {
+		return fmt.Sprintf("%s from %s: %s", c.Status, c.Start, c.Reason)
+	}
+	return fmt.Sprintf("%s from %s to %s: %s", c.Status, c.Start, c.End, c.Reason)
+}
+
+// Schedule returns the scheduled changes of a trail, by start date
+func Schedule(repo ScheduleRepository, trailID string) ([]ScheduledChange, error) {
+	changes, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	var schedule []ScheduledChange
+	for _, change := range changes {
+		if change.TrailID == trailID {
+			schedule = append(schedule, change)
+		}
+	}
+	slices.SortStableFunc(schedule, func(a, b ScheduledChange) int {
+		return strings.Compare(a.Start, b.Start)
+	})
+	return schedule, nil
+}
+
+// StatusOn returns the status of a trail on date, given its status history
+// and its schedule sorted by start date. A scheduled change that is active
+// on date overrides the status from the history, the one that started last
+// if several are; it is returned along with the status.
+func StatusOn(trail Trail, history []StatusChange, schedule []ScheduledChange, date string) (Status, *ScheduledChange) {
+	for i := len(schedule) - 1; i >= 0; i-- {
+		if schedule[i].Active(date) {
+			return schedule[i].Status, &schedule[i]
+		}
+	}
+
+	status := trail.Status
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].EffectiveDate <= date {
+			return history[i].To, nil
+		}
+		// Before its first recorded change the trail had the status the
+		// change started from
+		if history[i].From != "" {
+			status = history[i].From
+		}
+	}
+	return status, nil
+}
+
+// Upcoming returns the scheduled changes that start after date
+func Upcoming(schedule []ScheduledChange, date string) []ScheduledChange {
+	var upcoming []ScheduledChange
+	for _, change := range schedule {
+		if change.Start > date {
+			upcoming = append(upcoming, change)
+		}
+	}
+	return upcoming
+}
@@ -0,0 +1,189 @@
+package Trail
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Status is the state a trail is in
+type Status string
+
+// Trail statuses
+const (
+	Open             Status = "open"
+	PartiallyOpen    Status = "partially open"
+	Caution          Status = "caution"
+	Closed           Status = "closed"
+	SeasonalClosure  Status = "seasonal closure"
+	EmergencyClosure Status = "emergency closure"
+)
+
+// Statuses lists every trail status
+var Statuses = []Status{Open, PartiallyOpen, Caution, Closed, SeasonalClosure, EmergencyClosure}
+
+// transitions lists the statuses each status can change to
+var transitions = map[Status][]Status{
+	Open:          {PartiallyOpen, Caution, Closed, SeasonalClosure, EmergencyClosure},
+	PartiallyOpen: {Open, Caution, Closed, SeasonalClosure, EmergencyClosure},
+	Caution:       {Open, PartiallyOpen, Closed, SeasonalClosure, EmergencyClosure},
+	Closed:        {Open, PartiallyOpen, Caution, SeasonalClosure},
+	// A trail that is closed already cannot be closed by an emergency, and
+	// an emergency closure is lifted once the trail has been assessed
+	// rather than turned into a seasonal one
+	SeasonalClosure:  {Open, PartiallyOpen, Caution, Closed},
+	EmergencyClosure: {Open, PartiallyOpen, Caution, Closed},
+}
+
+// Errors returned when changing the status of a trail
+var (
+	// ErrTransition is returned for a status change the trail's current
+	// status does not allow
+	ErrTransition = errors.New("status change not allowed")
+	// ErrStatusChange is returned when a trail update changes the status,
+	// which takes a status change giving a reason and effective date
+	ErrStatusChange = errors.New("the status is changed with a status change giving a reason and effective date")
+	// ErrHistory is returned when a recorded status change is edited
+	ErrHistory = errors.New("status history cannot be changed")
+)
+
+// ParseStatus returns the status named s, ignoring case and accepting
+// dashes or underscores for spaces
+func ParseStatus(s string) (Status, error) {
+	status := Status(strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_'
+	}), " "))
+	if !status.Valid() {
+		return "", fmt.Errorf("unknown status %q, use one of: %s", s, JoinStatuses(Statuses))
+	}
+	return status, nil
+}
+
+// Valid reports whether s is one of the trail statuses
+func (s Status) Valid() bool {
+	return slices.Contains(Statuses, s)
+}
+
+// CanChangeTo reports whether a trail with status s can change to status to
+func (s Status) CanChangeTo(to Status) bool {
+	return slices.Contains(transitions[s], to)
+}
+
+// Passable reports whether a trail with status s can be walked, if only
+// with care or in part
+func (s Status) Passable() bool {
+	return s == Open || s == PartiallyOpen || s == Caution
+}
+
+// Next lists the statuses s can change to
+func (s Status) Next() []Status {
+	return transitions[s]
+}
+
+func (s Status) String() string {
+	return string(s)
+}
+
+// Set parses a status, so that a *Status can be used as a flag
+func (s *Status) Set(value string) error {
+	status, err := ParseStatus(value)
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
+
+// JoinStatuses lists statuses separated by commas
+func JoinStatuses(statuses []Status) string {
+	names := make([]string, len(statuses))
+	for i, status := range statuses {
+		names[i] = string(status)
+	}
+	return strings.Join(names, ", ")
+}
+
+// legacyStatus maps the free text statuses of trails written before
+// statuses were typed to the nearest status. Anything unrecognised is
+// treated as closed, so that no trail is shown as open by mistake.
+func legacyStatus(text string) Status {
+	if status, err := ParseStatus(text); err == nil {
+		return status
+	}
+	text = strings.ToLower(text)
+	switch {
+	case strings.Contains(text, "emergency"):
+		return EmergencyClosure
+	case strings.Contains(text, "season"), strings.Contains(text, "winter"):
+		return SeasonalClosure
+	case strings.Contains(text, "partial"):
+		return PartiallyOpen
+	case strings.Contains(text, "caution"), strings.Contains(text, "warning"):
+		return Caution
+	}
+	return Closed
+}
+
+// StatusChange records a change of a trail's status
+type StatusChange struct {
+	ID      string `json:"id"`
+	TrailID string `json:"trail_id"`
+	From    Status `json:"from"`
+	To      Status `json:"to"`
+	Reason  string `json:"reason"`
+	// EffectiveDate is the day the change took effect, YYYY-MM-DD
+	EffectiveDate string `json:"effective_date"`
+}
+
+// Validate checks the fields of a status change. Whether the trail can
+// make the change depends on its current status, see Status.CanChangeTo.
+func (c StatusChange) Validate() error {
+	switch {
+	case c.TrailID == "":
+		return errors.New("a status change needs the trail ID")
+	case c.From != "" && !c.From.Valid():
+		return fmt.Errorf("unknown status %q", c.From)
+	case !c.To.Valid():
+		return fmt.Errorf("unknown status %q, use one of: %s", c.To, JoinStatuses(Statuses))
+	case strings.TrimSpace(c.Reason) == "":
+		return errors.New("a status change needs a reason")
+	case !ValidDate(c.EffectiveDate):
+		return fmt.Errorf("invalid effective date %q, please use YYYY-MM-DD", c.EffectiveDate)
+	}
+	return nil
+}
+
+func (c StatusChange) String() string {
+	return fmt.Sprintf("%s since %s: %s", c.To, c.EffectiveDate, c.Reason)
+}
+
+// ValidDate reports whether date is a real date written as YYYY-MM-DD
+func ValidDate(date string) bool {
+	_, err := time.Parse("2006-01-02", date)
+	return err == nil
+}
+
+// Today returns the current date as YYYY-MM-DD
+func Today() string {
+	return time.Now().Format("2006-01-02")
+}
+
+// History returns the status changes of a trail, oldest first
+func History(repo HistoryRepository, trailID string) ([]StatusChange, error) {
+	changes, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	var history []StatusChange
+	for _, change := range changes {
+		if change.TrailID == trailID {
+			history = append(history, change)
+		}
+	}
+	slices.SortStableFunc(history, func(a, b StatusChange) int {
+		return strings.Compare(a.EffectiveDate, b.EffectiveDate)
+	})
+	return history, nil
+}
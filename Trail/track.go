@@ -0,0 +1,143 @@
+package Trail
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Track is the recorded geometry of a trail, as a line of points from the
+// trailhead. A trail has at most one track.
+type Track struct {
+	ID      string       `json:"id"`
+	TrailID string       `json:"trail_id"`
+	Name    string       `json:"name,omitempty"`
+	Points  []TrackPoint `json:"points"`
+}
+
+// TrackPoint is a point of a track. Elevation is in meters, as GPS units
+// record it, and nil where the point has none.
+type TrackPoint struct {
+	Coordinates
+	Elevation *float64 `json:"elevation,omitempty"`
+}
+
+// TrackStats summarises a track. The length is in miles and elevations in
+// meters, as the track records them.
+type TrackStats struct {
+	Length        Distance  `json:"length"`
+	ElevationGain Elevation `json:"elevation_gain"`
+	ElevationLoss Elevation `json:"elevation_loss"`
+	// MinElevation and MaxElevation are 0 for tracks without elevations
+	MinElevation Elevation   `json:"min_elevation"`
+	MaxElevation Elevation   `json:"max_elevation"`
+	Trailhead    Coordinates `json:"trailhead"`
+}
+
+// climbThreshold is the smallest change in elevation, in meters, counted as
+// a climb or descent, so that the jitter of GPS elevations does not add up
+const climbThreshold = 3.0
+
+// Validate checks the fields of a track
+func (t Track) Validate() error {
+	switch {
+	case t.TrailID == "":
+		return errors.New("a track needs the trail ID")
+	case len(t.Points) < 2:
+		return errors.New("a track needs at least two points")
+	}
+	for i, point := range t.Points {
+		if err := point.Validate(); err != nil {
+			return fmt.Errorf("point %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Stats measures the track. Climbs and descents smaller than a few meters
+// are ignored as GPS noise.
+func (t Track) Stats() TrackStats {
+	stats := TrackStats{Length: Miles(0), ElevationGain: Meters(0), ElevationLoss: Meters(0), MinElevation: Meters(0), MaxElevation: Meters(0)}
+	if len(t.Points) == 0 {
+		return stats
+	}
+	stats.Trailhead = t.Points[0].Coordinates
+	for i := 1; i < len(t.Points); i++ {
+		stats.Length.Value += greatCircle(t.Points[i-1].Coordinates, t.Points[i].Coordinates)
+	}
+
+	// reference is the elevation climbs and descents are measured from
+	var reference float64
+	measured := false
+	for _, point := range t.Points {
+		if point.Elevation == nil {
+			continue
+		}
+		elevation := *point.Elevation
+		if !measured {
+			reference, measured = elevation, true
+			stats.MinElevation.Value, stats.MaxElevation.Value = elevation, elevation
+			continue
+		}
+		stats.MinElevation.Value = min(stats.MinElevation.Value, elevation)
+		stats.MaxElevation.Value = max(stats.MaxElevation.Value, elevation)
+		switch change := elevation - reference; {
+		case change >= climbThreshold:
+			stats.ElevationGain.Value += change
+			reference = elevation
+		case change <= -climbThreshold:
+			stats.ElevationLoss.Value -= change
+			reference = elevation
+		}
+	}
+	return stats
+}
+
+// Apply fills in the length, elevation gain and trailhead of trail from the
+// track's stats. The elevation gain is rounded to the meter.
+func (s TrackStats) Apply(trail *Trail) {
+	trail.Length = s.Length
+	trail.ElevationGain = Meters(math.Round(s.ElevationGain.Value))
+	trailhead := s.Trailhead
+	trail.Location.Trailhead = &trailhead
+}
+
+// encodePoints writes points as "lat,lon,ele" separated by spaces, leaving
+// out the elevation where there is none
+func encodePoints(points []TrackPoint) string {
+	texts := make([]string, len(points))
+	for i, point := range points {
+		texts[i] = point.Coordinates.String()
+		if point.Elevation != nil {
+			texts[i] += "," + strconv.FormatFloat(*point.Elevation, 'f', -1, 64)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+// decodePoints reads points written by encodePoints
+func decodePoints(text string) ([]TrackPoint, error) {
+	fields := strings.Fields(text)
+	points := make([]TrackPoint, len(fields))
+	for i, field := range fields {
+		parts := strings.Split(field, ",")
+		if len(parts) != 2 && len(parts) != 3 {
+			return nil, fmt.Errorf("invalid track point %q", field)
+		}
+		coordinates, err := ParseCoordinates(parts[0] + "," + parts[1])
+		if err != nil {
+			return nil, err
+		}
+		points[i].Coordinates = coordinates
+		if len(parts) == 3 {
+			elevation, err := strconv.ParseFloat(parts[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid track point %q", field)
+			}
+			points[i].Elevation = &elevation
+		}
+	}
+	return points, nil
+}
@@ -2,104 +2,88 @@ package Trail
 
 import (
 	"bufio"
-	"encoding/csv"
+	"errors"
 	"fmt"
 	"os"
-	"strconv"
+	"project/Storage"
+	"project/utils"
 	"strings"
 )
 
-var TrailRecords []Trail
-
 type Trail struct {
-	Name       string
-	Location   string
-	Difficulty string
-	Length     float64
-	Status     string
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Location   Location   `json:"location"`
+	Difficulty Difficulty `json:"difficulty"`
+	Length     Distance   `json:"length"`
+	// ElevationGain is the total climb, 0 when not known
+	ElevationGain Elevation `json:"elevation_gain"`
+	Status        Status    `json:"status"`
+	Version       int       `json:"version"`
 }
 
-// Load trail data from a CSV file
-func LoadTrailData(filePath string) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		fmt.Println("Error loading file:", err)
-		return
-	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		fmt.Println("Error reading CSV:", err)
-		return
-	}
-
-	for _, record := range records {
-		if len(record) < 5 {
-			fmt.Println("Skipping invalid record:", record)
-			continue
-		}
-		length, err := strconv.ParseFloat(record[3], 64)
-		if err != nil {
-			fmt.Println("Error parsing trail length:", err)
-			continue
-		}
-		trail := Trail{
-			Name:       record[0],
-			Location:   record[1],
-			Difficulty: record[2],
-			Length:     length,
-			Status:     record[4],
-		}
-		TrailRecords = append(TrailRecords, trail)
-	}
-}
-
-// Save trail data to a CSV file
-func SaveTrailData(filePath string) {
-	file, err := os.Create(filePath)
-	if err != nil {
-		fmt.Println("Error creating file:", err)
-		return
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	for _, trail := range TrailRecords {
-		record := []string{trail.Name, trail.Location, trail.Difficulty, strconv.FormatFloat(trail.Length, 'f', 2, 64), trail.Status}
-		if err := writer.Write(record); err != nil {
-			fmt.Println("Error writing to CSV:", err)
-			return
-		}
+// Validate checks a trail against the rules addTrail enforces
+func (t Trail) Validate() error {
+	switch {
+	case strings.TrimSpace(t.Name) == "":
+		return errors.New("trail name cannot be empty")
+	case t.Location.Empty():
+		return errors.New("location cannot be empty")
+	case !t.Difficulty.Valid():
+		return fmt.Errorf("unknown difficulty %q, use %s", t.Difficulty, DifficultyHelp)
+	case !t.Length.Valid():
+		return fmt.Errorf("unknown length unit %q, use mi or km", t.Length.Unit)
+	case t.Length.Value <= 0:
+		return errors.New("trail length must be a positive number")
+	case !t.ElevationGain.Valid():
+		return fmt.Errorf("unknown elevation unit %q, use ft or m", t.ElevationGain.Unit)
+	case t.ElevationGain.Value < 0:
+		return errors.New("elevation gain cannot be negative")
+	case t.Location.Trailhead != nil && t.Location.Trailhead.Validate() != nil:
+		return fmt.Errorf("invalid trailhead: %w", t.Location.Trailhead.Validate())
+	case !t.Status.Valid():
+		return fmt.Errorf("unknown status %q, use one of: %s", t.Status, JoinStatuses(Statuses))
 	}
+	return nil
 }
 
 // Trail menu for managing trails
-func TrailMenu() {
+// Lengths and elevations are shown in units, and read in them when no unit
+// is given.
+func TrailMenu(repo Repository, history HistoryRepository, schedule ScheduleRepository, importGPX GPXImporter, units Units) {
 	for {
 		fmt.Println("\nManage Trails")
 		fmt.Println("1. Add Trail")
 		fmt.Println("2. Update Trail")
 		fmt.Println("3. Delete Trail")
 		fmt.Println("4. View Trails")
-		fmt.Println("5. Back to Main Menu")
+		fmt.Println("5. Change Trail Status")
+		fmt.Println("6. Schedule Status Change")
+		fmt.Println("7. Cancel Scheduled Change")
+		fmt.Println("8. Import GPX Track")
+		fmt.Println("9. Back to Main Menu")
 
 		var choice int
 		fmt.Scanln(&choice)
 
 		switch choice {
 		case 1:
-			addTrail()
+			addTrail(repo, units)
 		case 2:
-			updateTrail()
+			updateTrail(repo, units)
 		case 3:
-			deleteTrail()
+			deleteTrail(repo)
 		case 4:
-			viewTrails()
+			viewTrails(repo, history, schedule, units)
 		case 5:
+			changeStatus(repo, history)
+		case 6:
+			scheduleChange(repo, schedule)
+		case 7:
+			cancelScheduledChange(repo, schedule)
+		case 8:
+			importTrack(repo, importGPX, units)
+		case 9:
 			return
 		default:
 			fmt.Println("Invalid option.")
@@ -108,7 +92,7 @@ func TrailMenu() {
 }
 
 // Add a new trail
-func addTrail() {
+func addTrail(repo Repository, units Units) {
 	reader := bufio.NewReader(os.Stdin)
 
 	var trail Trail
@@ -123,118 +107,428 @@ func addTrail() {
 	}
 
 	// Get trail location
-	fmt.Print("Enter location: ")
-	trail.Location, _ = reader.ReadString('\n')
-	trail.Location = strings.TrimSpace(trail.Location)
-	if trail.Location == "" {
+	fmt.Print("Enter location (city, state, land unit in parentheses, e.g. Boise, ID (Boise National Forest)): ")
+	text, _ := reader.ReadString('\n')
+	trail.Location = ParseLocation(text)
+	if trail.Location.Empty() {
 		fmt.Println("Location cannot be empty.")
 		return
 	}
 
 	// Check for duplicates
-	for _, existingTrail := range TrailRecords {
-		if strings.EqualFold(existingTrail.Name, trail.Name) && strings.EqualFold(existingTrail.Location, trail.Location) {
+	trails, err := repo.List()
+	if err != nil {
+		fmt.Println("Error reading trails:", err)
+		return
+	}
+	for _, existingTrail := range trails {
+		if codec.Same(existingTrail, trail) {
 			fmt.Printf("A trail with the name '%s' and location '%s' already exists.\n", trail.Name, trail.Location)
 			return
 		}
 	}
 
-	// Get difficulty
-	fmt.Print("Enter difficulty (Easy, Medium, Hard): ")
-	trail.Difficulty, _ = reader.ReadString('\n')
-	trail.Difficulty = strings.TrimSpace(trail.Difficulty)
-	if trail.Difficulty == "" {
-		fmt.Println("Difficulty cannot be empty.")
+	// Get trailhead coordinates
+	fmt.Print("Enter trailhead coordinates (latitude,longitude, blank if not known): ")
+	text, _ = reader.ReadString('\n')
+	if trail.Location.Trailhead, err = readTrailhead(text, nil); err != nil {
+		fmt.Println(err)
 		return
 	}
 
 	// Get length with validation
-	fmt.Print("Enter length (miles): ")
 	for {
-		_, err := fmt.Scanln(&trail.Length)
-		if err != nil || trail.Length <= 0 {
-			fmt.Println("Please enter a valid positive number for trail length.")
-			fmt.Print("Enter length (miles): ")
-			continue
+		fmt.Printf("Enter length (e.g. 5.2 km or 3 mi; %s if no unit): ", units.Distance())
+		text, _ = reader.ReadString('\n')
+		if trail.Length, err = readLength(text, Distance{}, units); err == nil && trail.Length.Value > 0 {
+			break
 		}
-		break
+		fmt.Println("Please enter a valid positive length.")
+	}
+
+	// Get elevation gain
+	fmt.Printf("Enter elevation gain (e.g. 1200 ft or 350 m; %s if no unit, blank if not known): ", units.Elevation())
+	text, _ = reader.ReadString('\n')
+	if trail.ElevationGain, err = readElevationGain(text, Elevation{Unit: units.Elevation()}, units); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Get difficulty, suggesting one from the length and elevation gain
+	suggested := SuggestDifficulty(trail.Length, trail.ElevationGain)
+	fmt.Printf("Enter difficulty (%s; blank for %s): ", DifficultyHelp, suggested)
+	var ok bool
+	if trail.Difficulty, ok = readDifficulty(reader, suggested); !ok {
+		return
 	}
 
 	// Get status
-	fmt.Print("Enter status (open/closed): ")
-	trail.Status, _ = reader.ReadString('\n')
-	trail.Status = strings.TrimSpace(trail.Status)
-	if trail.Status == "" {
-		fmt.Println("Status cannot be empty.")
+	fmt.Printf("Enter status (%s): ", JoinStatuses(Statuses))
+	text, _ = reader.ReadString('\n')
+	if trail.Status, err = ParseStatus(strings.TrimSpace(text)); err != nil {
+		fmt.Println(err)
 		return
 	}
 
 	// Add the trail
-	TrailRecords = append(TrailRecords, trail)
+	if _, err := repo.Create(trail); err != nil {
+		fmt.Println("Error adding trail:", err)
+		return
+	}
 	fmt.Println("Trail added successfully.")
 }
 
 // Update an existing trail
-func updateTrail() {
+func updateTrail(repo Repository, units Units) {
 	reader := bufio.NewReader(os.Stdin)
 
-	// Get the trail name and location to uniquely identify the trail
-	fmt.Print("Enter the name of the trail to update: ")
-	name, _ := reader.ReadString('\n')
-	name = strings.TrimSpace(name)
+	trail, ok := findTrail(repo, reader, "Enter the name of the trail to update: ")
+	if !ok {
+		return
+	}
+	var err error
+
+	// Get new details for the trail
+	fmt.Printf("Enter new location (blank to keep %s): ", trail.Location)
+	text, _ := reader.ReadString('\n')
+	if strings.TrimSpace(text) != "" {
+		trailhead := trail.Location.Trailhead
+		trail.Location = ParseLocation(text)
+		trail.Location.Trailhead = trailhead
+	}
+
+	current := "not known"
+	if trail.Location.Trailhead != nil {
+		current = trail.Location.Trailhead.String()
+	}
+	fmt.Printf("Enter new trailhead coordinates (latitude,longitude, blank to keep %s): ", current)
+	text, _ = reader.ReadString('\n')
+	if trail.Location.Trailhead, err = readTrailhead(text, trail.Location.Trailhead); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("Enter new length (%s if no unit, blank to keep %s): ", units.Distance(), trail.Length)
+	text, _ = reader.ReadString('\n')
+	if trail.Length, err = readLength(text, trail.Length, units); err != nil {
+		fmt.Println(err)
+		return
+	}
 
-	fmt.Print("Enter the location of the trail to update: ")
-	location, _ := reader.ReadString('\n')
-	location = strings.TrimSpace(location)
+	fmt.Printf("Enter new elevation gain (%s if no unit, blank to keep %s): ", units.Elevation(), trail.ElevationGain)
+	text, _ = reader.ReadString('\n')
+	if trail.ElevationGain, err = readElevationGain(text, trail.ElevationGain, units); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("Enter new difficulty (%s; blank to keep %s, suggested %s): ", DifficultyHelp, trail.Difficulty, SuggestDifficulty(trail.Length, trail.ElevationGain))
+	if trail.Difficulty, ok = readDifficulty(reader, trail.Difficulty); !ok {
+		return
+	}
+
+	// Update the trail record
+	if err := trail.Validate(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	err = codec.UpdateResolving(repo, trail, utils.ConfirmOverwrite)
+	if errors.Is(err, Storage.ErrConflict) {
+		fmt.Println("Update cancelled, the trail keeps the other changes.")
+		return
+	}
+	if err != nil {
+		fmt.Println("Error updating trail:", err)
+		return
+	}
+	fmt.Println("Trail updated successfully.")
+}
+
+// readDifficulty reads a difficulty, returning fallback for a blank line
+func readDifficulty(reader *bufio.Reader, fallback Difficulty) (Difficulty, bool) {
+	text, _ := reader.ReadString('\n')
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return fallback, true
+	}
+	difficulty, err := ParseDifficulty(text)
+	if err != nil {
+		fmt.Println(err)
+		return "", false
+	}
+	return difficulty, true
+}
+
+// readTrailhead parses trailhead coordinates, returning current for a blank
+// line
+func readTrailhead(text string, current *Coordinates) (*Coordinates, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return current, nil
+	}
+	trailhead, err := ParseCoordinates(text)
+	if err != nil {
+		return current, err
+	}
+	return &trailhead, nil
+}
 
-	// Search for the trail by name and location
-	for i, trail := range TrailRecords {
-		if trail.Name == name && trail.Location == location {
-			// Get new details for the trail
-			fmt.Print("Enter new location: ")
-			trail.Location, _ = reader.ReadString('\n')
-			trail.Location = strings.TrimSpace(trail.Location)
+// readLength parses a trail length, in the units shown when it has no
+// unit, returning current for a blank line
+func readLength(text string, current Distance, units Units) (Distance, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return current, nil
+	}
+	length, err := ParseDistance(text, units.Distance())
+	if err == nil && length.Value <= 0 {
+		err = errors.New("trail length must be a positive number")
+	}
+	return length, err
+}
 
-			fmt.Print("Enter new difficulty: ")
-			trail.Difficulty, _ = reader.ReadString('\n')
-			trail.Difficulty = strings.TrimSpace(trail.Difficulty)
+// readElevationGain parses an elevation gain, in the units shown when it
+// has no unit, returning current for a blank line
+func readElevationGain(text string, current Elevation, units Units) (Elevation, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return current, nil
+	}
+	gain, err := ParseElevation(text, units.Elevation())
+	if err == nil && gain.Value < 0 {
+		err = errors.New("elevation gain cannot be negative")
+	}
+	return gain, err
+}
 
-			fmt.Print("Enter new length (miles): ")
-			fmt.Scanln(&trail.Length)
+// Change the status of a trail, recording why and since when
+func changeStatus(repo Repository, history HistoryRepository) {
+	reader := bufio.NewReader(os.Stdin)
 
-			fmt.Print("Enter new status (open/closed): ")
-			trail.Status, _ = reader.ReadString('\n')
-			trail.Status = strings.TrimSpace(trail.Status)
+	trail, ok := findTrail(repo, reader, "Enter the name of the trail: ")
+	if !ok {
+		return
+	}
 
-			// Update the trail record in memory
-			TrailRecords[i] = trail
-			fmt.Println("Trail updated successfully.")
+	change := StatusChange{TrailID: trail.ID}
+	var err error
+	fmt.Printf("The trail is %s. Enter the new status (%s): ", trail.Status, JoinStatuses(trail.Status.Next()))
+	text, _ := reader.ReadString('\n')
+	if change.To, err = ParseStatus(strings.TrimSpace(text)); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if !trail.Status.CanChangeTo(change.To) {
+		fmt.Printf("A trail that is %s cannot be changed to %s.\n", trail.Status, change.To)
+		return
+	}
 
-			// Save the updated data back to the CSV file
-			SaveTrailData("data/trails.csv")
-			return
-		}
+	fmt.Print("Enter the reason for the change: ")
+	change.Reason, _ = reader.ReadString('\n')
+	change.Reason = strings.TrimSpace(change.Reason)
+	if change.Reason == "" {
+		fmt.Println("Reason cannot be empty.")
+		return
 	}
-	fmt.Println("Trail not found.")
+
+	fmt.Printf("Enter the effective date (YYYY-MM-DD, blank for today %s): ", Today())
+	change.EffectiveDate, _ = reader.ReadString('\n')
+	change.EffectiveDate = strings.TrimSpace(change.EffectiveDate)
+
+	if _, err := history.Create(change); err != nil {
+		fmt.Println("Error changing status:", err)
+		return
+	}
+	fmt.Println("Trail status changed successfully.")
 }
 
-// Delete an existing trail
-// Delete an existing trail
-func deleteTrail() {
+// Schedule a status for a trail over a period ahead of time
+func scheduleChange(repo Repository, schedule ScheduleRepository) {
 	reader := bufio.NewReader(os.Stdin)
 
-	// Get trail name with spaces
-	fmt.Print("Enter the name of the trail to delete: ")
+	trail, ok := findTrail(repo, reader, "Enter the name of the trail: ")
+	if !ok {
+		return
+	}
+
+	change := ScheduledChange{TrailID: trail.ID}
+	fmt.Printf("Enter the scheduled status (%s): ", JoinStatuses(Statuses))
+	text, _ := reader.ReadString('\n')
+	var err error
+	if change.Status, err = ParseStatus(strings.TrimSpace(text)); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Print("Enter the reason for the change: ")
+	change.Reason, _ = reader.ReadString('\n')
+	change.Reason = strings.TrimSpace(change.Reason)
+
+	fmt.Print("Enter the start date (YYYY-MM-DD): ")
+	change.Start, _ = reader.ReadString('\n')
+	change.Start = strings.TrimSpace(change.Start)
+
+	fmt.Print("Enter the end date (YYYY-MM-DD, blank for no end): ")
+	change.End, _ = reader.ReadString('\n')
+	change.End = strings.TrimSpace(change.End)
+
+	if err := change.Validate(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if _, err := schedule.Create(change); err != nil {
+		fmt.Println("Error scheduling status change:", err)
+		return
+	}
+	fmt.Println("Status change scheduled successfully.")
+}
+
+// Cancel a scheduled status change of a trail
+func cancelScheduledChange(repo Repository, schedule ScheduleRepository) {
+	reader := bufio.NewReader(os.Stdin)
+
+	trail, ok := findTrail(repo, reader, "Enter the name of the trail: ")
+	if !ok {
+		return
+	}
+	changes, err := Schedule(schedule, trail.ID)
+	if err != nil {
+		fmt.Println("Error reading status schedule:", err)
+		return
+	}
+	if len(changes) == 0 {
+		fmt.Println("The trail has no scheduled changes.")
+		return
+	}
+
+	options := make([]string, len(changes))
+	for i, change := range changes {
+		options[i] = change.String()
+	}
+	i := utils.Choose("Select the scheduled change to cancel", options)
+	if i < 0 {
+		fmt.Println("Operation cancelled.")
+		return
+	}
+	fmt.Printf("Are you sure you want to cancel '%s'? (y/n): ", changes[i])
+	var confirmation string
+	fmt.Scanln(&confirmation)
+	if confirmation != "y" {
+		fmt.Println("Operation cancelled.")
+		return
+	}
+	if err := schedule.Delete(changes[i].ID); err != nil {
+		fmt.Println("Error cancelling scheduled change:", err)
+		return
+	}
+	fmt.Println("Scheduled change cancelled successfully.")
+}
+
+// Import the track of a trail from a GPX file, adding the trail if it is new
+func importTrack(repo Repository, importGPX GPXImporter, units Units) {
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Print("Enter the path of the GPX file: ")
+	path, _ := reader.ReadString('\n')
+	f, err := os.Open(strings.TrimSpace(path))
+	if err != nil {
+		fmt.Println("Error opening file:", err)
+		return
+	}
+	track, err := ReadGPX(f)
+	f.Close()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Read %d track points.\n", len(track.Points))
+
+	var trail Trail
+	fmt.Print("Is the track for an existing trail? (y/n): ")
+	var answer string
+	fmt.Scanln(&answer)
+	var ok bool
+	if answer == "y" {
+		if trail, ok = findTrail(repo, reader, "Enter the name of the trail: "); !ok {
+			return
+		}
+	} else {
+		if track.Name != "" {
+			fmt.Printf("Enter trail name (blank for %s): ", track.Name)
+		} else {
+			fmt.Print("Enter trail name: ")
+		}
+		trail.Name, _ = reader.ReadString('\n')
+		if trail.Name = strings.TrimSpace(trail.Name); trail.Name == "" {
+			trail.Name = track.Name
+		}
+		if trail.Name == "" {
+			fmt.Println("Trail name cannot be empty.")
+			return
+		}
+
+		fmt.Print("Enter location (city, state, land unit in parentheses, e.g. Boise, ID (Boise National Forest)): ")
+		text, _ := reader.ReadString('\n')
+		if trail.Location = ParseLocation(text); trail.Location.Empty() {
+			fmt.Println("Location cannot be empty.")
+			return
+		}
+		if _, err := Find(repo, trail.Name, trail.Location.String()); err == nil {
+			fmt.Printf("A trail with the name '%s' and location '%s' already exists.\n", trail.Name, trail.Location)
+			return
+		}
+
+		fmt.Printf("Enter status (%s): ", JoinStatuses(Statuses))
+		text, _ = reader.ReadString('\n')
+		if trail.Status, err = ParseStatus(strings.TrimSpace(text)); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	// Offer the difficulty suggested by the track, or keep the current one
+	fallback := trail.Difficulty
+	if fallback == "" {
+		stats := track.Stats()
+		fallback = SuggestDifficulty(stats.Length, stats.ElevationGain)
+	}
+	fmt.Printf("Enter difficulty (%s; blank for %s): ", DifficultyHelp, fallback)
+	if trail.Difficulty, ok = readDifficulty(reader, fallback); !ok {
+		return
+	}
+
+	trail, stats, err := importGPX(trail, track)
+	if err != nil {
+		fmt.Println("Error importing track:", err)
+		return
+	}
+	fmt.Printf("Track of '%s' imported successfully.\n", trail.Name)
+	fmt.Printf("Length: %s\n", stats.Length.Format(units))
+	fmt.Printf("Elevation gain: %s, loss: %s\n", stats.ElevationGain.Format(units), stats.ElevationLoss.Format(units))
+	fmt.Printf("Elevation: %s to %s\n", stats.MinElevation.Format(units), stats.MaxElevation.Format(units))
+	fmt.Printf("Trailhead: %s\n", stats.Trailhead)
+}
+
+// findTrail asks for the name of a trail and looks it up, letting the
+// user pick from the trails matching it
+func findTrail(repo Repository, reader *bufio.Reader, prompt string) (Trail, bool) {
+	fmt.Print(prompt)
 	name, _ := reader.ReadString('\n')
 	name = strings.TrimSpace(name)
+	return ChooseByName(repo, name)
+}
+
+// Delete an existing trail
+func deleteTrail(repo Repository) {
+	reader := bufio.NewReader(os.Stdin)
 
-	// Get location with spaces
-	fmt.Print("Enter the location of the trail to delete: ")
-	location, _ := reader.ReadString('\n')
-	location = strings.TrimSpace(location)
+	trail, ok := findTrail(repo, reader, "Enter the name of the trail to delete: ")
+	if !ok {
+		return
+	}
 
 	// Confirm before deletion
-	fmt.Printf("Are you sure you want to delete the trail '%s' at location '%s'? (y/n): ", name, location)
+	fmt.Printf("Are you sure you want to delete the trail '%s' at location '%s'? (y/n): ", trail.Name, trail.Location)
 	var confirmation string
 	fmt.Scanln(&confirmation)
 	if confirmation != "y" {
@@ -242,30 +536,43 @@ func deleteTrail() {
 		return
 	}
 
-	// Find and delete the trail by name and location
-	for i, trail := range TrailRecords {
-		if trail.Name == name && trail.Location == location {
-			// Delete the trail record from TrailRecords
-			TrailRecords = append(TrailRecords[:i], TrailRecords[i+1:]...)
-			fmt.Println("Trail deleted successfully.")
-			// Save the updated data back to the CSV file
-			SaveTrailData("data/trails.csv")
-			return
-		}
+	if err := repo.Delete(trail.ID); err != nil {
+		fmt.Println("Error deleting trail:", err)
+		return
 	}
-	fmt.Println("Trail not found.")
+	fmt.Println("Trail deleted successfully.")
 }
 
 // View all trails
-func viewTrails() {
-	if len(TrailRecords) == 0 {
+func viewTrails(repo Repository, history HistoryRepository, schedule ScheduleRepository, units Units) {
+	trails, err := repo.List()
+	if err != nil {
+		fmt.Println("Error reading trails:", err)
+		return
+	}
+	if len(trails) == 0 {
 		fmt.Println("No trails to display.")
 		return
 	}
 
+	today := Today()
 	fmt.Println("Trail List:")
-	for _, trail := range TrailRecords {
-		fmt.Printf("Name: %s, Location: %s, Difficulty: %s, Length: %.2f miles, Status: %s\n",
-			trail.Name, trail.Location, trail.Difficulty, trail.Length, trail.Status)
+	for _, trail := range trails {
+		trailHistory, err := History(history, trail.ID)
+		if err != nil {
+			fmt.Println("Error reading status history:", err)
+			return
+		}
+		trailSchedule, err := Schedule(schedule, trail.ID)
+		if err != nil {
+			fmt.Println("Error reading status schedule:", err)
+			return
+		}
+		status, _ := StatusOn(trail, trailHistory, trailSchedule, today)
+		fmt.Printf("ID: %s, Name: %s, Location: %s, Difficulty: %s, Length: %s, Elevation Gain: %s, Status: %s\n",
+			trail.ID, trail.Name, trail.Location, trail.Difficulty, trail.Length.Format(units), trail.ElevationGain.Format(units), status)
+		for _, change := range Upcoming(trailSchedule, today) {
+			fmt.Printf("  Upcoming: %s\n", change)
+		}
 	}
 }
@@ -0,0 +1,288 @@
+package Trail
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Units is the system lengths and elevations are shown in
+type Units string
+
+// Unit systems
+const (
+	// Imperial shows lengths in miles and elevations in feet
+	Imperial Units = "imperial"
+	// Metric shows lengths in kilometres and elevations in meters
+	Metric Units = "metric"
+)
+
+// ParseUnits parses "imperial" or "metric"
+func ParseUnits(s string) (Units, error) {
+	switch units := Units(strings.ToLower(strings.TrimSpace(s))); units {
+	case Imperial, Metric:
+		return units, nil
+	}
+	return "", fmt.Errorf("unknown units %q, use imperial or metric", s)
+}
+
+// Distance returns the unit lengths are shown in
+func (u Units) Distance() DistanceUnit {
+	if u == Metric {
+		return Kilometer
+	}
+	return Mile
+}
+
+// Elevation returns the unit elevations are shown in
+func (u Units) Elevation() ElevationUnit {
+	if u == Metric {
+		return Meter
+	}
+	return Foot
+}
+
+// DistanceUnit is the unit of a Distance
+type DistanceUnit string
+
+// Distance units
+const (
+	Mile      DistanceUnit = "mi"
+	Kilometer DistanceUnit = "km"
+)
+
+// ElevationUnit is the unit of an Elevation
+type ElevationUnit string
+
+// Elevation units
+const (
+	Foot  ElevationUnit = "ft"
+	Meter ElevationUnit = "m"
+)
+
+const (
+	metersPerMile = 1609.344
+	metersPerFoot = 0.3048
+)
+
+// distanceUnits and elevationUnits map the ways of writing a unit that
+// ParseDistance and ParseElevation accept to the unit
+var (
+	distanceUnits = map[string]DistanceUnit{
+		"mi": Mile, "mile": Mile, "miles": Mile,
+		"km": Kilometer, "kms": Kilometer, "kilometer": Kilometer, "kilometers": Kilometer, "kilometre": Kilometer, "kilometres": Kilometer,
+	}
+	elevationUnits = map[string]ElevationUnit{
+		"ft": Foot, "foot": Foot, "feet": Foot, "'": Foot,
+		"m": Meter, "meter": Meter, "meters": Meter, "metre": Meter, "metres": Meter,
+	}
+)
+
+// measurement matches a number optionally followed by a unit, with or
+// without a space between them
+var measurement = regexp.MustCompile(`^([-+]?(?:[0-9]+\.?[0-9]*|\.[0-9]+))\s*([a-z']*)$`)
+
+// parseMeasurement splits s into its value and unit
+func parseMeasurement(s string) (float64, string, bool) {
+	m := measurement.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, "", false
+	}
+	value, err := strconv.ParseFloat(m[1], 64)
+	return value, m[2], err == nil
+}
+
+// Distance is a length as it was entered, in its own unit, so that it is
+// stored without conversion or rounding. An empty unit is taken as miles.
+type Distance struct {
+	Value float64
+	Unit  DistanceUnit
+}
+
+// Miles returns a distance of n miles
+func Miles(n float64) Distance {
+	return Distance{Value: n, Unit: Mile}
+}
+
+// Kilometers returns a distance of n kilometres
+func Kilometers(n float64) Distance {
+	return Distance{Value: n, Unit: Kilometer}
+}
+
+// ParseDistance reads a distance such as "5.2km", "3 mi" or "2 miles". A
+// number without a unit is in unit.
+func ParseDistance(s string, unit DistanceUnit) (Distance, error) {
+	value, text, ok := parseMeasurement(s)
+	if ok && text != "" {
+		unit, ok = distanceUnits[text]
+	}
+	if !ok {
+		return Distance{}, fmt.Errorf("invalid distance %q, please enter a number with mi or km", s)
+	}
+	return Distance{Value: value, Unit: unit}, nil
+}
+
+// unit returns the unit of d, miles when none is given
+func (d Distance) unit() DistanceUnit {
+	if d.Unit == "" {
+		return Mile
+	}
+	return d.Unit
+}
+
+// Valid reports whether d is in a known unit
+func (d Distance) Valid() bool {
+	return d.unit() == Mile || d.unit() == Kilometer
+}
+
+// In returns d converted to unit
+func (d Distance) In(unit DistanceUnit) float64 {
+	if d.unit() == unit {
+		return d.Value
+	}
+	meters := d.Value * metersPerMile
+	if d.unit() == Kilometer {
+		meters = d.Value * 1000
+	}
+	if unit == Kilometer {
+		return meters / 1000
+	}
+	return meters / metersPerMile
+}
+
+// Miles returns d in miles
+func (d Distance) Miles() float64 {
+	return d.In(Mile)
+}
+
+// Format writes d in the units shown, to two decimals, e.g. "3.23 mi"
+func (d Distance) Format(units Units) string {
+	return fmt.Sprintf("%.2f %s", d.In(units.Distance()), units.Distance())
+}
+
+// String writes d as entered, e.g. "5.2 km", the way ParseDistance reads it
+func (d Distance) String() string {
+	return strconv.FormatFloat(d.Value, 'f', -1, 64) + " " + string(d.unit())
+}
+
+// MarshalJSON writes d as a string such as "5.2 km"
+func (d Distance) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a distance written as a string, or a number of miles
+// as trails exported before distances had units have it
+func (d *Distance) UnmarshalJSON(data []byte) error {
+	var miles float64
+	if err := json.Unmarshal(data, &miles); err == nil {
+		*d = Miles(miles)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("a distance must be a string such as \"5.2 km\" or a number of miles")
+	}
+	distance, err := ParseDistance(text, Mile)
+	if err != nil {
+		return err
+	}
+	*d = distance
+	return nil
+}
+
+// Elevation is a height or a climb as it was entered, in its own unit, so
+// that it is stored without conversion or rounding. An empty unit is taken
+// as feet.
+type Elevation struct {
+	Value float64
+	Unit  ElevationUnit
+}
+
+// Feet returns an elevation of n feet
+func Feet(n float64) Elevation {
+	return Elevation{Value: n, Unit: Foot}
+}
+
+// Meters returns an elevation of n meters
+func Meters(n float64) Elevation {
+	return Elevation{Value: n, Unit: Meter}
+}
+
+// ParseElevation reads an elevation such as "1200 ft", "350m" or "400
+// meters". A number without a unit is in unit.
+func ParseElevation(s string, unit ElevationUnit) (Elevation, error) {
+	value, text, ok := parseMeasurement(s)
+	if ok && text != "" {
+		unit, ok = elevationUnits[text]
+	}
+	if !ok {
+		return Elevation{}, fmt.Errorf("invalid elevation %q, please enter a number with ft or m", s)
+	}
+	return Elevation{Value: value, Unit: unit}, nil
+}
+
+// unit returns the unit of e, feet when none is given
+func (e Elevation) unit() ElevationUnit {
+	if e.Unit == "" {
+		return Foot
+	}
+	return e.Unit
+}
+
+// Valid reports whether e is in a known unit
+func (e Elevation) Valid() bool {
+	return e.unit() == Foot || e.unit() == Meter
+}
+
+// In returns e converted to unit
+func (e Elevation) In(unit ElevationUnit) float64 {
+	switch {
+	case e.unit() == unit:
+		return e.Value
+	case unit == Meter:
+		return e.Value * metersPerFoot
+	}
+	return e.Value / metersPerFoot
+}
+
+// Feet returns e in feet
+func (e Elevation) Feet() float64 {
+	return e.In(Foot)
+}
+
+// Format writes e in the units shown, to the foot or meter, e.g. "725 ft"
+func (e Elevation) Format(units Units) string {
+	return fmt.Sprintf("%.0f %s", e.In(units.Elevation()), units.Elevation())
+}
+
+// String writes e as entered, e.g. "221 m", the way ParseElevation reads it
+func (e Elevation) String() string {
+	return strconv.FormatFloat(e.Value, 'f', -1, 64) + " " + string(e.unit())
+}
+
+// MarshalJSON writes e as a string such as "221 m"
+func (e Elevation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.String())
+}
+
+// UnmarshalJSON reads an elevation written as a string, or a number of feet
+// as trails exported before elevations had units have it
+func (e *Elevation) UnmarshalJSON(data []byte) error {
+	var feet float64
+	if err := json.Unmarshal(data, &feet); err == nil {
+		*e = Feet(feet)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("an elevation must be a string such as \"1200 ft\" or a number of feet")
+	}
+	elevation, err := ParseElevation(text, Foot)
+	if err != nil {
+		return err
+	}
+	*e = elevation
+	return nil
+}
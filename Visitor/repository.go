@@ -0,0 +1,102 @@
+package visitor
+
+import (
+	"fmt"
+	"project/Storage"
+	"project/utils"
+	"strconv"
+)
+
+// Repository stores visitor records, keyed by ID
+type Repository = Storage.Repository[Visitor]
+
+// MemoryRepository, CSVRepository and SQLRepository are the available
+// Repository implementations
+type (
+	MemoryRepository = Storage.Memory[Visitor]
+	CSVRepository    = Storage.CSV[Visitor]
+	SQLRepository    = Storage.SQL[Visitor]
+)
+
+const idPrefix = "vis"
+
+var schema = Storage.Schema{
+	Name:    "visitors",
+	Version: 4,
+	Columns: []string{"id", "name", "visit_date", "trail_id", "trail", "feedback", "satisfaction", "version"},
+	Types:   map[string]string{"version": "INTEGER"},
+	Indexes: []string{"trail_id", "visit_date"},
+	Legacy: func(width int) (int, []string, bool) {
+		switch width {
+		case 5:
+			return 1, []string{"name", "visit_date", "trail", "feedback", "satisfaction"}, true
+		case 7:
+			return 2, []string{"id", "name", "visit_date", "trail_id", "trail", "feedback", "satisfaction"}, true
+		}
+		return 0, nil, false
+	},
+	Migrations: []Storage.Migration{
+		{To: 2, Description: "give every visit a stable id and a trail id", Apply: func(t *Storage.Table) {
+			t.AddColumn("id", func([]string) string { return utils.NewID(idPrefix) })
+			// Filled in by name once the trails are loaded
+			t.AddColumn("trail_id", func([]string) string { return "" })
+		}},
+		{To: 3, Description: "add a schema version marker and header row"},
+		{To: 4, Description: "give every visit a version number", Apply: func(t *Storage.Table) {
+			t.AddColumn("version", func([]string) string { return "1" })
+		}},
+	},
+}
+
+var codec = Storage.Codec[Visitor]{
+	Entity:     "visitor",
+	Prefix:     idPrefix,
+	Key:        func(v Visitor) string { return v.ID },
+	SetKey:     func(v Visitor, id string) Visitor { v.ID = id; return v },
+	Version:    func(v Visitor) int { return v.Version },
+	SetVersion: func(v Visitor, version int) Visitor { v.Version = version; return v },
+	Schema:     schema,
+	Encode: func(v Visitor) Storage.Row {
+		return Storage.Row{
+			"id":           v.ID,
+			"name":         v.Name,
+			"visit_date":   v.VisitDate,
+			"trail_id":     v.TrailID,
+			"trail":        v.Trail,
+			"feedback":     v.Feedback,
+			"satisfaction": v.Satisfaction,
+			"version":      strconv.Itoa(v.Version),
+		}
+	},
+	Decode: func(row Storage.Row) (Visitor, error) {
+		version, err := strconv.Atoi(row["version"])
+		if err != nil {
+			return Visitor{}, fmt.Errorf("invalid version: %w", err)
+		}
+		return Visitor{
+			ID:           row["id"],
+			Name:         row["name"],
+			VisitDate:    row["visit_date"],
+			TrailID:      row["trail_id"],
+			Trail:        row["trail"],
+			Feedback:     row["feedback"],
+			Satisfaction: row["satisfaction"],
+			Version:      version,
+		}, nil
+	},
+}
+
+// NewMemoryRepository creates an in-memory repository holding visitors
+func NewMemoryRepository(visitors ...Visitor) *MemoryRepository {
+	return Storage.NewMemory(codec, visitors...)
+}
+
+// NewCSVRepository creates a repository backed by the CSV file at filePath
+func NewCSVRepository(filePath string) *CSVRepository {
+	return Storage.NewCSV(filePath, codec)
+}
+
+// NewSQLRepository creates a repository backed by a table in db
+func NewSQLRepository(db *Storage.Database) *SQLRepository {
+	return Storage.NewSQL(db, codec)
+}
@@ -2,70 +2,40 @@ package visitor
 
 import (
 	"bufio"
-	"encoding/csv"
+	"errors"
 	"fmt"
 	"os"
+	"project/Storage"
+	Trail "project/Trail"
+	"project/utils"
 	"regexp"
 	"strings"
 )
 
 type Visitor struct {
-	Name         string
-	VisitDate    string
-	Trail        string
-	Feedback     string
-	Satisfaction string
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	VisitDate    string `json:"visit_date"`
+	TrailID      string `json:"trail_id"`
+	Trail        string `json:"trail"`
+	Feedback     string `json:"feedback"`
+	Satisfaction string `json:"satisfaction"`
+	Version      int    `json:"version"`
 }
 
-var Visitors []Visitor
-
-// Load visitor data from a CSV file
-func LoadVisitorData(filePath string) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return
-	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		fmt.Println("Error reading CSV:", err)
-		return
-	}
-
-	for _, record := range records {
-		visitor := Visitor{
-			Name:         record[0],
-			VisitDate:    record[1],
-			Trail:        record[2],
-			Feedback:     record[3],
-			Satisfaction: record[4],
-		}
-		Visitors = append(Visitors, visitor)
-	}
-}
-
-// Save visitor data to a CSV file
-func SaveVisitorData(filePath string) {
-	file, err := os.Create(filePath)
-	if err != nil {
-		fmt.Println("Error creating file:", err)
-		return
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	for _, visitor := range Visitors {
-		record := []string{visitor.Name, visitor.VisitDate, visitor.Trail, visitor.Feedback, visitor.Satisfaction}
-		if err := writer.Write(record); err != nil {
-			fmt.Println("Error writing to CSV:", err)
-			return
-		}
+// Validate checks a visitor record against the rules addVisitor enforces
+func (v Visitor) Validate() error {
+	switch {
+	case strings.TrimSpace(v.Name) == "":
+		return errors.New("visitor name cannot be empty")
+	case !isValidDate(v.VisitDate):
+		return fmt.Errorf("invalid visit date %q, please use YYYY-MM-DD", v.VisitDate)
+	case strings.TrimSpace(v.Trail) == "":
+		return errors.New("trail name cannot be empty")
+	case !isValidSatisfaction(v.Satisfaction):
+		return fmt.Errorf("satisfaction score %q must be between 1 and 5", v.Satisfaction)
 	}
+	return nil
 }
 
 // Validate the satisfaction score (1-5)
@@ -93,8 +63,51 @@ func readInput(prompt string) string {
 	return strings.TrimSpace(input)
 }
 
+// chooseVisitor finds the visits by name on date, asking the user to pick
+// one when there are several
+func chooseVisitor(repo Repository, name, date string) (Visitor, bool) {
+	visitors, err := repo.List()
+	if err != nil {
+		fmt.Println("Error reading visitors:", err)
+		return Visitor{}, false
+	}
+
+	var matches []Visitor
+	var options []string
+	for _, visitor := range visitors {
+		if visitor.Name == name && visitor.VisitDate == date {
+			matches = append(matches, visitor)
+			options = append(options, fmt.Sprintf("%s on %s at %s (ID %s)", visitor.Name, visitor.VisitDate, visitor.Trail, visitor.ID))
+		}
+	}
+	if len(matches) == 0 {
+		fmt.Println("Visitor not found.")
+		return Visitor{}, false
+	}
+
+	i := utils.Choose("Select the visit", options)
+	if i < 0 {
+		fmt.Println("Operation cancelled.")
+		return Visitor{}, false
+	}
+	return matches[i], true
+}
+
+// linkTrail points the visit at the trail named in visitor.Trail, or the
+// one the user picks from those with a similar name, and writes the name
+// as the trail has it. It reports false if there is no such trail or the
+// user cancels.
+func linkTrail(trails Trail.Repository, visitor *Visitor) bool {
+	trail, ok := Trail.ChooseByName(trails, visitor.Trail)
+	if !ok {
+		return false
+	}
+	visitor.TrailID, visitor.Trail = trail.ID, trail.Name
+	return true
+}
+
 // Visitor menu for managing visitors
-func VisitorMenu() {
+func VisitorMenu(repo Repository, trails Trail.Repository) {
 	for {
 		fmt.Println("\nVisitor Tracking")
 		fmt.Println("1. Add Visitor")
@@ -108,13 +121,13 @@ func VisitorMenu() {
 
 		switch choice {
 		case 1:
-			addVisitor()
+			addVisitor(repo, trails)
 		case 2:
-			updateVisitor()
+			updateVisitor(repo, trails)
 		case 3:
-			deleteVisitor()
+			deleteVisitor(repo)
 		case 4:
-			viewVisitors()
+			viewVisitors(repo)
 		case 5:
 			return
 		default:
@@ -124,7 +137,7 @@ func VisitorMenu() {
 }
 
 // Add a new visitor
-func addVisitor() {
+func addVisitor(repo Repository, trails Trail.Repository) {
 	var visitor Visitor
 
 	// Get and validate visitor name
@@ -147,6 +160,9 @@ func addVisitor() {
 		fmt.Println("Trail name cannot be empty.")
 		return
 	}
+	if !linkTrail(trails, &visitor) {
+		return
+	}
 
 	// Get and validate satisfaction score
 	visitor.Satisfaction = readInput("Enter satisfaction score (1-5): ")
@@ -158,57 +174,75 @@ func addVisitor() {
 	// Get feedback
 	visitor.Feedback = readInput("Enter feedback (e.g., 'satisfied, wildlife'): ")
 
-	Visitors = append(Visitors, visitor)
+	if _, err := repo.Create(visitor); err != nil {
+		fmt.Println("Error adding visitor:", err)
+		return
+	}
 	fmt.Println("Visitor added successfully.")
 }
 
 // Update an existing visitor
-func updateVisitor() {
+func updateVisitor(repo Repository, trails Trail.Repository) {
 	name := readInput("Enter visitor name to update: ")
 	date := readInput("Enter visit date to update: ")
 
-	for i, visitor := range Visitors {
-		if visitor.Name == name && visitor.VisitDate == date {
-			// Get new visitor details with validation
-			visitor.Name = readInput("Enter new name: ")
-			if visitor.Name == "" {
-				fmt.Println("Visitor name cannot be empty.")
-				return
-			}
-
-			visitor.VisitDate = readInput("Enter new visit date (YYYY-MM-DD): ")
-			if !isValidDate(visitor.VisitDate) {
-				fmt.Println("Invalid date format. Please use YYYY-MM-DD.")
-				return
-			}
-
-			visitor.Trail = readInput("Enter new trail name: ")
-			if visitor.Trail == "" {
-				fmt.Println("Trail name cannot be empty.")
-				return
-			}
-
-			visitor.Satisfaction = readInput("Enter new satisfaction score: ")
-			if !isValidSatisfaction(visitor.Satisfaction) {
-				fmt.Println("Satisfaction score must be between 1 and 5.")
-				return
-			}
-
-			visitor.Feedback = readInput("Enter new feedback: ")
-
-			Visitors[i] = visitor
-			fmt.Println("Visitor record updated successfully.")
-			return
-		}
+	visitor, ok := chooseVisitor(repo, name, date)
+	if !ok {
+		return
+	}
+
+	// Get new visitor details with validation
+	visitor.Name = readInput("Enter new name: ")
+	if visitor.Name == "" {
+		fmt.Println("Visitor name cannot be empty.")
+		return
+	}
+
+	visitor.VisitDate = readInput("Enter new visit date (YYYY-MM-DD): ")
+	if !isValidDate(visitor.VisitDate) {
+		fmt.Println("Invalid date format. Please use YYYY-MM-DD.")
+		return
+	}
+
+	visitor.Trail = readInput("Enter new trail name: ")
+	if visitor.Trail == "" {
+		fmt.Println("Trail name cannot be empty.")
+		return
+	}
+	if !linkTrail(trails, &visitor) {
+		return
+	}
+
+	visitor.Satisfaction = readInput("Enter new satisfaction score: ")
+	if !isValidSatisfaction(visitor.Satisfaction) {
+		fmt.Println("Satisfaction score must be between 1 and 5.")
+		return
+	}
+
+	visitor.Feedback = readInput("Enter new feedback: ")
+
+	err := codec.UpdateResolving(repo, visitor, utils.ConfirmOverwrite)
+	if errors.Is(err, Storage.ErrConflict) {
+		fmt.Println("Update cancelled, the visitor record keeps the other changes.")
+		return
+	}
+	if err != nil {
+		fmt.Println("Error updating visitor:", err)
+		return
 	}
-	fmt.Println("Visitor not found.")
+	fmt.Println("Visitor record updated successfully.")
 }
 
 // Delete an existing visitor
-func deleteVisitor() {
+func deleteVisitor(repo Repository) {
 	name := readInput("Enter visitor name to delete: ")
 	date := readInput("Enter visit date to delete: ")
 
+	visitor, ok := chooseVisitor(repo, name, date)
+	if !ok {
+		return
+	}
+
 	// Confirm before deletion
 	fmt.Printf("Are you sure you want to delete the record for '%s' on '%s'? (y/n): ", name, date)
 	var confirmation string
@@ -218,25 +252,27 @@ func deleteVisitor() {
 		return
 	}
 
-	for i, visitor := range Visitors {
-		if visitor.Name == name && visitor.VisitDate == date {
-			Visitors = append(Visitors[:i], Visitors[i+1:]...)
-			fmt.Println("Visitor record deleted successfully.")
-			return
-		}
+	if err := repo.Delete(visitor.ID); err != nil {
+		fmt.Println("Error deleting visitor:", err)
+		return
 	}
-	fmt.Println("Visitor not found.")
+	fmt.Println("Visitor record deleted successfully.")
 }
 
 // View all visitors
-func viewVisitors() {
-	if len(Visitors) == 0 {
+func viewVisitors(repo Repository) {
+	visitors, err := repo.List()
+	if err != nil {
+		fmt.Println("Error reading visitors:", err)
+		return
+	}
+	if len(visitors) == 0 {
 		fmt.Println("No visitors to display.")
 		return
 	}
 
 	fmt.Println("List of Visitors:")
-	for _, visitor := range Visitors {
-		fmt.Printf("Name: %s, Date: %s, Trail: %s, Satisfaction: %s, Feedback: %s\n", visitor.Name, visitor.VisitDate, visitor.Trail, visitor.Satisfaction, visitor.Feedback)
+	for _, visitor := range visitors {
+		fmt.Printf("ID: %s, Name: %s, Date: %s, Trail: %s, Satisfaction: %s, Feedback: %s\n", visitor.ID, visitor.Name, visitor.VisitDate, visitor.Trail, visitor.Satisfaction, visitor.Feedback)
 	}
 }
@@ -3,18 +3,88 @@ package main
 // By Lauren Auer
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	CLI "project/CLI"
+	DataStore "project/DataStore"
 	Feedback "project/Feedback"
 	Maintenance "project/Maintenance"
+	Network "project/Network"
+	Search "project/Search"
 	Status "project/Status"
 	Trail "project/Trail"
 	Visitor "project/Visitor"
+	"project/utils"
+	"strconv"
 )
 
 func main() {
+	flag.Usage = func() {
+		CLI.Usage(os.Stderr)
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		flag.PrintDefaults()
+	}
+	dataDir := flag.String("data-dir", "", "directory holding the data files, overriding TRAILS_DATA_DIR")
+	units := flag.String("units", "", "units to show lengths and elevations in, imperial or metric, overriding TRAILS_UNITS")
+	dryRun := flag.Bool("migrate-dry-run", false, "same as the command migrate --dry-run")
+	importCSV := flag.Bool("import-csv", false, "same as the command import-csv")
+	exportJSON := flag.String("export-json", "", "same as the command export FILE")
+	importJSON := flag.String("import-json", "", "same as the command import FILE")
+	backups := flag.Bool("backups", false, "same as the command backups")
+	restore := flag.String("restore", "", "same as the command restore BACKUP")
+	dataset := flag.String("dataset", "", "dataset for -export-json and -import-json")
+	merge := flag.Bool("merge", false, "merge for -import-json")
+	flag.Parse()
+
+	config, err := DataStore.ConfigFromEnv()
+	if err != nil {
+		fmt.Println("Error in configuration:", err)
+		os.Exit(1)
+	}
+	if *dataDir != "" {
+		config.DataDir = *dataDir
+	}
+	if *units != "" {
+		if config.Units, err = Trail.ParseUnits(*units); err != nil {
+			fmt.Println("Error in configuration:", err)
+			os.Exit(1)
+		}
+	}
+
+	// Commands run without the menu. The older flags are shorthands for
+	// some of them.
+	args := flag.Args()
+	switch {
+	case *dryRun:
+		args = []string{"migrate", "--dry-run"}
+	case *importCSV:
+		args = []string{"import-csv"}
+	case *exportJSON != "":
+		args = []string{"export", "--dataset", *dataset, *exportJSON}
+	case *importJSON != "":
+		args = []string{"import", "--dataset", *dataset, "--merge=" + strconv.FormatBool(*merge), *importJSON}
+	case *backups:
+		args = []string{"backups"}
+	case *restore != "":
+		args = []string{"restore", *restore}
+	}
+	if len(args) > 0 {
+		os.Exit(CLI.Run(config, args, os.Stdout, os.Stderr))
+	}
+
+	store, err := DataStore.New(config)
+	if err != nil {
+		fmt.Println("Error opening data store:", err)
+		os.Exit(1)
+	}
+
 	// Load all necessary data files once at the start
-	Status.LoadData() // This will load both Trail and Maintenance data
+	for _, result := range store.Load() {
+		CLI.ReportLoad(os.Stdout, result)
+	}
 
 	// Main menu loop
 	for {
@@ -25,35 +95,267 @@ func main() {
 		fmt.Println("3. Track Maintenance")
 		fmt.Println("4. Feedback Summary")
 		fmt.Println("5. Trail Status")
-		fmt.Println("6. Save and Exit")
+		fmt.Println("6. Check Data Integrity")
+		fmt.Println("7. Restore Backup")
+		fmt.Println("8. Import/Export Data")
+		fmt.Println("9. Trail Network")
+		fmt.Println("10. Search Trails")
+		fmt.Println("11. Save and Exit")
 
 		var choice int
 		fmt.Scanln(&choice)
 
 		switch choice {
 		case 1:
-			Trail.TrailMenu()
+			Trail.TrailMenu(store.Trails, store.StatusHistory, store.StatusSchedule, store.ImportGPX, config.Units)
 		case 2:
-			Visitor.VisitorMenu()
+			Visitor.VisitorMenu(store.Visitors, store.Trails)
 		case 3:
-			Maintenance.MaintenanceMenu()
+			Maintenance.MaintenanceMenu(store.Maintenance, store.Plans, store.Trails, store.GenerateWorkOrders)
 		case 4:
-			Feedback.ViewFeedbackSummary()
+			Feedback.ViewFeedbackSummary(store.Visitors)
 		case 5:
-			Status.ViewTrailStatus()
+			Status.ViewTrailStatus(store.Trails, store.Maintenance, store.StatusHistory, store.StatusSchedule)
 		case 6:
-			saveAndExit()
+			checkIntegrity(store)
+		case 7:
+			restoreBackup(store)
+		case 8:
+			jsonMenu(store)
+		case 9:
+			Network.NetworkMenu(store.Junctions, store.Segments, store.Trails, store.Maintenance, store.StatusHistory, store.StatusSchedule, config.Units)
+		case 10:
+			Search.SearchMenu(store.Trails, store.Visitors, store.Maintenance, store.StatusHistory, store.StatusSchedule, config.Units)
+		case 11:
+			saveAndExit(store)
 		default:
 			fmt.Println("Invalid option. Please try again.")
 		}
 	}
 }
 
-func saveAndExit() {
+func saveAndExit(store *DataStore.DataStore) {
 	// Save all data before exiting
-	Trail.SaveTrailData("data/trails.csv")
-	Visitor.SaveVisitorData("data/visitors.csv")
-	Maintenance.SaveMaintenanceData("data/maintenance.csv")
+	if err := store.Save(); err != nil {
+		fmt.Println("Error saving data:", err)
+	}
+	store.Close()
 	fmt.Println("Data saved. Exiting application.")
 	os.Exit(0)
 }
+
+// checkIntegrity lists the records with broken trail references and lets
+// the user relink, delete or keep each of them
+func checkIntegrity(store *DataStore.DataStore) {
+	problems, err := store.CheckIntegrity()
+	if err != nil {
+		fmt.Println("Error checking data:", err)
+		return
+	}
+	if len(problems) == 0 {
+		fmt.Println("No integrity problems found.")
+		return
+	}
+
+	fmt.Printf("\nFound %d integrity problems:\n", len(problems))
+	for _, problem := range problems {
+		fmt.Println("-", problem)
+	}
+	fmt.Print("Repair them now? (y/n): ")
+	var confirmation string
+	fmt.Scanln(&confirmation)
+	if confirmation != "y" {
+		return
+	}
+
+	for _, problem := range problems {
+		fmt.Printf("\n%s\n", problem)
+		var options []string
+		for _, trail := range problem.Candidates {
+			options = append(options, fmt.Sprintf("Link to %s at %s", trail.Name, trail.Location))
+		}
+		options = append(options, "Delete the record", "Leave it as it is")
+
+		switch i := utils.Choose("Select a repair", options); {
+		case i < 0 || i == len(options)-1:
+			fmt.Println("Left unchanged.")
+		case i == len(options)-2:
+			if err := store.DeleteRecord(problem); err != nil {
+				fmt.Println("Error deleting record:", err)
+				continue
+			}
+			fmt.Println("Record deleted.")
+		default:
+			if err := store.Relink(problem, problem.Candidates[i]); err != nil {
+				fmt.Println("Error linking record:", err)
+				continue
+			}
+			fmt.Println("Record linked.")
+		}
+	}
+}
+
+// restoreBackup lets the user pick a backup of one of the data files and
+// restores it over the current file
+func restoreBackup(store *DataStore.DataStore) {
+	backups, err := store.Backups()
+	if err != nil {
+		fmt.Println("Error listing backups:", err)
+		return
+	}
+	if len(backups) == 0 {
+		fmt.Println("No backups available.")
+		return
+	}
+
+	fmt.Println("\nAvailable Backups:")
+	for i, backup := range backups {
+		fmt.Printf("%d. %s from %s\n", i+1, filepath.Base(backup.File), backup.Taken.Format("2006-01-02 15:04:05"))
+	}
+
+	fmt.Print("Select a backup to restore (0 to cancel): ")
+	var n int
+	fmt.Scanln(&n)
+	if n < 1 || n > len(backups) {
+		fmt.Println("Restore cancelled.")
+		return
+	}
+	selected := backups[n-1]
+
+	fmt.Printf("Are you sure you want to replace %s with this backup? (y/n): ", selected.File)
+	var confirmation string
+	fmt.Scanln(&confirmation)
+	if confirmation != "y" {
+		fmt.Println("Restore cancelled.")
+		return
+	}
+
+	restored := true
+	for _, result := range store.Restore(selected) {
+		CLI.ReportLoad(os.Stdout, result)
+		restored = restored && result.Err == nil
+	}
+	if restored {
+		fmt.Println("Backup restored successfully.")
+	}
+}
+
+// jsonMenu exports the data to a JSON file or imports one, or exports the
+// trails as a map
+func jsonMenu(store *DataStore.DataStore) {
+	fmt.Println("\nImport/Export Data")
+	fmt.Println("1. Export JSON")
+	fmt.Println("2. Import JSON")
+	fmt.Println("3. Export Trail Map (GeoJSON or KML)")
+	fmt.Println("4. Back to Main Menu")
+	var choice int
+	fmt.Scanln(&choice)
+	if choice == 3 {
+		exportMap(store)
+		return
+	}
+	if choice != 1 && choice != 2 {
+		return
+	}
+
+	options := []string{"All datasets"}
+	for _, name := range DataStore.Datasets {
+		options = append(options, "Only "+name)
+	}
+	i := utils.Choose("Select the data", options)
+	if i < 0 {
+		fmt.Println("Operation cancelled.")
+		return
+	}
+	dataset := ""
+	if i > 0 {
+		dataset = DataStore.Datasets[i-1]
+	}
+
+	fmt.Print("Enter the JSON file path: ")
+	var path string
+	fmt.Scanln(&path)
+	if path == "" {
+		fmt.Println("File path cannot be empty.")
+		return
+	}
+
+	if choice == 1 {
+		if err := exportJSONFile(store, path, dataset); err != nil {
+			fmt.Println("Error exporting JSON:", err)
+			return
+		}
+		fmt.Println("Data exported to", path)
+		return
+	}
+
+	fmt.Print("Merge with the stored records instead of replacing them? (y/n): ")
+	var confirmation string
+	fmt.Scanln(&confirmation)
+	mode := DataStore.Replace
+	if confirmation == "y" {
+		mode = DataStore.Merge
+	}
+	if err := importJSONFile(store, path, dataset, mode); err != nil {
+		fmt.Println("Error importing JSON:", err)
+	}
+}
+
+// exportMap writes the trails with their status today to a GeoJSON or KML
+// file
+func exportMap(store *DataStore.DataStore) {
+	i := utils.Choose("Select the map format", []string{"GeoJSON", "KML"})
+	if i < 0 {
+		fmt.Println("Operation cancelled.")
+		return
+	}
+	format := Status.MapFormats[i]
+
+	fmt.Print("Enter the map file path: ")
+	var path string
+	fmt.Scanln(&path)
+	if path == "" {
+		fmt.Println("File path cannot be empty.")
+		return
+	}
+
+	snapshot, err := store.Snapshot()
+	if err != nil {
+		fmt.Println("Error reading data:", err)
+		return
+	}
+	trails, err := Status.MapTrails(snapshot.Trails, snapshot.Maintenance, snapshot.StatusHistory, snapshot.StatusSchedule, snapshot.Tracks, Trail.Today())
+	if err != nil {
+		fmt.Println("Error reading data:", err)
+		return
+	}
+	if err := utils.WriteFileAtomic(path, func(w io.Writer) error {
+		return Status.WriteMap(w, format, trails, store.Config().Units)
+	}); err != nil {
+		fmt.Println("Error exporting map:", err)
+		return
+	}
+	fmt.Printf("Map of %d trails exported to %s\n", len(trails), path)
+}
+
+// exportJSONFile writes a JSON export to path
+func exportJSONFile(store *DataStore.DataStore, path, dataset string) error {
+	return utils.WriteFileAtomic(path, func(w io.Writer) error {
+		return store.ExportJSON(w, dataset)
+	})
+}
+
+// importJSONFile imports the JSON file at path and prints what changed
+func importJSONFile(store *DataStore.DataStore, path, dataset string, mode DataStore.ImportMode) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	result, err := store.ImportJSON(f, dataset, mode)
+	if err != nil {
+		return err
+	}
+	CLI.ReportImport(os.Stdout, result)
+	return nil
+}
@@ -1 +1,159 @@
 package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BackupsKept is the number of backups kept for each data file
+const BackupsKept = 10
+
+// backupTimeFormat sorts lexically in chronological order
+const backupTimeFormat = "20060102-150405.000"
+
+// WriteFileAtomic replaces the file at path with the output of write. The
+// data goes to a temporary file in the same directory which is synced and
+// then renamed over path, so a crash leaves either the old or the new file.
+func WriteFileAtomic(path string, write func(w io.Writer) error) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Clean up the temporary file if anything below fails
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir makes a rename in dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// Not every platform supports syncing a directory, the rename has
+	// happened either way
+	d.Sync()
+	return nil
+}
+
+// BackupDir returns the directory backups of the file at path are kept in
+func BackupDir(path string) string {
+	return filepath.Join(filepath.Dir(path), "backups")
+}
+
+// BackupFile copies the file at path to a timestamped file in BackupDir and
+// removes the oldest backups beyond BackupsKept. It does nothing if the
+// file does not exist yet.
+func BackupFile(path string) error {
+	src, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dir := BackupDir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	stem, ext := splitName(path)
+	name := fmt.Sprintf("%s-%s%s", stem, time.Now().Format(backupTimeFormat), ext)
+	err = WriteFileAtomic(filepath.Join(dir, name), func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	backups, err := ListBackups(path)
+	if err != nil {
+		return err
+	}
+	for _, old := range backups[min(len(backups), BackupsKept):] {
+		os.Remove(old)
+	}
+	return nil
+}
+
+// ListBackups returns the backups of the file at path, newest first
+func ListBackups(path string) ([]string, error) {
+	entries, err := os.ReadDir(BackupDir(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		if _, ok := BackupTime(path, entry.Name()); ok {
+			backups = append(backups, filepath.Join(BackupDir(path), entry.Name()))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// BackupTime returns when backup, a backup of the file at path, was taken
+func BackupTime(path, backup string) (time.Time, bool) {
+	stem, ext := splitName(path)
+	name := filepath.Base(backup)
+	if !strings.HasPrefix(name, stem+"-") || !strings.HasSuffix(name, ext) {
+		return time.Time{}, false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, stem+"-"), ext)
+	taken, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return taken, true
+}
+
+// RestoreBackup replaces the file at path with backup. The current file is
+// backed up first so a restore can itself be undone.
+func RestoreBackup(backup, path string) error {
+	// Read the backup first, backing up the current file may rotate it out
+	data, err := os.ReadFile(backup)
+	if err != nil {
+		return err
+	}
+	if err := BackupFile(path); err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+func splitName(path string) (stem, ext string) {
+	base := filepath.Base(path)
+	ext = filepath.Ext(base)
+	return strings.TrimSuffix(base, ext), ext
+}
@@ -0,0 +1,39 @@
+package utils
+
+import "fmt"
+
+// Choose lists options numbered from 1 and returns the index of the one the
+// user picks, or -1 if they cancel. A single option is picked without asking.
+func Choose(prompt string, options []string) int {
+	if len(options) == 1 {
+		return 0
+	}
+	for i, option := range options {
+		fmt.Printf("%d. %s\n", i+1, option)
+	}
+	fmt.Printf("%s (0 to cancel): ", prompt)
+	var n int
+	fmt.Scanln(&n)
+	if n < 1 || n > len(options) {
+		return -1
+	}
+	return n - 1
+}
+
+// Difference is a field in which two versions of a record differ
+type Difference struct {
+	Field, Mine, Theirs string
+}
+
+// ConfirmOverwrite shows how the version of a record someone else saved
+// differs from the user's and asks whether to save over it
+func ConfirmOverwrite(entity string, diff []Difference) bool {
+	fmt.Printf("Someone else changed this %s while you were editing it:\n", entity)
+	for _, d := range diff {
+		fmt.Printf("  %s: yours '%s', theirs '%s'\n", d.Field, d.Mine, d.Theirs)
+	}
+	fmt.Print("Save your changes over theirs? (y/n): ")
+	var answer string
+	fmt.Scanln(&answer)
+	return answer == "y"
+}